@@ -0,0 +1,55 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"github.com/sdibtacm/sandbox/checker"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var cmdCheckChecker string
+
+var checkCmd = &cobra.Command{
+	Use:   "check [flags] OUTPUT ANSWER",
+	Short: "Compare an output file with the answer file",
+	Long: `Compare an output file with the answer file, print the verdict and where they differ.
+
+  exact   byte by byte, presentation error if only whitespace differs
+  lines   like exact, whitespace at the end of lines and blank lines at the end are ignored
+  tokens  tokens split by whitespace
+  nocase  like tokens, the case of letters is ignored
+  float   like tokens, numbers are compared with epsilon, like float:1e-4 or float:ABS,REL`,
+	Example:       "sandbox check --checker float:1e-6 main.out 1.ans",
+	Args:          cobra.ExactArgs(2),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return check(args[0], args[1])
+	},
+}
+
+func init() {
+	checkCmd.Flags().StringVar(&cmdCheckChecker, "checker", checker.CHECKER_LINES, "Compare by `checker`, one of "+strings.Join(checker.CHECKER_STR, ", "))
+
+	cmd.AddCommand(checkCmd)
+}
+
+func check(output, answer string) error {
+	ch, err := checker.Parse(cmdCheckChecker)
+	if err != nil {
+		return err
+	}
+	r, err := ch.Check("", output, answer)
+	if err != nil {
+		return err
+	}
+	if r.Message != "" {
+		fmt.Printf("%s: %s\n", exec.VERDICT_STR[r.Verdict], r.Message)
+	} else {
+		fmt.Println(exec.VERDICT_STR[r.Verdict])
+	}
+	return nil
+}
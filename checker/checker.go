@@ -0,0 +1,184 @@
+// +build linux
+
+// Package checker compares the output of a program with the answer.
+//
+// Outputs are read in a streaming way, so large outputs are not loaded into
+// memory. Every Checker is an exec.Checker, so it can be used by exec.Batch:
+//
+//	b := &exec.Batch{Checker: checker.Tokens(), ...}
+package checker
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	CHECKER_EXACT  = "exact"
+	CHECKER_LINES  = "lines"
+	CHECKER_TOKENS = "tokens"
+	CHECKER_NOCASE = "nocase"
+	CHECKER_FLOAT  = "float"
+)
+
+// CHECKER_STR is the names accepted by Parse.
+var CHECKER_STR = []string{
+	CHECKER_EXACT,
+	CHECKER_LINES,
+	CHECKER_TOKENS,
+	CHECKER_NOCASE,
+	CHECKER_FLOAT,
+}
+
+const DEFAULT_EPSILON = 1e-6
+
+var ErrUnknownChecker = errors.New("checker: unknown checker")
+
+// Result is the result of a compare. When output differs from answer, Line and
+// Column is where the output first differs, Expected and Got is the context there.
+type Result struct {
+	Verdict  int // exec.VERDICT_OK, exec.VERDICT_WRONG_ANSWER or exec.VERDICT_PRESENTATION_ERROR
+	Line     int // 1-based
+	Column   int // 1-based, in bytes
+	Token    int // 1-based index of the differing token, 0 for checkers not compare by token
+	Expected string
+	Got      string
+}
+
+// Message describes where the output differs, empty if they are the same.
+func (r *Result) Message() string {
+	if r.Verdict == exec.VERDICT_OK {
+		return ""
+	}
+	if r.Token > 0 {
+		return fmt.Sprintf("token %d at line %d column %d: expected %s, got %s",
+			r.Token, r.Line, r.Column, tokenStr(r.Expected), tokenStr(r.Got))
+	}
+	return fmt.Sprintf("line %d column %d: expected %q, got %q", r.Line, r.Column, r.Expected, r.Got)
+}
+
+func tokenStr(s string) string {
+	if s == "" {
+		return "EOF"
+	}
+	return strconv.Quote(s)
+}
+
+// Checker compares an output with its answer.
+type Checker interface {
+	exec.Checker
+	Compare(output, answer io.Reader) (*Result, error)
+}
+
+type checker func(output, answer io.ByteReader) (*Result, error)
+
+func (c checker) Compare(output, answer io.Reader) (*Result, error) {
+	return c(newReader(output), newReader(answer))
+}
+
+// Check compares the output file with the answer file, the input is not used.
+func (c checker) Check(input, output, answer string) (*exec.CheckResult, error) {
+	out, err := os.Open(output)
+	if err != nil {
+		return nil, err
+	}
+	defer out.Close()
+	ans, err := os.Open(answer)
+	if err != nil {
+		return nil, err
+	}
+	defer ans.Close()
+
+	r, err := c.Compare(out, ans)
+	if err != nil {
+		return nil, err
+	}
+	return &exec.CheckResult{Verdict: r.Verdict, Message: r.Message()}, nil
+}
+
+// Exact compares byte by byte, if only whitespace differs, the verdict is
+// presentation error.
+func Exact() Checker {
+	return checker(compareBytes)
+}
+
+// Lines is like Exact, but whitespace at the end of lines and blank lines at
+// the end of output are ignored.
+func Lines() Checker {
+	return checker(func(output, answer io.ByteReader) (*Result, error) {
+		return compareBytes(&trimReader{r: output}, &trimReader{r: answer})
+	})
+}
+
+// Tokens compares tokens split by whitespace.
+func Tokens() Checker {
+	return checker(func(output, answer io.ByteReader) (*Result, error) {
+		return compareTokens(output, answer, tokenEqual)
+	})
+}
+
+// CaseInsensitive compares tokens split by whitespace, the case of letters is ignored.
+func CaseInsensitive() Checker {
+	return checker(func(output, answer io.ByteReader) (*Result, error) {
+		return compareTokens(output, answer, tokenEqualFold)
+	})
+}
+
+// Float compares tokens split by whitespace, if both tokens are numbers, they
+// are the same when the absolute error is not greater than abs, or the relative
+// error is not greater than rel.
+func Float(abs, rel float64) Checker {
+	equal := floatEqual(abs, rel)
+	return checker(func(output, answer io.ByteReader) (*Result, error) {
+		return compareTokens(output, answer, equal)
+	})
+}
+
+// Parse returns the checker by spec, spec is a name in CHECKER_STR, float can
+// set epsilon like `float:1e-4` for both errors, or `float:1e-4,1e-9` for absolute
+// and relative error. Default epsilon is DEFAULT_EPSILON.
+func Parse(spec string) (Checker, error) {
+	name, arg := spec, ""
+	if i := strings.Index(spec, ":"); i >= 0 {
+		name, arg = spec[:i], spec[i+1:]
+	}
+	if name != CHECKER_FLOAT && arg != "" {
+		return nil, fmt.Errorf("%v: %s", ErrUnknownChecker, spec)
+	}
+
+	switch name {
+	case CHECKER_EXACT:
+		return Exact(), nil
+	case CHECKER_LINES:
+		return Lines(), nil
+	case CHECKER_TOKENS:
+		return Tokens(), nil
+	case CHECKER_NOCASE:
+		return CaseInsensitive(), nil
+	case CHECKER_FLOAT:
+		abs, rel := DEFAULT_EPSILON, DEFAULT_EPSILON
+		if arg != "" {
+			eps := strings.Split(arg, ",")
+			if len(eps) > 2 {
+				return nil, fmt.Errorf("%v: %s", ErrUnknownChecker, spec)
+			}
+			var err error
+			if abs, err = strconv.ParseFloat(eps[0], 64); err != nil || abs < 0 {
+				return nil, fmt.Errorf("%v: bad epsilon %s", ErrUnknownChecker, eps[0])
+			}
+			rel = abs
+			if len(eps) == 2 {
+				if rel, err = strconv.ParseFloat(eps[1], 64); err != nil || rel < 0 {
+					return nil, fmt.Errorf("%v: bad epsilon %s", ErrUnknownChecker, eps[1])
+				}
+			}
+		}
+		return Float(abs, rel), nil
+	}
+	return nil, fmt.Errorf("%v: %s", ErrUnknownChecker, spec)
+}
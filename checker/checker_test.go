@@ -0,0 +1,133 @@
+// +build linux
+
+package checker
+
+import (
+	"github.com/sdibtacm/sandbox/exec"
+	"io"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestCheckers(t *testing.T) {
+	ok, wa, pe := exec.VERDICT_OK, exec.VERDICT_WRONG_ANSWER, exec.VERDICT_PRESENTATION_ERROR
+	for _, tt := range []struct {
+		checker string
+		output  string
+		answer  string
+		verdict int
+		message string
+	}{
+		{"exact", "1 2\n3\n", "1 2\n3\n", ok, ""},
+		{"exact", "1 2 \n3\n", "1 2\n3\n", pe, `line 1 column 4: expected "1 2", got "1 2 "`},
+		{"exact", "1 2\n3", "1 2\n3\n", pe, `line 2 column 2: expected "3", got "3"`},
+		{"exact", "1 2\n4\n", "1 2\n3\n", wa, `line 2 column 1: expected "3", got "4"`},
+		{"exact", "12 3\n", "1 23\n", wa, `line 1 column 2: expected "1 23", got "12 3"`},
+		{"exact", "ab c\n", "abc\n", wa, `line 1 column 3: expected "abc", got "ab c"`},
+		{"lines", "1 2  \r\n3\n\n\n", "1 2\n3", ok, ""},
+		{"lines", "1  2\n3\n", "1 2\n3\n", pe, `line 1 column 3: expected "1 2", got "1  2"`},
+		{"lines", "1 2\n\n3\n", "1 2\n3\n", pe, `line 2 column 1: expected "3", got ""`},
+		{"lines", "1\t 2\n", "1\t 2 \n", ok, ""},
+		{"lines", "1 2\n", "1 2\n3\n", wa, `line 1 column 4: expected "1 2", got "1 2"`},
+		{"tokens", " 1\n\n2\t3 ", "1 2 3\n", ok, ""},
+		{"tokens", "1 2\n 4", "1 2 3\n", wa, `token 3 at line 2 column 2: expected "3", got "4"`},
+		{"tokens", "1 2", "1 2 3\n", wa, `token 3 at line 1 column 4: expected "3", got EOF`},
+		{"tokens", "1 2 3 4", "1 2 3\n", wa, `token 4 at line 1 column 7: expected EOF, got "4"`},
+		{"nocase", "YES\nno", "yes\nNo\n", ok, ""},
+		{"nocase", "x" + strings.Repeat("é", CHUNK_SIZE), "X" + strings.Repeat("É", CHUNK_SIZE), ok, ""},
+		{"nocase", "yes", "no", wa, `token 1 at line 1 column 1: expected "no", got "yes"`},
+		{"float", "1.0000001 nan -inf 1e9", "1 nan -inf 1000000001", ok, ""},
+		{"float", "1.001", "1", wa, `token 1 at line 1 column 1: expected "1", got "1.001"`},
+		{"float:0.01", "1.001 abc", "1 abc", ok, ""},
+		{"float:0,0.01", "1000001 0.0001", "1000000 0", wa, `token 2 at line 1 column 9: expected "0", got "0.0001"`},
+	} {
+		c, err := Parse(tt.checker)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r, err := c.Compare(strings.NewReader(tt.output), strings.NewReader(tt.answer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Verdict != tt.verdict || r.Message() != tt.message {
+			t.Errorf("%s(%q, %q) = %s, %q, want %s, %q", tt.checker, tt.output, tt.answer,
+				exec.VERDICT_STR[r.Verdict], r.Message(), exec.VERDICT_STR[tt.verdict], tt.message)
+		}
+	}
+
+	for _, spec := range []string{"diff", "exact:1", "float:a", "float:-1", "float:1,2,3"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) should fail", spec)
+		}
+	}
+}
+
+// repeatReader gives n copies of s without holding them in memory.
+type repeatReader struct {
+	s   string
+	n   int
+	off int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, io.EOF
+	}
+	k := copy(p, r.s[r.off:])
+	r.off += k
+	if r.off == len(r.s) {
+		r.off = 0
+		r.n--
+	}
+	return k, nil
+}
+
+func TestLargeOutput(t *testing.T) {
+	const n = 1 << 17
+	for _, name := range CHECKER_STR {
+		c, _ := Parse(name)
+		r, err := c.Compare(&repeatReader{s: "1 2 3\n", n: n}, &repeatReader{s: "1 2 3\n", n: n})
+		if err != nil || r.Verdict != exec.VERDICT_OK {
+			t.Errorf("%s: %+v, %v, want ok", name, r, err)
+		}
+	}
+
+	r, err := Tokens().Compare(&repeatReader{s: "1 2 3\n", n: n}, &repeatReader{s: "1 2 3\n", n: n + 1})
+	if err != nil || r.Verdict != exec.VERDICT_WRONG_ANSWER || r.Token != 3*n+1 || r.Line != n+1 {
+		t.Errorf("tokens: %+v, %v, want wrong answer at token %d", r, err, 3*n+1)
+	}
+}
+
+func TestLongToken(t *testing.T) {
+	const n = 1 << 10
+	s := strings.Repeat("a", 1<<12)
+	for _, name := range CHECKER_STR {
+		c, _ := Parse(name)
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		r, err := c.Compare(&repeatReader{s: s, n: n}, &repeatReader{s: s, n: n})
+		runtime.ReadMemStats(&after)
+		if err != nil || r.Verdict != exec.VERDICT_OK {
+			t.Errorf("%s: %+v, %v, want ok", name, r, err)
+		}
+		// the token is 4M
+		if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+			t.Errorf("%s: allocated %d bytes", name, alloc)
+		}
+
+		r, err = c.Compare(strings.NewReader("1 "+strings.Repeat("a", 3*CHUNK_SIZE)+"b"),
+			strings.NewReader("1 "+strings.Repeat("a", 3*CHUNK_SIZE)+"c"))
+		want := exec.VERDICT_WRONG_ANSWER
+		if err != nil || r.Verdict != want || r.Token > 0 && r.Expected != strings.Repeat("a", 2*CONTEXT_SIZE)+"..." {
+			t.Errorf("%s: %+v, %v, want %s", name, r, err, exec.VERDICT_STR[want])
+		}
+	}
+
+	// whitespace at the end of a line is counted
+	r, err := Lines().Compare(io.MultiReader(strings.NewReader("1"), &repeatReader{s: strings.Repeat(" ", 1<<12), n: n},
+		strings.NewReader("\n")), strings.NewReader("1\n"))
+	if err != nil || r.Verdict != exec.VERDICT_OK {
+		t.Errorf("lines: %+v, %v, want ok", r, err)
+	}
+}
@@ -0,0 +1,370 @@
+// +build linux
+
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/sdibtacm/sandbox/exec"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+const (
+	BUFFER_SIZE  = 64 * 1024
+	CONTEXT_SIZE = 16   // bytes of context before and after the differing position
+	CHUNK_SIZE   = 4096 // long tokens are compared by chunks of it
+)
+
+func newReader(r io.Reader) io.ByteReader {
+	if br, ok := r.(io.ByteReader); ok {
+		return br
+	}
+	return bufio.NewReaderSize(r, BUFFER_SIZE)
+}
+
+func isSpace(b byte) bool {
+	switch b {
+	case ' ', '\t', '\n', '\r', '\v', '\f':
+		return true
+	}
+	return false
+}
+
+// compareBytes compares byte by byte, when they differ, the rest are compared by
+// token to tell presentation error from wrong answer.
+func compareBytes(output, answer io.ByteReader) (*Result, error) {
+	line, col := 1, 1
+	var before []byte // the line before the position, at most CONTEXT_SIZE bytes
+	var token []byte  // the last byte of the token before the position
+	for {
+		ob, oerr := output.ReadByte()
+		ab, aerr := answer.ReadByte()
+		if oerr != nil && oerr != io.EOF {
+			return nil, oerr
+		}
+		if aerr != nil && aerr != io.EOF {
+			return nil, aerr
+		}
+		if oerr == io.EOF && aerr == io.EOF {
+			return &Result{Verdict: exec.VERDICT_OK}, nil
+		}
+
+		if oerr == nil && aerr == nil && ob == ab {
+			if ob == '\n' {
+				line, col = line+1, 1
+				before = before[:0]
+			} else {
+				col++
+				if len(before) == CONTEXT_SIZE {
+					copy(before, before[1:])
+					before = before[:CONTEXT_SIZE-1]
+				}
+				before = append(before, ob)
+			}
+			if isSpace(ob) {
+				token = token[:0]
+			} else {
+				token = append(token[:0], ob)
+			}
+			continue
+		}
+
+		var oAhead, aAhead []byte
+		if oerr == nil {
+			oAhead = readAhead(output, ob)
+		}
+		if aerr == nil {
+			aAhead = readAhead(answer, ab)
+		}
+		r := &Result{
+			Verdict:  exec.VERDICT_WRONG_ANSWER,
+			Line:     line,
+			Column:   col,
+			Expected: string(before) + string(lineOf(aAhead)),
+			Got:      string(before) + string(lineOf(oAhead)),
+		}
+
+		// bytes before the position are the same, so only the rest need to be compared,
+		// the last byte of the token keeps the rest of it a part of the token
+		oRest := &prefixReader{prefix: append(append([]byte(nil), token...), oAhead...), r: output}
+		aRest := &prefixReader{prefix: append(append([]byte(nil), token...), aAhead...), r: answer}
+		same, err := compareTokens(oRest, aRest, tokenEqual)
+		if err != nil {
+			return nil, err
+		}
+		if same.Verdict == exec.VERDICT_OK {
+			r.Verdict = exec.VERDICT_PRESENTATION_ERROR
+		}
+		return r, nil
+	}
+}
+
+// readAhead reads at most CONTEXT_SIZE bytes start with first, stop after newline.
+func readAhead(r io.ByteReader, first byte) []byte {
+	ahead := []byte{first}
+	for first != '\n' && len(ahead) < CONTEXT_SIZE {
+		b, err := r.ReadByte()
+		if err != nil {
+			break
+		}
+		ahead = append(ahead, b)
+		if b == '\n' {
+			break
+		}
+	}
+	return ahead
+}
+
+func lineOf(b []byte) []byte {
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
+
+type prefixReader struct {
+	prefix []byte
+	r      io.ByteReader
+}
+
+func (p *prefixReader) ReadByte() (byte, error) {
+	if len(p.prefix) > 0 {
+		b := p.prefix[0]
+		p.prefix = p.prefix[1:]
+		return b, nil
+	}
+	return p.r.ReadByte()
+}
+
+// trimReader drops whitespace at the end of lines and blank lines at the end,
+// only the count of whitespace of the current line and newlines are kept, the
+// whitespace is output as its first byte, so a line mixing them is compared by
+// the count.
+type trimReader struct {
+	r        io.ByteReader
+	newlines int  // newlines not sure to output yet
+	spaces   int  // whitespace after the last newline, not sure to output yet
+	space    byte // the first of them
+	flush    bool // a byte after them is read, they must be output
+	next     byte
+	err      error
+}
+
+func (t *trimReader) ReadByte() (byte, error) {
+	for !t.flush {
+		if t.err != nil {
+			return 0, t.err
+		}
+		b, err := t.r.ReadByte()
+		if err != nil {
+			t.err = err
+			continue
+		}
+		switch {
+		case b == '\n':
+			t.newlines++
+			t.spaces = 0
+		case isSpace(b):
+			if t.spaces == 0 {
+				t.space = b
+			}
+			t.spaces++
+		default:
+			t.flush, t.next = true, b
+		}
+	}
+
+	if t.newlines > 0 {
+		t.newlines--
+		return '\n', nil
+	}
+	if t.spaces > 0 {
+		t.spaces--
+		return t.space, nil
+	}
+	t.flush = false
+	return t.next, nil
+}
+
+type tokenReader struct {
+	r         io.ByteReader
+	line, col int // position of the next byte
+	buf       []byte
+	tail      []byte // an incomplete rune at the end of the last chunk
+	more      bool   // the current token has bytes after the last chunk
+}
+
+func newTokenReader(r io.ByteReader) *tokenReader {
+	return &tokenReader{r: r, line: 1, col: 1, buf: make([]byte, 0, CHUNK_SIZE), tail: make([]byte, 0, utf8.UTFMax)}
+}
+
+func (t *tokenReader) advance(b byte) {
+	if b == '\n' {
+		t.line, t.col = t.line+1, 1
+	} else {
+		t.col++
+	}
+}
+
+// next skips whitespace to the next token and returns where it starts, io.EOF
+// if no token left. The token is read by chunk.
+func (t *tokenReader) next() (line, col int, err error) {
+	for {
+		b, err := t.r.ReadByte()
+		if err != nil {
+			return t.line, t.col, err
+		}
+		if !isSpace(b) {
+			line, col = t.line, t.col
+			t.advance(b)
+			t.tail, t.more = append(t.tail[:0], b), true
+			return line, col, nil
+		}
+		t.advance(b)
+	}
+}
+
+// chunk returns the next at most CHUNK_SIZE bytes of the token, it ends with a
+// complete rune unless the token does. The chunk is valid until the next call.
+func (t *tokenReader) chunk() ([]byte, error) {
+	t.buf = append(t.buf[:0], t.tail...)
+	t.tail = t.tail[:0]
+	for t.more && len(t.buf) < CHUNK_SIZE {
+		b, err := t.r.ReadByte()
+		if err == io.EOF {
+			t.more = false
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		t.advance(b)
+		if isSpace(b) {
+			t.more = false
+			break
+		}
+		t.buf = append(t.buf, b)
+	}
+	if t.more {
+		for i := len(t.buf) - 1; i >= 0 && i >= len(t.buf)-utf8.UTFMax; i-- {
+			if utf8.RuneStart(t.buf[i]) {
+				if !utf8.FullRune(t.buf[i:]) {
+					t.tail = append(t.tail, t.buf[i:]...)
+					t.buf = t.buf[:i]
+				}
+				break
+			}
+		}
+	}
+	return t.buf, nil
+}
+
+// compareTokens compares tokens by equal, a token longer than CHUNK_SIZE is
+// compared chunk by chunk.
+func compareTokens(output, answer io.ByteReader, equal func(got, want []byte, whole bool) bool) (*Result, error) {
+	ot, at := newTokenReader(output), newTokenReader(answer)
+	// heads of the tokens for the result, the first chunk is longer unless the
+	// token ends, one more byte than shorten keeps
+	gotHead, wantHead := make([]byte, 0, 2*CONTEXT_SIZE+1), make([]byte, 0, 2*CONTEXT_SIZE+1)
+	for n := 1; ; n++ {
+		line, col, oerr := ot.next()
+		_, _, aerr := at.next()
+		if oerr != nil && oerr != io.EOF {
+			return nil, oerr
+		}
+		if aerr != nil && aerr != io.EOF {
+			return nil, aerr
+		}
+		if oerr == io.EOF && aerr == io.EOF {
+			return &Result{Verdict: exec.VERDICT_OK}, nil
+		}
+
+		same := oerr == nil && aerr == nil
+		gotHead, wantHead = gotHead[:0], wantHead[:0]
+		for first := true; ; first = false {
+			var got, want []byte
+			if oerr == nil {
+				if got, oerr = ot.chunk(); oerr != nil {
+					return nil, oerr
+				}
+			}
+			if aerr == nil {
+				if want, aerr = at.chunk(); aerr != nil {
+					return nil, aerr
+				}
+			}
+			gotHead = appendHead(gotHead, got)
+			wantHead = appendHead(wantHead, want)
+			if same {
+				same = equal(got, want, first && !ot.more && !at.more) && ot.more == at.more
+			}
+			if !same || !ot.more && !at.more {
+				break
+			}
+		}
+		if !same {
+			return &Result{
+				Verdict:  exec.VERDICT_WRONG_ANSWER,
+				Line:     line,
+				Column:   col,
+				Token:    n,
+				Expected: shorten(wantHead),
+				Got:      shorten(gotHead),
+			}, nil
+		}
+	}
+}
+
+func appendHead(head, b []byte) []byte {
+	if n := cap(head) - len(head); len(b) > n {
+		b = b[:n]
+	}
+	return append(head, b...)
+}
+
+func shorten(b []byte) string {
+	if len(b) <= 2*CONTEXT_SIZE {
+		return string(b)
+	}
+	return string(b[:2*CONTEXT_SIZE]) + "..."
+}
+
+// equal functions compare chunks of tokens, whole is false if they are not
+// the whole tokens.
+
+func tokenEqual(got, want []byte, whole bool) bool {
+	return bytes.Equal(got, want)
+}
+
+func tokenEqualFold(got, want []byte, whole bool) bool {
+	return bytes.EqualFold(got, want)
+}
+
+func floatEqual(abs, rel float64) func(got, want []byte, whole bool) bool {
+	return func(got, want []byte, whole bool) bool {
+		if bytes.Equal(got, want) {
+			return true
+		}
+		if !whole {
+			return false
+		}
+		g, err := strconv.ParseFloat(string(got), 64)
+		if err != nil {
+			return false
+		}
+		w, err := strconv.ParseFloat(string(want), 64)
+		if err != nil {
+			return false
+		}
+		if math.IsNaN(g) || math.IsNaN(w) {
+			return math.IsNaN(g) && math.IsNaN(w)
+		}
+		if math.IsInf(g, 0) || math.IsInf(w, 0) {
+			return g == w
+		}
+		d := math.Abs(g - w)
+		return d <= abs || d <= rel*math.Abs(w)
+	}
+}
@@ -0,0 +1,417 @@
+// +build linux
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var ErrUnknownConfigProfile = errors.New("config profile not found")
+var ErrUnknownRlimit = errors.New("unknown rlimit")
+var ErrUnsupportedConfigFormat = errors.New("unsupported config format, only JSON (.json), YAML (.yaml, .yml) and TOML (.toml) are supported")
+
+var (
+	cmdConfigPath    string
+	cmdConfigProfile string
+)
+
+// names of rlimit in config, in order of exec.RLIMIT_*
+var rlimitNames = [...]string{"cpu", "fsize", "data", "stack", "core", "rss", "nproc", "nofile", "memlock", "as",
+	"locks", "sigpending", "msgqueue", "nice", "rtprio", "rttime", "nlimits"}
+
+// Config is the content of config file given by --config, it is JSON like
+//
+//	{
+//	    "resource": {"cpu_time": 1000, "memory": "256m"},
+//	    "syscall": {"level": 3},
+//	    "profiles": {"java": {"resource": {"thread": 64}}}
+//	}
+//
+// or the same in YAML or TOML, like
+//
+//	[resource]
+//	cpu_time = 1000
+//	memory = "256m"
+//
+//	[profiles.java.resource]
+//	thread = 64
+//
+// Every field is the same as a flag, flags in command line override it.
+// Fields of a profile chosen by --config-profile override the top level ones.
+type Config struct {
+	Resource   ResourceConfig    `json:"resource"`
+	Rlimit     map[string]uint64 `json:"rlimit,omitempty"`
+	Syscall    SyscallConfig     `json:"syscall"`
+	IO         IOConfig          `json:"io"`
+	Env        []string          `json:"env,omitempty"`
+	EnvFiles   []string          `json:"env_files,omitempty"`
+	EnvPass    []string          `json:"env_pass,omitempty"`
+	UseHostEnv *bool             `json:"use_host_env,omitempty"`
+	Chroot     *string           `json:"chroot,omitempty"`
+	Chdir      *string           `json:"chdir,omitempty"`
+	Credential CredentialConfig  `json:"credential"`
+	CPUSet     *string           `json:"cpu_set,omitempty"`
+	Kill       KillConfig        `json:"kill"`
+
+	Profiles map[string]json.RawMessage `json:"profiles,omitempty"`
+}
+
+type ResourceConfig struct {
+	CpuTime   *uint   `json:"cpu_time,omitempty"`   // ms
+	ClockTime *uint   `json:"clock_time,omitempty"` // ms
+	Memory    *string `json:"memory,omitempty"`     // bytes with suffix like 256m
+	Output    *string `json:"output,omitempty"`
+	Thread    *uint   `json:"thread,omitempty"`
+}
+
+type SyscallConfig struct {
+	Level            *int     `json:"level,omitempty"`
+	Helper           *string  `json:"helper,omitempty"`
+	NoNewPrivs       *bool    `json:"no_new_privs,omitempty"`
+	DefaultAction    *int     `json:"default_action,omitempty"`
+	BadSyscallAction *int     `json:"bad_syscall_action,omitempty"`
+	Arches           []string `json:"arches,omitempty"`
+	BadArchAction    *int     `json:"bad_arch_action,omitempty"`
+}
+
+type IOConfig struct {
+	Input   *string  `json:"input,omitempty"`
+	Output  *string  `json:"output,omitempty"`
+	Error   *string  `json:"error,omitempty"`
+	Fds     []string `json:"fds,omitempty"` // like 3=path:r
+	Tty     *bool    `json:"tty,omitempty"`
+	TtySize *string  `json:"tty_size,omitempty"`
+}
+
+type CredentialConfig struct {
+	Uid   *int  `json:"uid,omitempty"`
+	Gid   *int  `json:"gid,omitempty"`
+	Umask *uint `json:"umask,omitempty"`
+}
+
+type KillConfig struct {
+	Signal *string `json:"signal,omitempty"`
+	Grace  *uint   `json:"grace,omitempty"` // ms
+	Tree   *bool   `json:"tree,omitempty"`
+}
+
+// configField binds a field of Config to a flag
+type configField struct {
+	flag string
+	// get returns values to set to the flag, nil if the field is not set
+	get func() []string
+	// load sets the field by value of the flag
+	load func(c *cobra.Command) error
+}
+
+func stringField(flag string, p **string) configField {
+	return configField{
+		flag: flag,
+		get: func() []string {
+			if *p == nil {
+				return nil
+			}
+			return []string{**p}
+		},
+		load: func(c *cobra.Command) error {
+			v, err := c.Flags().GetString(flag)
+			*p = &v
+			return err
+		},
+	}
+}
+
+func uintField(flag string, p **uint) configField {
+	return configField{
+		flag: flag,
+		get: func() []string {
+			if *p == nil {
+				return nil
+			}
+			return []string{strconv.FormatUint(uint64(**p), 10)}
+		},
+		load: func(c *cobra.Command) error {
+			v, err := c.Flags().GetUint(flag)
+			*p = &v
+			return err
+		},
+	}
+}
+
+func intField(flag string, p **int) configField {
+	return configField{
+		flag: flag,
+		get: func() []string {
+			if *p == nil {
+				return nil
+			}
+			return []string{strconv.Itoa(**p)}
+		},
+		load: func(c *cobra.Command) error {
+			v, err := c.Flags().GetInt(flag)
+			*p = &v
+			return err
+		},
+	}
+}
+
+func boolField(flag string, p **bool) configField {
+	return configField{
+		flag: flag,
+		get: func() []string {
+			if *p == nil {
+				return nil
+			}
+			return []string{strconv.FormatBool(**p)}
+		},
+		load: func(c *cobra.Command) error {
+			v, err := c.Flags().GetBool(flag)
+			*p = &v
+			return err
+		},
+	}
+}
+
+// listField binds a repeatable flag, every value is set to the flag
+func listField(flag string, p *[]string) configField {
+	return configField{
+		flag: flag,
+		get:  func() []string { return *p },
+		load: func(c *cobra.Command) (err error) {
+			*p, err = c.Flags().GetStringArray(flag)
+			return
+		},
+	}
+}
+
+func (cfg *Config) fields() []configField {
+	fields := []configField{
+		uintField("max-cpu-time", &cfg.Resource.CpuTime),
+		uintField("max-time", &cfg.Resource.ClockTime),
+		stringField("max-memory", &cfg.Resource.Memory),
+		stringField("max-output", &cfg.Resource.Output),
+		uintField("max-thread", &cfg.Resource.Thread),
+
+		intField("syscall-limit-level", &cfg.Syscall.Level),
+		stringField("syscall", &cfg.Syscall.Helper),
+		boolField("no-new-privs", &cfg.Syscall.NoNewPrivs),
+		intField("syscall-default-action", &cfg.Syscall.DefaultAction),
+		intField("syscall-bad-syscall-action", &cfg.Syscall.BadSyscallAction),
+		listField("syscall-arch", &cfg.Syscall.Arches),
+		intField("syscall-bad-arch-action", &cfg.Syscall.BadArchAction),
+
+		stringField("input-path", &cfg.IO.Input),
+		stringField("output-path", &cfg.IO.Output),
+		stringField("error-path", &cfg.IO.Error),
+		boolField("tty", &cfg.IO.Tty),
+		stringField("tty-size", &cfg.IO.TtySize),
+		listField("fd", &cfg.IO.Fds),
+
+		listField("env", &cfg.Env),
+		listField("env-file", &cfg.EnvFiles),
+		listField("env-pass", &cfg.EnvPass),
+		boolField("use-host-env", &cfg.UseHostEnv),
+		stringField("chroot", &cfg.Chroot),
+		stringField("chdir", &cfg.Chdir),
+
+		intField("uid", &cfg.Credential.Uid),
+		intField("gid", &cfg.Credential.Gid),
+		uintField("umask", &cfg.Credential.Umask),
+		stringField("cpu-set", &cfg.CPUSet),
+
+		stringField("kill-signal", &cfg.Kill.Signal),
+		uintField("kill-grace", &cfg.Kill.Grace),
+		boolField("kill-tree", &cfg.Kill.Tree),
+	}
+
+	for _, name := range rlimitNames {
+		name := name
+		fields = append(fields, configField{
+			flag: rlimitFlag(name),
+			get: func() []string {
+				if v, ok := cfg.Rlimit[name]; ok {
+					return []string{strconv.FormatUint(v, 10)}
+				}
+				return nil
+			},
+			load: func(c *cobra.Command) error {
+				v, err := c.Flags().GetUint64(rlimitFlag(name))
+				if err == nil && v != exec.RLIMIT_UNRESOURCE {
+					if cfg.Rlimit == nil {
+						cfg.Rlimit = map[string]uint64{}
+					}
+					cfg.Rlimit[name] = v
+				}
+				return err
+			},
+		})
+	}
+	return fields
+}
+
+func rlimitFlag(name string) string {
+	if name == "stack" {
+		return "rlimit-sstack"
+	}
+	return "rlimit-" + name
+}
+
+// loadConfig reads config file, and merges the profile into it if profile is not empty.
+// The format is decided by the extension of path, a path without extension is JSON.
+func loadConfig(path, profile string) (*Config, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	switch ext {
+	case ".json", "", ".yaml", ".yml", ".toml":
+	default:
+		return nil, fmt.Errorf("%s: %v: %s", path, ErrUnsupportedConfigFormat, ext)
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err = decodeConfig(b, ext, cfg); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	if profile != "" {
+		p, ok := cfg.Profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("%v: %s", ErrUnknownConfigProfile, profile)
+		}
+		// fields not in profile are kept, the profile is JSON after decoded
+		if err = decodeConfig(p, ".json", cfg); err != nil {
+			return nil, fmt.Errorf("%s: profile %s: %v", path, profile, err)
+		}
+	}
+	for name := range cfg.Rlimit {
+		if !isRlimitName(name) {
+			return nil, fmt.Errorf("%v: %s", ErrUnknownRlimit, name)
+		}
+	}
+	return cfg, nil
+}
+
+// decodeConfig decodes b in the format by ext, YAML and TOML are converted to
+// JSON first, so they are checked the same as JSON.
+func decodeConfig(b []byte, ext string, cfg *Config) error {
+	var v interface{}
+	var err error
+	switch ext {
+	case ".yaml", ".yml":
+		v, err = parseYaml(b)
+	case ".toml":
+		v, err = parseToml(b)
+	}
+	if err != nil {
+		return err
+	}
+	if v != nil {
+		if b, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(cfg)
+}
+
+func isRlimitName(name string) bool {
+	for _, n := range rlimitNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// apply sets flags of c by the config, flags set in command line are not changed.
+// Fields without flag in c are ignored, like io in judge.
+func (cfg *Config) apply(c *cobra.Command) error {
+	flags := c.Flags()
+	for _, f := range cfg.fields() {
+		if flags.Lookup(f.flag) == nil || flags.Changed(f.flag) {
+			continue
+		}
+		for _, v := range f.get() {
+			if err := flags.Set(f.flag, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyConfig applies config file given by --config to flags of c.
+func applyConfig(c *cobra.Command) error {
+	if cmdConfigPath == "" {
+		if cmdConfigProfile != "" {
+			return errors.New("--config-profile need --config")
+		}
+		return nil
+	}
+	cfg, err := loadConfig(cmdConfigPath, cmdConfigProfile)
+	if err != nil {
+		return err
+	}
+	return cfg.apply(c)
+}
+
+// effectiveConfig returns the config by values of flags of c.
+func effectiveConfig(c *cobra.Command) (*Config, error) {
+	cfg := &Config{}
+	for _, f := range cfg.fields() {
+		if c.Flags().Lookup(f.flag) == nil {
+			continue
+		}
+		if err := f.load(c); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show configuration of sandbox",
+}
+
+var configPrintCmd = &cobra.Command{
+	Use:   "print [--config file] [--config-profile name] [flags]",
+	Short: "Print the effective configuration merged from config file and flags",
+	Long: `Print the effective configuration merged from config file and flags.
+
+The config file is JSON, YAML or TOML by its extension, every field is the same as a flag,
+flags in command line override it.
+Named profiles can be put in "profiles" and chosen by --config-profile, fields of the profile
+override the top level ones. The output can be used as a config file.`,
+	Example:       "sandbox config print --config sandbox.json --config-profile java -t 2000",
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyConfig(cmd); err != nil {
+			return err
+		}
+		cfg, err := effectiveConfig(cmd)
+		if err != nil {
+			return err
+		}
+		return printJson(cfg)
+	},
+}
+
+func init() {
+	addIOFlags(configPrintCmd)
+	addRunFlags(configPrintCmd)
+	configCmd.AddCommand(configPrintCmd)
+	cmd.AddCommand(configCmd)
+}
@@ -0,0 +1,160 @@
+// +build linux
+
+package main
+
+import (
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testConfig = `{
+	"resource": {"cpu_time": 1000, "memory": "256m"},
+	"rlimit": {"stack": 1024},
+	"syscall": {"level": 2},
+	"env": ["PATH=/bin", "A=1"],
+	"io": {"fds": ["3=/dev/null:r"]},
+	"profiles": {"java": {"resource": {"thread": 64, "memory": "1g"}, "rlimit": {"nofile": 64}}}
+}`
+
+const testYamlConfig = `# the same as testConfig
+resource:
+  cpu_time: 1000
+  memory: 256m
+rlimit: {stack: 1024}
+syscall:
+  level: 2
+env:
+  - PATH=/bin
+  - "A=1"
+io:
+  fds: ['3=/dev/null:r']
+profiles:
+  java:
+    resource: {thread: 64, memory: 1g}
+    rlimit:
+      nofile: 64 # comment
+`
+
+const testTomlConfig = `# the same as testConfig
+env = ["PATH=/bin", 'A=1']
+
+[resource]
+cpu_time = 1_000
+memory = "256m"
+
+[rlimit]
+stack = 1024
+
+[syscall]
+level = 2
+
+[io]
+fds = [
+    "3=/dev/null:r", # comment
+]
+
+[profiles.java]
+resource = { thread = 64, memory = "1g" }
+rlimit.nofile = 64
+`
+
+func TestConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sandbox.json")
+	if err = ioutil.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &cobra.Command{}
+	addIOFlags(c)
+	addRunFlags(c)
+	if err = c.ParseFlags([]string{"--config", path, "--config-profile", "java", "-b", "2000", "--rlimit-nofile", "32"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = applyConfig(c); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := effectiveConfig(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *cfg.Resource.CpuTime != 2000 || *cfg.Resource.Memory != "1g" || *cfg.Resource.Thread != 64 {
+		t.Errorf("resource = %d, %s, %d, want 2000, 1g, 64", *cfg.Resource.CpuTime, *cfg.Resource.Memory, *cfg.Resource.Thread)
+	}
+	if want := map[string]uint64{"stack": 1024, "nofile": 32}; !reflect.DeepEqual(cfg.Rlimit, want) {
+		t.Errorf("rlimit = %v, want %v", cfg.Rlimit, want)
+	}
+	if *cfg.Syscall.Level != 2 || !reflect.DeepEqual(cfg.Env, []string{"PATH=/bin", "A=1"}) ||
+		!reflect.DeepEqual(cfg.IO.Fds, []string{"3=/dev/null:r"}) {
+		t.Errorf("level = %d, env = %v, fds = %v", *cfg.Syscall.Level, cfg.Env, cfg.IO.Fds)
+	}
+
+	if _, err = loadConfig(path, "cobol"); err == nil {
+		t.Error("load unknown profile should fail")
+	}
+	if err = ioutil.WriteFile(path, []byte(`{"rlimit": {"stak": 1}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = loadConfig(path, ""); err == nil {
+		t.Error("load unknown rlimit should fail")
+	}
+
+	// the same config in YAML and TOML
+	if err = ioutil.WriteFile(path, []byte(testConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want, err := loadConfig(path, "java")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want.Profiles = nil
+	for name, content := range map[string]string{
+		"sandbox.yaml": testYamlConfig,
+		"sandbox.yml":  testYamlConfig,
+		"sandbox.toml": testTomlConfig,
+	} {
+		path = filepath.Join(dir, name)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig(path, "java")
+		if err != nil {
+			t.Errorf("load %s: %v", name, err)
+			continue
+		}
+		if cfg.Profiles = nil; !reflect.DeepEqual(cfg, want) {
+			t.Errorf("load %s: config = %+v, want %+v", name, cfg, want)
+		}
+	}
+
+	for name, content := range map[string]string{
+		"bad.yaml":     "resource:\n  cpu_time: 1000\n   memory: 1m\n",
+		"unknown.yaml": "resource:\n  cpu_tim: 1000\n",
+		"type.yaml":    "resource:\n  cpu_time: fast\n",
+		"bad.toml":     "[resource]\ncpu_time = 1000\nmemory = 256m\n",
+		"dup.toml":     "[resource]\ncpu_time = 1000\n[resource]\n",
+		"unknown.toml": "[resource]\ncpu_tim = 1000\n",
+		"sandbox.ini":  "[resource]\ncpu_time = 1000\n",
+	} {
+		path = filepath.Join(dir, name)
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = loadConfig(path, ""); err == nil {
+			t.Errorf("load %s should fail", name)
+		}
+	}
+	if _, err = loadConfig(filepath.Join(dir, "sandbox.ini"), ""); err == nil || !strings.Contains(err.Error(), ErrUnsupportedConfigFormat.Error()) {
+		t.Errorf("load sandbox.ini: err = %v, want %v", err, ErrUnsupportedConfigFormat)
+	}
+}
@@ -0,0 +1,587 @@
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// YAML and TOML config files are parsed into maps, slices and scalars, then
+// they are encoded as JSON and decoded as a JSON config. Only the common parts
+// of both formats are supported, enough for Config.
+
+var ErrBadConfigSyntax = errors.New("bad config syntax")
+
+func configSyntaxError(line int, format string, a ...interface{}) error {
+	return fmt.Errorf("%v, line %d: %s", ErrBadConfigSyntax, line, fmt.Sprintf(format, a...))
+}
+
+// parseScalar parses a number, a bool or null, ok is false for other strings.
+func parseScalar(s string) (v interface{}, ok bool) {
+	switch s {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	num := strings.Replace(s, "_", "", -1)
+	sign := ""
+	if strings.HasPrefix(num, "-") || strings.HasPrefix(num, "+") {
+		sign, num = num[:1], num[1:]
+	}
+	for prefix, base := range map[string]int{"0x": 16, "0o": 8, "0b": 2} {
+		if strings.HasPrefix(num, prefix) {
+			if n, err := strconv.ParseInt(sign+num[2:], base, 64); err == nil {
+				return n, true
+			}
+			return nil, false
+		}
+	}
+	if n, err := strconv.ParseInt(sign+num, 10, 64); err == nil {
+		return n, true
+	}
+	if f, err := strconv.ParseFloat(sign+num, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		return f, true
+	}
+	return nil, false
+}
+
+// stripComment removes a comment starts with # out of quotes, a # in a YAML
+// plain scalar must follow whitespace.
+func stripComment(line string, yaml bool) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch b := line[i]; {
+		case quote != 0:
+			if b == '\\' && quote == '"' {
+				i++
+			} else if b == quote {
+				quote = 0
+			}
+		case b == '"' || b == '\'':
+			quote = b
+		case b == '#' && (!yaml || i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote parses a quoted string at the start of s, returns the string and its
+// length in s.
+func unquote(s string) (string, int, error) {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quote == '"':
+			i++
+		case s[i] == quote && quote == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			// '' is a quote in YAML
+			i++
+		case s[i] == quote:
+			if quote == '\'' {
+				return strings.Replace(s[1:i], "''", "'", -1), i + 1, nil
+			}
+			v, err := strconv.Unquote(s[:i+1])
+			return v, i + 1, err
+		}
+	}
+	return "", 0, errors.New("unterminated string")
+}
+
+type yamlLine struct {
+	n      int // line number
+	indent int
+	text   string // without indent and comment
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	i     int
+}
+
+// parseYaml parses block mappings and sequences, flow collections and scalars of YAML.
+func parseYaml(b []byte) (interface{}, error) {
+	p := &yamlParser{}
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimRight(stripComment(line, true), " \t\r")
+		text := strings.TrimLeft(line, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if text[0] == '\t' {
+			return nil, configSyntaxError(i+1, "tab in indentation")
+		}
+		p.lines = append(p.lines, yamlLine{n: i + 1, indent: len(line) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	v, err := p.block(p.lines[0].indent)
+	if err == nil && p.i < len(p.lines) {
+		err = configSyntaxError(p.lines[p.i].n, "bad indentation")
+	}
+	return v, err
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) block(indent int) (interface{}, error) {
+	if isSequenceItem(p.lines[p.i].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+// nested parses the block after a line ends with `:` or `-`, a sequence of a
+// mapping can be at the same indent of the key.
+func (p *yamlParser) nested(indent int) (interface{}, error) {
+	if p.i < len(p.lines) {
+		if next := p.lines[p.i]; next.indent > indent || next.indent == indent && isSequenceItem(next.text) {
+			return p.block(next.indent)
+		}
+	}
+	return nil, nil
+}
+
+func (p *yamlParser) sequence(indent int) (interface{}, error) {
+	list := []interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent && isSequenceItem(p.lines[p.i].text) {
+		line := p.lines[p.i]
+		p.i++
+		var v interface{}
+		var err error
+		if item := strings.TrimSpace(line.text[1:]); item == "" {
+			if p.i < len(p.lines) && p.lines[p.i].indent > indent {
+				v, err = p.block(p.lines[p.i].indent)
+			}
+		} else {
+			v, err = yamlValue(item, line.n)
+		}
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+func (p *yamlParser) mapping(indent int) (interface{}, error) {
+	m := map[string]interface{}{}
+	for p.i < len(p.lines) && p.lines[p.i].indent == indent {
+		line := p.lines[p.i]
+		if isSequenceItem(line.text) {
+			break
+		}
+		key, value, err := splitYamlKey(line.text)
+		if err != nil {
+			return nil, configSyntaxError(line.n, "%v", err)
+		}
+		if _, ok := m[key]; ok {
+			return nil, configSyntaxError(line.n, "duplicate key %s", key)
+		}
+		p.i++
+		if value == "" {
+			m[key], err = p.nested(indent)
+		} else {
+			m[key], err = yamlValue(value, line.n)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// splitYamlKey splits `key: value`, the key may be quoted.
+func splitYamlKey(text string) (key, value string, err error) {
+	rest := text
+	if text[0] == '"' || text[0] == '\'' {
+		var n int
+		if key, n, err = unquote(text); err != nil {
+			return "", "", err
+		}
+		rest = strings.TrimLeft(text[n:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", errors.New("expect `key: value`")
+		}
+	} else {
+		i := strings.Index(text, ": ")
+		if i < 0 && strings.HasSuffix(text, ":") {
+			i = len(text) - 1
+		}
+		if i <= 0 {
+			return "", "", errors.New("expect `key: value`")
+		}
+		key, rest = strings.TrimSpace(text[:i]), text[i:]
+	}
+	return key, strings.TrimSpace(rest[1:]), nil
+}
+
+func yamlValue(s string, line int) (interface{}, error) {
+	switch s[0] {
+	case '|', '>':
+		return nil, configSyntaxError(line, "block scalar is not supported")
+	case '&', '*', '!':
+		return nil, configSyntaxError(line, "anchor, alias and tag are not supported")
+	case '[', '{':
+		f := &yamlFlow{s: s}
+		v, err := f.value()
+		if err == nil && strings.TrimSpace(f.s[f.i:]) != "" {
+			err = errors.New("unexpected " + f.s[f.i:])
+		}
+		if err != nil {
+			return nil, configSyntaxError(line, "%v", err)
+		}
+		return v, nil
+	case '"', '\'':
+		v, n, err := unquote(s)
+		if err == nil && n != len(s) {
+			err = errors.New("unexpected " + s[n:])
+		}
+		if err != nil {
+			return nil, configSyntaxError(line, "%v", err)
+		}
+		return v, nil
+	}
+	return yamlPlain(s), nil
+}
+
+func yamlPlain(s string) interface{} {
+	switch s {
+	case "~", "null", "Null", "NULL":
+		return nil
+	case "True", "TRUE":
+		return true
+	case "False", "FALSE":
+		return false
+	}
+	if v, ok := parseScalar(s); ok {
+		return v
+	}
+	return s
+}
+
+// yamlFlow parses flow collections like `[a, b]` and `{a: 1}` in a line.
+type yamlFlow struct {
+	s string
+	i int
+}
+
+func (f *yamlFlow) skip() {
+	for f.i < len(f.s) && (f.s[f.i] == ' ' || f.s[f.i] == '\t') {
+		f.i++
+	}
+}
+
+func (f *yamlFlow) value() (interface{}, error) {
+	f.skip()
+	if f.i == len(f.s) {
+		return nil, errors.New("unexpected end of line")
+	}
+	switch f.s[f.i] {
+	case '[':
+		f.i++
+		list := []interface{}{}
+		for {
+			if f.skip(); f.i < len(f.s) && f.s[f.i] == ']' {
+				f.i++
+				return list, nil
+			}
+			v, err := f.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if err = f.next(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.i++
+		m := map[string]interface{}{}
+		for {
+			if f.skip(); f.i < len(f.s) && f.s[f.i] == '}' {
+				f.i++
+				return m, nil
+			}
+			k, err := f.scalar(":,}")
+			if err != nil {
+				return nil, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			if f.skip(); f.i == len(f.s) || f.s[f.i] != ':' {
+				return nil, errors.New("expect `:` after " + key)
+			}
+			f.i++
+			if m[key], err = f.value(); err != nil {
+				return nil, err
+			}
+			if err = f.next('}'); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return f.scalar(",]}")
+}
+
+// next skips `,`, or stops before end.
+func (f *yamlFlow) next(end byte) error {
+	f.skip()
+	if f.i < len(f.s) && f.s[f.i] == ',' {
+		f.i++
+		return nil
+	}
+	if f.i < len(f.s) && f.s[f.i] == end {
+		return nil
+	}
+	return fmt.Errorf("expect `,` or `%c`", end)
+}
+
+func (f *yamlFlow) scalar(stops string) (interface{}, error) {
+	f.skip()
+	if f.i < len(f.s) && (f.s[f.i] == '"' || f.s[f.i] == '\'') {
+		v, n, err := unquote(f.s[f.i:])
+		f.i += n
+		return v, err
+	}
+	start := f.i
+	for f.i < len(f.s) && strings.IndexByte(stops, f.s[f.i]) < 0 {
+		f.i++
+	}
+	return yamlPlain(strings.TrimSpace(f.s[start:f.i])), nil
+}
+
+// tomlParser parses key/value pairs, tables, arrays and inline tables of TOML,
+// arrays of tables and dates are not supported.
+type tomlParser struct {
+	lines []string
+	n     int // line number of s
+	s     string
+	i     int
+}
+
+func parseToml(b []byte) (interface{}, error) {
+	root := map[string]interface{}{}
+	table := root
+	defined := map[string]bool{}
+	p := &tomlParser{lines: strings.Split(string(b), "\n")}
+	for p.nextLine() {
+		if p.skip(); p.end() {
+			continue
+		}
+		if strings.HasPrefix(p.s[p.i:], "[[") {
+			return nil, p.errorf("array of tables is not supported")
+		}
+		if p.s[p.i] == '[' {
+			p.i++
+			keys, err := p.key()
+			if err != nil {
+				return nil, err
+			}
+			if p.skip(); p.end() || p.s[p.i] != ']' {
+				return nil, p.errorf("expect `]`")
+			}
+			p.i++
+			name := strings.Join(keys, "\x00")
+			if defined[name] {
+				return nil, p.errorf("duplicate table %s", strings.Join(keys, "."))
+			}
+			defined[name] = true
+			if table, err = p.table(root, keys); err != nil {
+				return nil, err
+			}
+		} else if err := p.keyValue(table); err != nil {
+			return nil, err
+		}
+		if p.skip(); !p.end() {
+			return nil, p.errorf("unexpected %s", p.s[p.i:])
+		}
+	}
+	return root, nil
+}
+
+func (p *tomlParser) errorf(format string, a ...interface{}) error {
+	return configSyntaxError(p.n, format, a...)
+}
+
+func (p *tomlParser) nextLine() bool {
+	if p.n == len(p.lines) {
+		return false
+	}
+	p.s, p.i = strings.TrimRight(p.lines[p.n], "\r"), 0
+	p.n++
+	return true
+}
+
+func (p *tomlParser) skip() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+// end reports whether the rest of the line is empty or a comment.
+func (p *tomlParser) end() bool {
+	return p.i == len(p.s) || p.s[p.i] == '#'
+}
+
+// skipLines skips whitespace, comments and newlines in an array.
+func (p *tomlParser) skipLines() error {
+	for p.skip(); p.end(); p.skip() {
+		if !p.nextLine() {
+			return p.errorf("unterminated array")
+		}
+	}
+	return nil
+}
+
+// key parses a dotted key, parts may be quoted.
+func (p *tomlParser) key() ([]string, error) {
+	var keys []string
+	for {
+		p.skip()
+		if p.end() {
+			return nil, p.errorf("expect key")
+		}
+		if c := p.s[p.i]; c == '"' || c == '\'' {
+			k, n, err := unquote(p.s[p.i:])
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			keys, p.i = append(keys, k), p.i+n
+		} else {
+			start := p.i
+			for p.i < len(p.s) && isBareKey(p.s[p.i]) {
+				p.i++
+			}
+			if start == p.i {
+				return nil, p.errorf("expect key")
+			}
+			keys = append(keys, p.s[start:p.i])
+		}
+		if p.skip(); p.i == len(p.s) || p.s[p.i] != '.' {
+			return keys, nil
+		}
+		p.i++
+	}
+}
+
+func isBareKey(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// table returns the table of keys in root, tables on the way are created.
+func (p *tomlParser) table(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, k := range keys {
+		v, ok := root[k]
+		if !ok {
+			v = map[string]interface{}{}
+			root[k] = v
+		}
+		if root, ok = v.(map[string]interface{}); !ok {
+			return nil, p.errorf("%s is not a table", k)
+		}
+	}
+	return root, nil
+}
+
+func (p *tomlParser) keyValue(table map[string]interface{}) error {
+	keys, err := p.key()
+	if err != nil {
+		return err
+	}
+	if p.skip(); p.i == len(p.s) || p.s[p.i] != '=' {
+		return p.errorf("expect `=` after %s", strings.Join(keys, "."))
+	}
+	p.i++
+	if table, err = p.table(table, keys[:len(keys)-1]); err != nil {
+		return err
+	}
+	k := keys[len(keys)-1]
+	if _, ok := table[k]; ok {
+		return p.errorf("duplicate key %s", strings.Join(keys, "."))
+	}
+	table[k], err = p.value()
+	return err
+}
+
+func (p *tomlParser) value() (interface{}, error) {
+	if p.skip(); p.end() {
+		return nil, p.errorf("expect value")
+	}
+	switch c := p.s[p.i]; c {
+	case '"', '\'':
+		if strings.HasPrefix(p.s[p.i:], `"""`) || strings.HasPrefix(p.s[p.i:], "'''") {
+			return nil, p.errorf("multi-line string is not supported")
+		}
+		s, n, err := unquote(p.s[p.i:])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		p.i += n
+		return s, nil
+	case '[':
+		p.i++
+		list := []interface{}{}
+		for {
+			if err := p.skipLines(); err != nil {
+				return nil, err
+			}
+			if p.s[p.i] == ']' {
+				p.i++
+				return list, nil
+			}
+			v, err := p.value()
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+			if err = p.skipLines(); err != nil {
+				return nil, err
+			}
+			if p.s[p.i] == ',' {
+				p.i++
+			} else if p.s[p.i] != ']' {
+				return nil, p.errorf("expect `,` or `]`")
+			}
+		}
+	case '{':
+		p.i++
+		m := map[string]interface{}{}
+		for first := true; ; first = false {
+			if p.skip(); p.i < len(p.s) && p.s[p.i] == '}' && first {
+				p.i++
+				return m, nil
+			}
+			if err := p.keyValue(m); err != nil {
+				return nil, err
+			}
+			if p.skip(); p.i < len(p.s) && p.s[p.i] == '}' {
+				p.i++
+				return m, nil
+			}
+			if p.i == len(p.s) || p.s[p.i] != ',' {
+				return nil, p.errorf("expect `,` or `}`")
+			}
+			p.i++
+		}
+	}
+	start := p.i
+	for p.i < len(p.s) && (isBareKey(p.s[p.i]) || p.s[p.i] == '.' || p.s[p.i] == '+') {
+		p.i++
+	}
+	v, ok := parseScalar(p.s[start:p.i])
+	if !ok {
+		return nil, p.errorf("bad value %s", p.s[start:])
+	}
+	return v, nil
+}
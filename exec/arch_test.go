@@ -0,0 +1,188 @@
+// +build linux
+
+package exec
+
+import (
+	"debug/elf"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+// hand-written syscall stubs of amd64, they call getpid then exit 0
+var (
+	// getpid by syscall, then exit by syscall
+	stubNative = []byte{0xb8, 0x27, 0, 0, 0, 0x0f, 0x05, 0xb8, 0x3c, 0, 0, 0, 0x31, 0xff, 0x0f, 0x05}
+	// getpid of x86 by int 0x80 in 64-bit mode, then exit by int 0x80
+	stubInt80 = []byte{0xb8, 0x14, 0, 0, 0, 0xcd, 0x80, 0xb8, 0x01, 0, 0, 0, 0x31, 0xdb, 0xcd, 0x80}
+	// getpid of x32 by syscall with X32_SYSCALL_BIT, then exit by syscall
+	stubX32 = []byte{0xb8, 0x27, 0, 0, 0x40, 0x0f, 0x05, 0xb8, 0x3c, 0, 0, 0, 0x31, 0xff, 0x0f, 0x05}
+	// exit by syscall
+	stubExit = []byte{0xb8, 0x3c, 0, 0, 0, 0x31, 0xff, 0x0f, 0x05}
+	// clone a thread which calls getpid of x86 by int 0x80 then exits,
+	// the main thread calls pause forever
+	stubThreadInt80 = []byte{0xb8, 0x38, 0, 0, 0, 0xbf, 0, 0x0f, 0x01, 0, 0x48, 0x8d, 0xb4, 0x24, 0, 0xf0, 0xff, 0xff,
+		0x31, 0xd2, 0x45, 0x31, 0xd2, 0x45, 0x31, 0xc0, 0x0f, 0x05, 0x85, 0xc0, 0x74, 0x09,
+		0xb8, 0x22, 0, 0, 0, 0x0f, 0x05, 0xeb, 0xf7,
+		0xb8, 0x14, 0, 0, 0, 0xcd, 0x80, 0xb8, 0x3c, 0, 0, 0, 0x31, 0xff, 0x0f, 0x05}
+	// i386 code, mmap2 a page, fstat64 stdout into it, then exit_group by the result
+	stubI386Basic = []byte{0x31, 0xdb, 0xb9, 0, 0x10, 0, 0, 0xba, 0x03, 0, 0, 0, 0xbe, 0x22, 0, 0, 0,
+		0xbf, 0xff, 0xff, 0xff, 0xff, 0x31, 0xed, 0xb8, 0xc0, 0, 0, 0, 0xcd, 0x80,
+		0x89, 0xc1, 0xbb, 0x01, 0, 0, 0, 0xb8, 0xc5, 0, 0, 0, 0xcd, 0x80,
+		0x89, 0xc3, 0xb8, 0xfc, 0, 0, 0, 0xcd, 0x80}
+)
+
+// getpidFilter returns a filter which traces getpid and allows others.
+func getpidFilter(t *testing.T, helper *scmpFilter.ScmpFilterLoadHelper) *syscall.SockFprog {
+	scmp, err := scmpFilter.NewFilter(helper, seccomp.ActAllow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scmp.Release()
+	getpid, err := seccomp.GetSyscallFromName("getpid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = scmp.AddRule(getpid, seccomp.ActTrace); err != nil {
+		t.Fatal(err)
+	}
+	bpf, err := scmpFilter.ScmpToBPF(scmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bpf
+}
+
+func TestArchBypass(t *testing.T) {
+	if NativeArch() != "amd64" {
+		t.Skip("stubs are of amd64")
+	}
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestArchBypass")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string][]byte{
+		"native": buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", stubNative),
+		"int80":  buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", stubInt80),
+		"x32":    buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", stubX32),
+		"exit":   buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", stubExit),
+		"i386":   buildElf(elf.ELFCLASS32, elf.EM_386, "", stubInt80),
+		"thread": buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", stubThreadInt80),
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name    string
+		file    string
+		helper  scmpFilter.ScmpFilterLoadHelper
+		verdict int
+		syscall int
+		arch    string
+	}{
+		{"allowed", "exit", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_OK, -1, ""},
+		{"native", "native", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_BAD_SYSCALL, 39, "amd64"},
+		// the syscall is unknown if it is killed by seccomp
+		{"int 0x80 killed by bad arch", "int80", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_BAD_SYSCALL, -1, ""},
+		{"int 0x80 traced by bad arch", "int80", scmpFilter.ScmpFilterLoadHelper{BadArchAction: scmpFilter.DEFAULT_TRACE},
+			VERDICT_BAD_SYSCALL, 20, "x86"},
+		{"int 0x80 traced by translated rule", "int80", scmpFilter.ScmpFilterLoadHelper{Arches: []string{"x86"}},
+			VERDICT_BAD_SYSCALL, 20, "x86"},
+		{"x32 killed by bad arch", "x32", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_BAD_SYSCALL, -1, ""},
+		{"x32 traced by translated rule", "x32", scmpFilter.ScmpFilterLoadHelper{Arches: []string{"x32"}},
+			VERDICT_BAD_SYSCALL, 0x40000027, "x32"},
+		{"i386 killed by bad arch", "i386", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_BAD_SYSCALL, -1, ""},
+		// the whole process is killed, not only the thread
+		{"int 0x80 of a thread killed by bad arch", "thread", scmpFilter.ScmpFilterLoadHelper{}, VERDICT_BAD_SYSCALL, -1, ""},
+		{"i386 traced by translated rule", "i386", scmpFilter.ScmpFilterLoadHelper{Arches: []string{"x86"}},
+			VERDICT_BAD_SYSCALL, 20, "x86"},
+	} {
+		c := Command(filepath.Join(dir, tt.file))
+		c.Sys = &SysAttr{Ptrace: true, SetNoNewPrivs: true, Bpf: getpidFilter(t, &tt.helper)}
+		c.ResourceLimit.ClockTime = 5000
+		if err := c.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		r := c.Result()
+		if r.Verdict != tt.verdict || r.Syscall != tt.syscall || r.SyscallArch != tt.arch {
+			t.Errorf("%s: verdict %s, syscall %d of %q, want %s, %d of %q", tt.name, VERDICT_STR[r.Verdict],
+				r.Syscall, r.SyscallArch, VERDICT_STR[tt.verdict], tt.syscall, tt.arch)
+		}
+	}
+}
+
+// syscalls of 32-bit names are allowed by a preset level, the arch of binary
+// is added to the filter by preflight.
+func TestArchLevelI386(t *testing.T) {
+	if NativeArch() != "amd64" {
+		t.Skip("stubs are of amd64")
+	}
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestArchLevelI386")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "i386")
+	if err := ioutil.WriteFile(path, buildElf(elf.ELFCLASS32, elf.EM_386, "", stubI386Basic), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{scmpFilter.LEVEL_BASIC, scmpFilter.LEVEL_SYSTEM, scmpFilter.LEVEL_ALL} {
+		c := Command(path)
+		c.Syscall = &SyscallLimit{Level: level}
+		c.ResourceLimit.ClockTime = 5000
+		if err := c.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", scmpFilter.LEVEL_STR[level], err)
+			continue
+		}
+		if r := c.Result(); r.Verdict != VERDICT_OK || r.ExitCode != 0 {
+			t.Errorf("%s: verdict %s, exit code %d, syscall %d of %q, want ok", scmpFilter.LEVEL_STR[level],
+				VERDICT_STR[r.Verdict], r.ExitCode, r.Syscall, r.SyscallArch)
+		}
+	}
+}
+
+func TestScmpArches(t *testing.T) {
+	helper := &scmpFilter.ScmpFilterLoadHelper{Arches: []string{"x86", "x32"}}
+	scmp, err := scmpFilter.NewFilter(helper, seccomp.ActAllow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer scmp.Release()
+	if action, err := scmp.GetBadArchAction(); err != nil || action != seccomp.ActKillProcess {
+		t.Errorf("bad arch action = %v, %v, want kill process by default", action, err)
+	}
+	for _, arch := range []seccomp.ScmpArch{seccomp.ArchNative, seccomp.ArchX86, seccomp.ArchX32} {
+		if ok, err := scmp.IsArchPresent(arch); !ok || err != nil {
+			t.Errorf("arch %v present = %v, %v, want true", arch, ok, err)
+		}
+	}
+	if _, err = scmpFilter.NewFilter(&scmpFilter.ScmpFilterLoadHelper{Arches: []string{"pdp11"}}, seccomp.ActAllow); err == nil {
+		t.Error("unknown arch is added")
+	}
+
+	c := Command("main")
+	c.Syscall = &SyscallLimit{Arches: []string{"x86", NativeArch(), "native", "x86"}}
+	c.binary = &Binary{Arch: "x32"}
+	if got := c.scmpArches(); !reflect.DeepEqual(got, []string{"x86", "x32"}) {
+		t.Errorf("arches = %v, want [x86 x32]", got)
+	}
+	c.binary = &Binary{Arch: NativeArch()}
+	if got := c.scmpArches(); !reflect.DeepEqual(got, []string{"x86"}) {
+		t.Errorf("arches = %v, want [x86]", got)
+	}
+}
@@ -0,0 +1,382 @@
+//+build linux
+
+package exec
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MANIFEST_FILE in a test dir lists the cases, one `input answer` per line,
+// paths are relative to the dir, empty lines and lines start with # are ignored.
+const MANIFEST_FILE = "manifest"
+
+var (
+	ErrNoTestCase    = errors.New("exec: no test case found")
+	ErrBadManifest   = errors.New("exec: manifest line must like `input answer`")
+	ErrNoCommand     = errors.New("exec: batch need Command to create Cmd")
+	ErrDuplicateCase = errors.New("exec: duplicate test case name")
+	ErrMissingAnswer = errors.New("exec: answer file of test case not found")
+	ErrNoChecker     = errors.New("exec: batch need Checker to compare outputs")
+	ErrOutputIsCase  = errors.New("exec: output file is a file of test cases")
+)
+
+// TestCase is one input file and its answer file.
+type TestCase struct {
+	Name   string
+	Input  string
+	Answer string
+}
+
+// LoadTestCases loads cases in dir. If MANIFEST_FILE exists, cases are read from it
+// in order, otherwise every `name.in` is paired with `name.out` (or `name.ans`), and
+// cases are sorted by name with numbers compared by value, so 2 is before 10.
+func LoadTestCases(dir string) ([]TestCase, error) {
+	manifest := filepath.Join(dir, MANIFEST_FILE)
+	if _, err := os.Stat(manifest); err == nil {
+		return loadManifest(dir, manifest)
+	}
+
+	inputs, err := filepath.Glob(filepath.Join(dir, "*.in"))
+	if err != nil {
+		return nil, err
+	}
+	var cases []TestCase
+	for _, input := range inputs {
+		base := strings.TrimSuffix(input, ".in")
+		tc := TestCase{Name: filepath.Base(base), Input: input}
+		for _, ext := range []string{".out", ".ans"} {
+			if stat, err := os.Stat(base + ext); err == nil && !stat.IsDir() {
+				tc.Answer = base + ext
+				break
+			}
+		}
+		if tc.Answer == "" {
+			return nil, fmt.Errorf("%v: %s", ErrMissingAnswer, tc.Name)
+		}
+		cases = append(cases, tc)
+	}
+	if len(cases) == 0 {
+		return nil, ErrNoTestCase
+	}
+	sort.Slice(cases, func(i, j int) bool { return naturalLess(cases[i].Name, cases[j].Name) })
+	return cases, nil
+}
+
+func loadManifest(dir, manifest string) ([]TestCase, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var cases []TestCase
+	names := map[string]bool{}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%v, line %d: %q", ErrBadManifest, n, line)
+		}
+		tc := TestCase{
+			Name:   strings.TrimSuffix(filepath.Base(fields[0]), filepath.Ext(fields[0])),
+			Input:  filepath.Join(dir, fields[0]),
+			Answer: filepath.Join(dir, fields[1]),
+		}
+		if names[tc.Name] {
+			return nil, fmt.Errorf("%v, line %d: %s", ErrDuplicateCase, n, tc.Name)
+		}
+		names[tc.Name] = true
+		cases = append(cases, tc)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, ErrNoTestCase
+	}
+	return cases, nil
+}
+
+// naturalLess compares a and b with runs of digits compared by value.
+func naturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		da, db := digitPrefix(a), digitPrefix(b)
+		if da != "" && db != "" {
+			na, nb := strings.TrimLeft(da, "0"), strings.TrimLeft(db, "0")
+			if len(na) != len(nb) {
+				return len(na) < len(nb)
+			}
+			if na != nb {
+				return na < nb
+			}
+			a, b = a[len(da):], b[len(db):]
+			continue
+		}
+		if a[0] != b[0] {
+			return a[0] < b[0]
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func digitPrefix(s string) string {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return s[:i]
+}
+
+// CheckResult is the result of comparing an output with its answer.
+type CheckResult struct {
+	Verdict int     // VERDICT_OK, VERDICT_WRONG_ANSWER, VERDICT_PRESENTATION_ERROR, or VERDICT_PARTIAL by special judge
+	Message string  // where the output differs from the answer
+	Score   float64 // score given by special judge when verdict is VERDICT_PARTIAL
+	Checker *Result // result of the special judge run, nil for other checkers
+}
+
+// Checker compares the output file of a case with the answer file.
+type Checker interface {
+	Check(input, output, answer string) (*CheckResult, error)
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(input, output, answer string) (*CheckResult, error)
+
+func (f CheckerFunc) Check(input, output, answer string) (*CheckResult, error) {
+	return f(input, output, answer)
+}
+
+// Batch runs a program against test cases with the same settings.
+type Batch struct {
+	Cases []TestCase
+	// Command creates the Cmd for a case, Stdin and Stdout will be replaced by
+	// the input file and the output file, other settings are kept.
+	// An error fails the case as a judge error.
+	Command func(tc TestCase) (*Cmd, error)
+	// Checker compares the output with the answer, like checker.Lines().
+	Checker Checker
+	// Parallel is the max cases run at the same time, less than 1 means 1.
+	Parallel int
+	// StopOnFailure skips cases not started yet after a case failed.
+	StopOnFailure bool
+	// OutputDir keeps the output of every case as `name.out`, if empty, a
+	// temporary dir is used and removed when Run returns. An output must not be
+	// an input or answer of cases, like when OutputDir is the tests dir.
+	OutputDir string
+}
+
+// CaseResult is the result of one case.
+type CaseResult struct {
+	Case    TestCase
+	Result  *Result // nil if the case is skipped or failed to run
+	Verdict int     // VERDICT_*
+	Message string  // message of the checker or the error
+	Score   float64 // score given by special judge when verdict is VERDICT_PARTIAL
+	Checker *Result // result of the special judge run, nil for other checkers
+	Skipped bool    // not run because of StopOnFailure or the context is done
+	Err     error
+}
+
+// BatchResult is the result of all cases.
+type BatchResult struct {
+	Cases []CaseResult
+	// Verdict is the verdict of the first failed case in order, VERDICT_CANCELLED
+	// if no case failed but some are skipped, otherwise VERDICT_OK.
+	Verdict    int
+	Passed     int
+	CpuTime    uint   // max of cases
+	ClockTime  uint   // max of cases
+	MemoryUsed uint64 // max of cases
+}
+
+// Run runs the cases, when ctx is done, running cases are cancelled and others are skipped.
+func (b *Batch) Run(ctx context.Context) (*BatchResult, error) {
+	if b.Command == nil {
+		return nil, ErrNoCommand
+	}
+	if b.Checker == nil {
+		return nil, ErrNoChecker
+	}
+	outputDir := b.OutputDir
+	if outputDir == "" {
+		dir, err := ioutil.TempDir("", "sandbox-batch")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		outputDir = dir
+	} else if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	} else if err := b.checkOutputs(outputDir); err != nil {
+		return nil, err
+	}
+	parallel := b.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	results := make([]CaseResult, len(b.Cases))
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range b.Cases {
+			next <- i
+		}
+	}()
+
+	var mu sync.Mutex
+	failed := false
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				tc := b.Cases[i]
+				mu.Lock()
+				skip := failed && b.StopOnFailure || ctx.Err() != nil
+				mu.Unlock()
+				if skip {
+					results[i] = CaseResult{Case: tc, Verdict: VERDICT_CANCELLED, Skipped: true}
+					continue
+				}
+
+				results[i] = b.runCase(ctx, tc, filepath.Join(outputDir, tc.Name+".out"))
+				if results[i].Verdict != VERDICT_OK {
+					mu.Lock()
+					failed = true
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	r := &BatchResult{Cases: results, Verdict: VERDICT_OK}
+	skipped := false
+	for _, cr := range results {
+		if cr.Skipped {
+			skipped = true
+			continue
+		}
+		if cr.Verdict == VERDICT_OK {
+			r.Passed++
+		} else if r.Verdict == VERDICT_OK {
+			r.Verdict = cr.Verdict
+		}
+		if cr.Result != nil {
+			r.CpuTime = maxUint(r.CpuTime, cr.Result.CpuTime)
+			r.ClockTime = maxUint(r.ClockTime, cr.Result.ClockTime)
+			if cr.Result.MemoryUsed > r.MemoryUsed {
+				r.MemoryUsed = cr.Result.MemoryUsed
+			}
+		}
+	}
+	if r.Verdict == VERDICT_OK && skipped {
+		r.Verdict = VERDICT_CANCELLED
+	}
+	return r, nil
+}
+
+// checkOutputs returns an error if an output in dir is a file of cases, it would
+// be overwritten.
+func (b *Batch) checkOutputs(dir string) error {
+	var files []os.FileInfo
+	for _, tc := range b.Cases {
+		for _, path := range []string{tc.Input, tc.Answer} {
+			if stat, err := os.Stat(path); err == nil {
+				files = append(files, stat)
+			}
+		}
+	}
+	for _, tc := range b.Cases {
+		output := filepath.Join(dir, tc.Name+".out")
+		stat, err := os.Stat(output)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			if os.SameFile(stat, f) {
+				return fmt.Errorf("%v: %s", ErrOutputIsCase, output)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Batch) runCase(ctx context.Context, tc TestCase, output string) (cr CaseResult) {
+	cr.Case = tc
+	defer func() {
+		// Start fails when ctx is done, the case is not run
+		if cr.Err != nil && ctx.Err() != nil {
+			cr.Verdict, cr.Message, cr.Skipped, cr.Err = VERDICT_CANCELLED, ctx.Err().Error(), true, nil
+		}
+		if cr.Err != nil {
+			cr.Verdict = VERDICT_JUDGE_ERROR
+			cr.Message = cr.Err.Error()
+		}
+	}()
+
+	stdin, err := os.Open(tc.Input)
+	if err != nil {
+		cr.Err = err
+		return
+	}
+	defer stdin.Close()
+	stdout, err := os.Create(output)
+	if err != nil {
+		cr.Err = err
+		return
+	}
+	defer stdout.Close()
+
+	c, err := b.Command(tc)
+	if err != nil {
+		cr.Err = err
+		return
+	}
+	if c.baseCtx == nil {
+		c.baseCtx = ctx
+	}
+	c.Stdin, c.Stdout = stdin, stdout
+	cr.Err = c.Run()
+	if cr.Err != nil {
+		return
+	}
+	cr.Result = c.Result()
+	cr.Verdict = cr.Result.Verdict
+	if cr.Verdict != VERDICT_OK {
+		return
+	}
+
+	check, err := b.Checker.Check(tc.Input, output, tc.Answer)
+	if err != nil {
+		cr.Err = err
+		return
+	}
+	cr.Verdict, cr.Message = check.Verdict, check.Message
+	cr.Score, cr.Checker = check.Score, check.Checker
+	return
+}
+
+func maxUint(a, b uint) uint {
+	if a > b {
+		return a
+	}
+	return b
+}
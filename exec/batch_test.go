@@ -0,0 +1,164 @@
+// +build linux
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCases(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// tokenChecker compares tokens, the checker package imports exec, so it is not
+// used here.
+var tokenChecker = CheckerFunc(func(input, output, answer string) (*CheckResult, error) {
+	o, err := ioutil.ReadFile(output)
+	if err != nil {
+		return nil, err
+	}
+	a, err := ioutil.ReadFile(answer)
+	if err != nil {
+		return nil, err
+	}
+	got, want := strings.Join(strings.Fields(string(o)), " "), strings.Join(strings.Fields(string(a)), " ")
+	if got != want {
+		return &CheckResult{Verdict: VERDICT_WRONG_ANSWER, Message: fmt.Sprintf("expected %q, got %q", want, got)}, nil
+	}
+	return &CheckResult{Verdict: VERDICT_OK}, nil
+})
+
+func TestBatch(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	dir, err := ioutil.TempDir("", "batch-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCases(t, dir, map[string]string{
+		"1.in": "1 2\n", "1.out": "1 2  \n\n\n",
+		"2.in": "3 4\n", "2.ans": "3 4",
+		"10.in": "5 6\n", "10.out": "5 7\n",
+		"3.in": "7 8\n", "3.out": "7 8\n",
+	})
+
+	cases, err := LoadTestCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tc := range cases {
+		names = append(names, tc.Name)
+	}
+	if len(names) != 4 || names[0] != "1" || names[1] != "2" || names[2] != "3" || names[3] != "10" {
+		t.Fatalf("case names = %v, want [1 2 3 10]", names)
+	}
+
+	b := &Batch{
+		Cases:    cases,
+		Command:  func(tc TestCase) (*Cmd, error) { return Command("/bin/cat"), nil },
+		Checker:  tokenChecker,
+		Parallel: 2,
+	}
+	r, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []int{VERDICT_OK, VERDICT_OK, VERDICT_OK, VERDICT_WRONG_ANSWER}
+	for i, cr := range r.Cases {
+		if cr.Verdict != want[i] || cr.Skipped || cr.Result == nil {
+			t.Errorf("case %s: verdict = %s, message = %q, want %s", cr.Case.Name, VERDICT_STR[cr.Verdict], cr.Message, VERDICT_STR[want[i]])
+		}
+	}
+	if r.Verdict != VERDICT_WRONG_ANSWER || r.Passed != 3 {
+		t.Errorf("verdict = %s, passed = %d, want wrong answer and 3", VERDICT_STR[r.Verdict], r.Passed)
+	}
+	if msg := r.Cases[3].Message; msg != `expected "5 7", got "5 6"` {
+		t.Errorf("message = %q", msg)
+	}
+
+	// the first case fails, others are skipped
+	b = &Batch{
+		Cases:         cases,
+		Command:       func(tc TestCase) (*Cmd, error) { return Command("/bin/false"), nil },
+		Checker:       tokenChecker,
+		StopOnFailure: true,
+	}
+	if r, err = b.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != VERDICT_RUNTIME_ERROR || r.Passed != 0 {
+		t.Errorf("verdict = %s, passed = %d, want runtime error and 0", VERDICT_STR[r.Verdict], r.Passed)
+	}
+	for _, cr := range r.Cases[1:] {
+		if !cr.Skipped {
+			t.Errorf("case %s is not skipped", cr.Case.Name)
+		}
+	}
+
+	// a case fails as judge error if its Cmd can not be created
+	b = &Batch{
+		Cases:   cases[:1],
+		Command: func(tc TestCase) (*Cmd, error) { return nil, errors.New("bad option") },
+		Checker: tokenChecker,
+	}
+	if r, err = b.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if cr := r.Cases[0]; cr.Verdict != VERDICT_JUDGE_ERROR || cr.Message != "bad option" {
+		t.Errorf("verdict = %s, message = %q, want judge error", VERDICT_STR[cr.Verdict], cr.Message)
+	}
+
+	// a case cancelled before it starts is skipped
+	ctx, cancel := context.WithCancel(context.Background())
+	b = &Batch{
+		Cases: cases[:1],
+		Command: func(tc TestCase) (*Cmd, error) {
+			cancel()
+			return Command("/bin/cat"), nil
+		},
+		Checker: tokenChecker,
+	}
+	if r, err = b.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cr := r.Cases[0]; r.Verdict != VERDICT_CANCELLED || cr.Verdict != VERDICT_CANCELLED || !cr.Skipped {
+		t.Errorf("verdict = %s, case = %+v, want cancelled", VERDICT_STR[r.Verdict], cr)
+	}
+
+	b = &Batch{Cases: cases, Command: func(tc TestCase) (*Cmd, error) { return Command("/bin/cat"), nil }}
+	if _, err = b.Run(context.Background()); err != ErrNoChecker {
+		t.Errorf("err = %v, want %v", err, ErrNoChecker)
+	}
+	// outputs would overwrite the answers
+	b.Checker, b.OutputDir = tokenChecker, dir
+	if _, err = b.Run(context.Background()); err == nil || !strings.HasPrefix(err.Error(), ErrOutputIsCase.Error()) {
+		t.Errorf("err = %v, want %v", err, ErrOutputIsCase)
+	}
+
+	// manifest takes order and files from it
+	writeCases(t, dir, map[string]string{MANIFEST_FILE: "# comment\n3.in 3.out\n\n1.in 1.out\n"})
+	if cases, err = LoadTestCases(dir); err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].Name != "3" || cases[1].Answer != filepath.Join(dir, "1.out") {
+		t.Errorf("cases from manifest = %+v", cases)
+	}
+	writeCases(t, dir, map[string]string{MANIFEST_FILE: "3.in\n"})
+	if _, err = LoadTestCases(dir); err == nil {
+		t.Error("bad manifest should fail")
+	}
+}
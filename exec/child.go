@@ -4,6 +4,7 @@ package exec
 
 import (
 	"errors"
+	"github.com/sdibtacm/sandbox/exec/log"
 	"runtime"
 	"sync"
 	"syscall"
@@ -15,6 +16,8 @@ var ForkLock sync.RWMutex
 type SysAttr struct {
 	Ptrace        bool
 	Setsid        bool
+	Setctty       bool // set controlling terminal to fd Ctty (only meaningful if Setsid is set)
+	Ctty          int  // controlling terminal fd, it is the fd number in child
 	RlimitList    [20]uint64
 	SetNoNewPrivs bool
 	Cloneflags    uintptr
@@ -22,6 +25,7 @@ type SysAttr struct {
 	Pdeathsig     uint
 	Credential    *Credential
 	Bpf           *syscall.SockFprog
+	CPUSet        []int // cpus the process can run on, all cpus if empty
 }
 
 type Credential struct {
@@ -41,7 +45,9 @@ func (e *ExecError) Error() string {
 
 var zeroSysAttr SysAttr
 
-func forkExec(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr) (pid int, err error) {
+// forkExec starts the process, steps completed by child are returned, the last
+// one is the failed step if err is not nil.
+func forkExec(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr, l *log.RunLogger) (pid int, steps []stepRecord, err error) {
 
 	var (
 		stepPipe [2]int
@@ -52,7 +58,7 @@ func forkExec(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr)
 		err2     error
 		err3     error
 		wstatus  syscall.WaitStatus
-		step     int
+		record   stepRecord
 	)
 
 	ForkLock.Lock()
@@ -75,11 +81,20 @@ func forkExec(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr)
 	errN, err2 = readlen(errPipe[0], (*byte)(unsafe.Pointer(&err1)), int(unsafe.Sizeof(err1)))
 	_ = syscall.Close(errPipe[0])
 	_ = syscall.Close(stepPipe[1])
-	stepN, err3 = readlen(stepPipe[0], (*byte)(unsafe.Pointer(&step)), int(unsafe.Sizeof(step)))
+	for {
+		stepN, err3 = readlen(stepPipe[0], (*byte)(unsafe.Pointer(&record)), int(unsafe.Sizeof(record)))
+		if err3 == syscall.EINTR {
+			continue
+		}
+		if err3 != nil || stepN != int(unsafe.Sizeof(record)) {
+			break
+		}
+		steps = append(steps, record)
+	}
 	_ = syscall.Close(stepPipe[0])
 	if err2 != nil || err3 != nil || errN != 0 {
-		if errN == int(unsafe.Sizeof(err1)) && stepN == int(unsafe.Sizeof(step)) {
-			err = &ExecError{Step: step, Err: errors.New(err1.Error())}
+		if errN == int(unsafe.Sizeof(err1)) && len(steps) > 0 {
+			err = &ExecError{Step: int(steps[len(steps)-1].Step), Err: errors.New(err1.Error())}
 		}
 		if err == nil && err2 == nil {
 			err = &ExecError{Step: SANDBOX_READ_PIPE, Err: syscall.EPIPE}
@@ -88,14 +103,17 @@ func forkExec(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr)
 			err = &ExecError{Step: SANDBOX_READ_PIPE, Err: syscall.EPIPE}
 		}
 
+		l.Debug("child {} failed at step {}", pid, err)
+
 		// Child failed; wait for it to exit, to make sure
 		// the zombies don't accumulate.
 		_, err1 := syscall.Wait4(pid, &wstatus, 0, nil)
 		for err1 == syscall.EINTR {
 			_, err1 = syscall.Wait4(pid, &wstatus, 0, nil)
 		}
-		return 0, err
+		return 0, steps, err
 	}
+	l.Debug("child {} forked", pid)
 	return
 
 error:
@@ -108,7 +126,19 @@ error:
 		_ = syscall.Close(errPipe[1])
 	}
 	ForkLock.Unlock()
-	return 0, &ExecError{Step: SANDBOX_PREPARE_PIPE, Err: err2}
+	return 0, nil, &ExecError{Step: SANDBOX_PREPARE_PIPE, Err: err2}
+}
+
+// childReportStep writes the step completed or failed with the time now to the
+// step pipe, it is called in child after fork, so it must not grow stack.
+//
+//go:nosplit
+//go:norace
+func childReportStep(stepPipe int, step int) {
+	var ts syscall.Timespec
+	RawSyscall(syscall.SYS_CLOCK_GETTIME, CLOCK_MONOTONIC, uintptr(unsafe.Pointer(&ts)), 0)
+	record := stepRecord{Step: int64(step), Time: int64(ts.Sec)*1e9 + int64(ts.Nsec)}
+	RawSyscall(syscall.SYS_WRITE, uintptr(stepPipe), uintptr(unsafe.Pointer(&record)), unsafe.Sizeof(record))
 }
 
 func cloneAndExecInChild(argv0 *byte, argv, envv []*byte, chroot, dir *byte, attr *SysAttr, errPipe, stepPipe int) (pid int, err syscall.Errno) {
@@ -127,8 +157,6 @@ func cloneAndExecInChild(argv0 *byte, argv, envv []*byte, chroot, dir *byte, att
 
 }
 
-var step int = SANDBOX_NO_START
-
 //go:noinline
 //go:norace
 func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sys *SysAttr, errPipe, stepPipe int) (r1 uintptr, err1 syscall.Errno, locked bool) {
@@ -142,6 +170,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		nextfd int
 		i      int
 		//fd1                       uintptr
+		step = SANDBOX_NO_START // every child has its own step, so it must not be global
 	)
 
 	ppid, _ := rawSyscallNoError(syscall.SYS_GETPID, 0, 0, 0)
@@ -159,6 +188,12 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 	}
 	nextfd++
 
+	// cpus have been checked in Start
+	var cpuMask [MAX_CPU / 64]uint64
+	for _, cpu := range sys.CPUSet {
+		cpuMask[cpu/64] |= 1 << uint(cpu%64)
+	}
+
 	runtimeBeforeFork()
 	locked = true
 
@@ -182,6 +217,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 	// Fork succeeded, now in child.
 
 	runtimeAfterForkInChild()
+	childReportStep(stepPipe, step)
 
 	// Session ID
 	if sys.Setsid {
@@ -198,6 +234,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		if err1 != 0 {
 			goto childerror
 		}
+		childReportStep(stepPipe, step)
 	}
 
 	if cred := sys.Credential; cred != nil {
@@ -207,6 +244,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 			if err1 != 0 {
 				goto childerror
 			}
+			childReportStep(stepPipe, step)
 		}
 		if cred.Gid != 0 {
 			step = SANDBOX_READY_FOR_SETGID
@@ -214,6 +252,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 			if err1 != 0 {
 				goto childerror
 			}
+			childReportStep(stepPipe, step)
 		}
 		if cred.Umask != 0 {
 			step = SANDBOX_READY_FOR_SETUMASK
@@ -221,6 +260,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 			if err1 != 0 {
 				goto childerror
 			}
+			childReportStep(stepPipe, step)
 		}
 	}
 
@@ -231,6 +271,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		if err1 != 0 {
 			goto childerror
 		}
+		childReportStep(stepPipe, step)
 	}
 
 	// Parent death signal
@@ -240,6 +281,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		if err1 != 0 {
 			goto childerror
 		}
+		childReportStep(stepPipe, step)
 
 		// Signal self if parent is already dead. This might cause a
 		// duplicate signal in rare cases, but it won't matter when
@@ -259,6 +301,9 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 	// Pass 1: look for fd[i] < i and move those up above len(fd)
 	// so that pass 2 won't stomp on an fd it needs later.
 	if errPipe < nextfd {
+		for nextfd == stepPipe { // don't stomp on step pipe
+			nextfd++
+		}
 		_, _, err1 = RawSyscall(syscall.SYS_DUP2, uintptr(errPipe), uintptr(nextfd), 0)
 		if err1 != 0 {
 			goto childerror
@@ -267,9 +312,21 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		errPipe = nextfd
 		nextfd++
 	}
+	if stepPipe < nextfd {
+		for nextfd == errPipe { // don't stomp on err pipe
+			nextfd++
+		}
+		_, _, err1 = RawSyscall(syscall.SYS_DUP2, uintptr(stepPipe), uintptr(nextfd), 0)
+		if err1 != 0 {
+			goto childerror
+		}
+		RawSyscall(syscall.SYS_FCNTL, uintptr(nextfd), syscall.F_SETFD, syscall.FD_CLOEXEC)
+		stepPipe = nextfd
+		nextfd++
+	}
 	for i = 0; i < len(fd); i++ {
 		if fd[i] >= 0 && fd[i] < int(i) {
-			if nextfd == errPipe { // don't stomp on pipe
+			for nextfd == errPipe || nextfd == stepPipe { // don't stomp on pipe
 				nextfd++
 			}
 			_, _, err1 = RawSyscall(syscall.SYS_DUP2, uintptr(fd[i]), uintptr(nextfd), 0)
@@ -304,6 +361,35 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 			goto childerror
 		}
 	}
+	childReportStep(stepPipe, step)
+
+	// Set the controlling TTY to Ctty
+	if sys.Setctty {
+		step = SANDBOX_READY_FOR_SET_CTTY
+		_, _, err1 = RawSyscall(syscall.SYS_IOCTL, uintptr(sys.Ctty), uintptr(syscall.TIOCSCTTY), 1)
+		if err1 != 0 {
+			goto childerror
+		}
+		childReportStep(stepPipe, step)
+	}
+
+	if len(sys.CPUSet) > 0 {
+		step = SANDBOX_READY_FOR_SET_AFFINITY
+		_, _, err1 = RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, unsafe.Sizeof(cpuMask), uintptr(unsafe.Pointer(&cpuMask)))
+		if err1 != 0 {
+			goto childerror
+		}
+		childReportStep(stepPipe, step)
+	}
+
+	// orphans of the run are reparented to the process instead of sandbox, so
+	// they are still its descendants, it is kept by exec.
+	step = SANDBOX_READY_FOR_SET_SUBREAPER
+	_, _, err1 = RawSyscall(syscall.SYS_PRCTL, PR_SET_CHILD_SUBREAPER, 1, 0)
+	if err1 != 0 {
+		goto childerror
+	}
+	childReportStep(stepPipe, step)
 
 	step = SANDBOX_READY_FOR_SET_RLIMIT
 	for i = 0; i <= RLIMIT_NLIMITS; i++ {
@@ -315,6 +401,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 			}
 		}
 	}
+	childReportStep(stepPipe, step)
 
 	if sys.Ptrace {
 		step = SANDBOX_READY_FOR_SET_PTRACE
@@ -322,6 +409,17 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		if err1 != 0 {
 			goto childerror
 		}
+		childReportStep(stepPipe, step)
+	}
+
+	// a filter can not be loaded without it after uid is changed
+	if sys.SetNoNewPrivs || sys.Bpf != nil {
+		step = SANDBOX_READY_FOR_SET_NO_NEW_PRIVS
+		_, _, err1 = RawSyscall(syscall.SYS_PRCTL, PR_SET_NO_NEW_PRIVS, 1, 0)
+		if err1 != 0 {
+			goto childerror
+		}
+		childReportStep(stepPipe, step)
 	}
 
 	if sys.Bpf != nil {
@@ -330,6 +428,7 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		if err1 != 0 {
 			goto childerror
 		}
+		childReportStep(stepPipe, step)
 	}
 
 	// Time to exec.
@@ -340,8 +439,9 @@ func cloneAndExecInChild1(argv0 *byte, argv, envv []*byte, chroot, dir *byte, sy
 		uintptr(unsafe.Pointer(&envv[0])))
 
 childerror:
-	RawSyscall(syscall.SYS_WRITE, uintptr(errPipe), uintptr(unsafe.Pointer(&err1)), unsafe.Sizeof(err1))  // what error
-	RawSyscall(syscall.SYS_WRITE, uintptr(stepPipe), uintptr(unsafe.Pointer(&step)), unsafe.Sizeof(step)) // which step
+	RawSyscall(syscall.SYS_WRITE, uintptr(errPipe), uintptr(unsafe.Pointer(&err1)), unsafe.Sizeof(err1)) // what error
+	// which step, it is the last one in step pipe
+	childReportStep(stepPipe, step)
 	for {
 		_, _, _ = RawSyscall(syscall.SYS_EXIT, 253, 0, 0)
 	}
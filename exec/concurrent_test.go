@@ -0,0 +1,60 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentSandbox runs many sandboxes in one process, should be run with -race.
+func TestConcurrentSandbox(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	const n = 100
+	var wg sync.WaitGroup
+	sys := &SysAttr{} // share one SysAttr, Start must not modify it
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			var stdout bytes.Buffer
+			c := Command("/bin/sh", "-c", "echo "+strconv.Itoa(i)+"; exit "+strconv.Itoa(i%4))
+			c.Sys = sys
+			c.Stdout = &stdout
+			c.ResourceLimit.ClockTime = 10000
+			c.ResourceLimit.Output = 64
+			if err := c.Run(); err != nil {
+				t.Errorf("run %d: %v", i, err)
+				return
+			}
+
+			r := c.Result()
+			if got, want := stdout.String(), strconv.Itoa(i)+"\n"; got != want {
+				t.Errorf("run %d: stdout = %q, want %q", i, got, want)
+			}
+			if r.ExitCode != i%4 {
+				t.Errorf("run %d: exit code = %d, want %d", i, r.ExitCode, i%4)
+			}
+			wantVerdict := VERDICT_OK
+			if i%4 != 0 {
+				wantVerdict = VERDICT_RUNTIME_ERROR
+			}
+			if r.Verdict != wantVerdict {
+				t.Errorf("run %d: verdict = %s, want %s", i, VERDICT_STR[r.Verdict], VERDICT_STR[wantVerdict])
+			}
+			if r.Output != uint64(len(stdout.String())) {
+				t.Errorf("run %d: output = %d, want %d", i, r.Output, len(stdout.String()))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if sys.RlimitList != [20]uint64{} || sys.Files != nil || sys.Bpf != nil {
+		t.Errorf("shared SysAttr was modified: %+v", sys)
+	}
+}
@@ -1,5 +1,7 @@
 package exec
 
+import "time"
+
 const (
 	RLIMIT_UNRESOURCE    uint64 = 0
 	TIME_UNRESOURCE      uint   = 0
@@ -12,6 +14,9 @@ const (
 	SECCOMP
 )
 
+// limiter will sample process usage every LIMITER_INTERVAL
+const LIMITER_INTERVAL = time.Millisecond
+
 const (
 	SANDBOX_NO_START = iota
 	SANDBOX_PREPARE_PIPE
@@ -30,6 +35,10 @@ const (
 	SANDBOX_READY_FOR_EXEC
 
 	SANDBOX_READ_PIPE
+	SANDBOX_READY_FOR_SET_CTTY
+	SANDBOX_READY_FOR_SET_AFFINITY
+	SANDBOX_READY_FOR_SET_SUBREAPER
+	SANDBOX_READY_FOR_SET_NO_NEW_PRIVS
 )
 
 var SANDBOX_STEP_STR = []string{
@@ -49,4 +58,71 @@ var SANDBOX_STEP_STR = []string{
 	"set bpf",
 	"exec",
 	"read error status from pipe",
+	"set controlling tty",
+	"set cpu affinity",
+	"set child subreaper",
+	"set no new privs",
+}
+
+// which limit the process exceeded, set when the sandbox kill it
+const (
+	EXCEED_NONE = iota
+	EXCEED_CPU_TIME
+	EXCEED_CLOCK_TIME
+	EXCEED_MEMORY
+	EXCEED_THREAD
+	EXCEED_OUTPUT
+)
+
+var EXCEED_STR = []string{
+	"none",
+	"cpu time",
+	"clock time",
+	"memory",
+	"thread",
+	"output",
+}
+
+// how memory of the process is measured, runtimes like java and go reserve
+// much more virtual memory than they use, rss should be used for them.
+const (
+	MEMORY_METRIC_VSIZE = iota
+	MEMORY_METRIC_RSS
+)
+
+var MEMORY_METRIC_STR = []string{
+	"vsize",
+	"rss",
+}
+
+const (
+	VERDICT_OK = iota
+	VERDICT_RUNTIME_ERROR
+	VERDICT_TIME_LIMIT_EXCEEDED
+	VERDICT_MEMORY_LIMIT_EXCEEDED
+	VERDICT_OUTPUT_LIMIT_EXCEEDED
+	VERDICT_THREAD_LIMIT_EXCEEDED
+	VERDICT_BAD_SYSCALL
+	VERDICT_WRONG_ANSWER
+	VERDICT_PRESENTATION_ERROR
+	VERDICT_JUDGE_ERROR
+	VERDICT_CANCELLED
+	VERDICT_PARTIAL
+	VERDICT_COMPILE_ERROR
+)
+
+var VERDICT_STR = []string{
+	"ok",
+	"runtime error",
+	"time limit exceeded",
+	"memory limit exceeded",
+	"output limit exceeded",
+	"thread limit exceeded",
+	"bad syscall",
+	"wrong answer",
+	"presentation error",
+	"judge error",
+	"cancelled",
+	"partial",
+	"compile error",
 }
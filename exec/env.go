@@ -0,0 +1,122 @@
+//+build linux
+
+package exec
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ENV_WORKDIR in env values is expanded to the work dir of the process by ExpandEnvs.
+const ENV_WORKDIR = "SANDBOX_WORKDIR"
+
+const (
+	DEFAULT_ENV_PATH = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	DEFAULT_ENV_LANG = "C.UTF-8"
+)
+
+// DefaultEnvs returns the minimal environment used when Cmd.Envs is nil, the
+// host environment is never passed to the process unless it is set in Envs.
+func DefaultEnvs(home string) []string {
+	if home == "" {
+		home = "/"
+	}
+	return []string{"PATH=" + DEFAULT_ENV_PATH, "HOME=" + home, "LANG=" + DEFAULT_ENV_LANG}
+}
+
+// ReadEnvFile reads `KEY=VALUE` per line from path. Empty lines and lines
+// start with # are skipped, `export ` before KEY is allowed. VALUE can be
+// quoted by ' or ", it is kept as is in ', otherwise it is expanded like
+// ExpandEnvs, and unquoted by Go syntax in ".
+func ReadEnvFile(path string, vars map[string]string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var envs []string
+	defined := map[string]string{}
+	s := bufio.NewScanner(f)
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("%s:%d: env must like KEY=VALUE", path, n)
+		}
+		key, value := strings.TrimSpace(line[:eq]), strings.TrimSpace(line[eq+1:])
+		if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		} else {
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				if value, err = strconv.Unquote(value); err != nil {
+					return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+				}
+			}
+			value = expandEnv(value, defined, vars)
+		}
+		defined[key] = value
+		envs = append(envs, key+"="+value)
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return envs, nil
+}
+
+// ExpandEnvs replaces $NAME and ${NAME} in values of envs. NAME is looked up
+// in envs before it, then in vars, it is empty if not found, so the host
+// environment is never used. $$ is a $.
+func ExpandEnvs(envs []string, vars map[string]string) []string {
+	defined := make(map[string]string, len(envs))
+	expanded := make([]string, 0, len(envs))
+	for _, env := range envs {
+		eq := strings.IndexByte(env, '=')
+		if eq < 0 {
+			expanded = append(expanded, env)
+			continue
+		}
+		key, value := env[:eq], expandEnv(env[eq+1:], defined, vars)
+		defined[key] = value
+		expanded = append(expanded, key+"="+value)
+	}
+	return expanded
+}
+
+func expandEnv(value string, defined, vars map[string]string) string {
+	return os.Expand(value, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if v, ok := defined[name]; ok {
+			return v
+		}
+		return vars[name]
+	})
+}
+
+// dedupEnvs keeps the last one of envs with the same key.
+func dedupEnvs(envs []string) []string {
+	index := make(map[string]int, len(envs))
+	out := make([]string, 0, len(envs))
+	for _, env := range envs {
+		key := env
+		if eq := strings.IndexByte(env, '='); eq >= 0 {
+			key = env[:eq]
+		}
+		if i, ok := index[key]; ok {
+			out[i] = env
+			continue
+		}
+		index[key] = len(out)
+		out = append(out, env)
+	}
+	return out
+}
@@ -0,0 +1,73 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDefaultEnvs(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	if err := os.Setenv("SANDBOX_TEST_SECRET", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("SANDBOX_TEST_SECRET")
+
+	var out bytes.Buffer
+	c := Command("/usr/bin/env")
+	c.Chdir = "/tmp"
+	c.Stdout = &out
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(out.String()), DefaultEnvs("/tmp"); !reflect.DeepEqual(got, want) {
+		t.Errorf("env = %v, want %v", got, want)
+	}
+}
+
+func TestExpandEnvs(t *testing.T) {
+	envs := ExpandEnvs([]string{"A=1", "B=$A-${" + ENV_WORKDIR + "}", "C=$$A$NONE", "A=2", "D=$A"},
+		map[string]string{ENV_WORKDIR: "/w", "A": "0"})
+	want := []string{"A=1", "B=1-/w", "C=$A", "A=2", "D=2"}
+	if !reflect.DeepEqual(envs, want) {
+		t.Errorf("expand = %v, want %v", envs, want)
+	}
+	if got, want := dedupEnvs(envs), []string{"A=2", "B=1-/w", "C=$A", "D=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dedup = %v, want %v", got, want)
+	}
+}
+
+func TestReadEnvFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "env-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "env")
+	content := "# comment\n\nexport A=a b\nB=\"$A\\tc\"\nC='$A'\nD=$HOME/d\n"
+	if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	envs, err := ReadEnvFile(path, map[string]string{"HOME": "/home"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"A=a b", "B=a b\tc", "C=$A", "D=/home/d"}; !reflect.DeepEqual(envs, want) {
+		t.Errorf("envs = %q, want %q", envs, want)
+	}
+
+	if err = ioutil.WriteFile(path, []byte("A=1\nnot env\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadEnvFile(path, nil); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("err = %v, want error at line 2", err)
+	}
+}
@@ -0,0 +1,50 @@
+//+build linux
+
+package exec
+
+import "sync"
+
+// Listener receives lifecycle events of every Cmd, methods are called in the
+// goroutine calling Start or Wait, so they must not block.
+type Listener interface {
+	// OnQueued is called when Start begins to wait for a free cpu of Scheduler.
+	OnQueued(c *Cmd)
+	// OnStart is called after the process is started.
+	OnStart(c *Cmd)
+	// OnStartError is called when Start fails, err is *ExecError if fork or exec fails.
+	OnStartError(c *Cmd, err error)
+	// OnFinish is called at the end of Wait, r is nil if waiting the process fails.
+	OnFinish(c *Cmd, r *Result)
+}
+
+var listeners struct {
+	mu sync.RWMutex
+	ls []Listener
+}
+
+// AddListener adds l to receive events of all Cmd.
+func AddListener(l Listener) {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	listeners.ls = append(listeners.ls, l)
+}
+
+func RemoveListener(l Listener) {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	for i, ll := range listeners.ls {
+		if ll == l {
+			listeners.ls = append(listeners.ls[:i:i], listeners.ls[i+1:]...)
+			return
+		}
+	}
+}
+
+func emit(fn func(l Listener)) {
+	listeners.mu.RLock()
+	ls := listeners.ls
+	listeners.mu.RUnlock()
+	for _, l := range ls {
+		fn(l)
+	}
+}
@@ -11,7 +11,10 @@ import (
 	"github.com/sdibtacm/sandbox/exec/scmpFilter"
 	"io"
 	"os"
-	"os/signal"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 	"unsafe"
@@ -22,6 +25,9 @@ import (
 // It is non-nil everywhere but Plan 9, which lacks EPIPE. See exec_posix.go.
 var skipStdinCopyError func(error) bool
 
+// runSeq is the last ID given to a Cmd without ID.
+var runSeq uint64
+
 func init() {
 	skipStdinCopyError = func(err error) bool {
 		// Ignore EPIPE errors copying to stdin if the program
@@ -37,7 +43,7 @@ func init() {
 type Cmd struct {
 	Path   string   // run command path
 	Args   []string // run command args
-	Envs   []string
+	Envs   []string // nil means DefaultEnvs, the host environment is not passed
 	Chroot string
 	Chdir  string
 
@@ -45,6 +51,16 @@ type Cmd struct {
 	Stdout io.Writer
 	Stderr io.Writer
 
+	// ExtraFiles specifies additional open files to be inherited by the
+	// new process. It does not include standard input, standard output, or
+	// standard error. If non-nil, entry i becomes file descriptor 3+i.
+	// A nil entry makes the corresponding descriptor closed in the child.
+	ExtraFiles []*os.File
+
+	// Tty if not nil, the process will run with a pseudo-terminal as its
+	// stdin, stdout and stderr. See Tty.
+	Tty *Tty
+
 	ResourceLimit    Resource
 	resourceMaxStats Resource
 	resourceStats    Resource
@@ -52,18 +68,48 @@ type Cmd struct {
 	Syscall          *SyscallLimit
 	Process          *Process
 	ProcessState     *ProcessState
-
-	finished        bool // when Wait was called
-	ctx             context.Context
-	ctxCancel       context.CancelFunc
+	KillPolicy       *KillPolicy
+	// MemoryMetric is how memory is measured for ResourceLimit.Memory and
+	// Result.MemoryUsed, MEMORY_METRIC_*, default is virtual memory size.
+	MemoryMetric int
+	// Scheduler pins the process to a free cpu of it, Start waits until a cpu is free.
+	Scheduler *Scheduler
+
+	// ID identifies the run in log messages, a sequence number is used if it is empty.
+	ID string
+	// Logger is the logger of the run, the global logger is used if it is nil.
+	Logger *logs.Logger
+	// LogFields are added to every log message of the run.
+	LogFields []log.Field
+	// CaptureLog captures all log messages of the run into Result.Log.
+	CaptureLog bool
+
+	finished        bool            // when Wait was called
+	baseCtx         context.Context // set by CommandContext
+	clockTimer      *time.Timer     // kill the process when clock time limit exceeded
+	cpu             int             // the only cpu the process run on, -1 if not pinned
+	logger          *log.RunLogger
+	startMono       int64 // CLOCK_MONOTONIC when Start is called, timeline is relative to it
 	closeAfterStart []io.Closer
 	closeAfterWait  []io.Closer
 	childFiles      []*os.File
 	goroutine       []func() error
 	errch           chan error // one send per goroutine
-	sigchan         chan os.Signal
 	waitDone        chan struct{}
 	pt              *ptrace
+	traced          bool       // the process is traced by sandbox for seccomp trace action
+	mu              sync.Mutex // guard resource stats and the fields below
+	exceed          int        // which limit make sandbox kill the process
+	outputTruncated bool
+	cancelled       bool           // baseCtx is canceled before process exit
+	killing         bool           // first signal of KillPolicy has been sent
+	tracked         map[int]uint64 // pid and start time of processes seen in the run
+	paused          bool
+	pauseTimestamp  time.Time
+	pausedTime      time.Duration // total time paused, excluded from clock time
+	timeline        []TimelineEvent
+	lastSignal      syscall.Signal // last signal recorded in timeline
+	binary          *Binary        // found by preflight, nil if not an ELF
 
 	startTimestamp time.Time
 	endTimestamp   time.Time
@@ -75,15 +121,28 @@ type Result struct {
 	MemoryUsed uint64
 	ExitStatus syscall.WaitStatus
 	ExitCode   int
-	Exceed     int
-	HelpStr    string
+	Exceed     int // EXCEED_*
+	Verdict    int // VERDICT_*
+	Output     uint64
+	// OutputTruncated is true when output exceed the limit, the writer only
+	// get the prefix before the limit.
+	OutputTruncated bool
+	HelpStr         string
+	CPU             int    // the cpu the process is pinned to, -1 if not pinned to one cpu
+	Syscall         int    // number of the bad syscall when verdict is VERDICT_BAD_SYSCALL, otherwise -1
+	SyscallArch     string // seccomp arch name of the bad syscall, like x86 for int 0x80
+	Log             []byte // log messages as JSON lines when Cmd.CaptureLog is set
+	Timeline        []TimelineEvent
+	Binary          Binary // found by preflight before exec, Path is empty if not an ELF
 }
 
 type Resource struct {
 	CpuTime   uint
 	ClockTime uint
 	Memory    uint64
-	Output    uint64
+	Output    uint64 // stdout + stderr
+	Stdout    uint64
+	Stderr    uint64
 	Thread    uint
 }
 
@@ -91,23 +150,18 @@ type SyscallLimit struct {
 	Level  int
 	Action int
 	Helper string
+	// Arches are added to the filter besides the native arch, like x86 and x32,
+	// the arch of the executable found by preflight is always added
+	Arches []string
+	// BadArchAction is the action of syscalls of arches not in the filter,
+	// 0: kill, 1: trace, 2: EPERM, 3: ENOSYS
+	BadArchAction int
 }
 
 func SetLogger(logger *logs.Logger) {
 	log.SetLog(logger)
 }
 
-func findExecutable(name string) (string, error) {
-	if err := executable(name); err == nil {
-		return name, nil
-	}
-	if lp, err := LookPath(name); err != nil {
-		return "", err
-	} else {
-		return lp, nil
-	}
-}
-
 func Command(name string, args ...string) *Cmd {
 	return CommandNotSameProgramName(name, name, args...)
 }
@@ -119,11 +173,25 @@ func CommandNotSameProgramName(command string, name string, args ...string) *Cmd
 	return cmd
 }
 
+// CommandContext is like Command but includes a context.
+//
+// When the context is canceled before the command completes, the whole process
+// group will be killed and the verdict will be VERDICT_CANCELLED. The deadline
+// of context is used as the clock time limit if it is earlier than ResourceLimit.ClockTime.
+func CommandContext(ctx context.Context, name string, args ...string) *Cmd {
+	if ctx == nil {
+		panic("nil Context")
+	}
+	cmd := Command(name, args...)
+	cmd.baseCtx = ctx
+	return cmd
+}
+
 func (c *Cmd) envv() []string {
 	if c.Envs != nil {
-		return c.Envs
+		return dedupEnvs(c.Envs)
 	}
-	return os.Environ()
+	return DefaultEnvs(c.Chdir)
 }
 
 func (c *Cmd) argv() []string {
@@ -177,17 +245,24 @@ func (c *Cmd) stdin() (f *os.File, err error) {
 }
 
 func (c *Cmd) stdout() (f *os.File, err error) {
-	return c.writerDescriptor(c.Stdout)
+	return c.writerDescriptor(c.Stdout, &c.resourceStats.Stdout, c.ResourceLimit.Stdout)
 }
 
 func (c *Cmd) stderr() (f *os.File, err error) {
 	if c.Stderr != nil && interfaceEqual(c.Stderr, c.Stdout) {
 		return c.childFiles[1], nil
 	}
-	return c.writerDescriptor(c.Stderr)
+	return c.writerDescriptor(c.Stderr, &c.resourceStats.Stderr, c.ResourceLimit.Stderr)
 }
 
-func (c *Cmd) writerDescriptor(w io.Writer) (f *os.File, err error) {
+// outputLimited reports whether output of a stream should be counted by sandbox.
+// Regular files are counted too, RLIMIT_FSIZE limits each file by itself, so
+// it can not limit stdout and stderr separately or in total.
+func (c *Cmd) outputLimited(limit uint64) bool {
+	return c.ResourceLimit.Output != BYTE_UNRESOURCE || limit != BYTE_UNRESOURCE
+}
+
+func (c *Cmd) writerDescriptor(w io.Writer, used *uint64, limit uint64) (f *os.File, err error) {
 	if w == nil {
 		f, err = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
 		if err != nil {
@@ -197,9 +272,24 @@ func (c *Cmd) writerDescriptor(w io.Writer) (f *os.File, err error) {
 		return
 	}
 
-	if f, ok := w.(*os.File); ok {
+	limited := c.outputLimited(limit)
+	if f, ok := w.(*os.File); ok && !limited {
 		return f, nil
 	}
+	if f, ok := w.(*os.File); ok {
+		// the caller may close f after Start as it is passed to the child directly,
+		// like the pipe ends of a Pair, so the copy goroutine writes to a dup of f
+		fd, err := fcntl(int(f.Fd()), syscall.F_DUPFD_CLOEXEC, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("fcntl", err)
+		}
+		dup := os.NewFile(uintptr(fd), f.Name())
+		c.closeAfterWait = append(c.closeAfterWait, dup)
+		w = dup
+	}
+	if limited {
+		w = &limitWriter{c: c, w: w, used: used, limit: limit}
+	}
 
 	pr, pw, err := os.Pipe()
 	if err != nil {
@@ -223,36 +313,115 @@ func (c *Cmd) closeDescriptors(closers []io.Closer) {
 }
 
 func (c *Cmd) Start() error {
-	execPath, err := findExecutable(c.Path)
+	err := c.start()
 	if err != nil {
-		log.GetLog().Warning("{} can not exec", c.Path)
+		emit(func(l Listener) { l.OnStartError(c, err) })
 		return err
 	}
-	c.Path = execPath
+	emit(func(l Listener) { l.OnStart(c) })
+	return nil
+}
 
+func (c *Cmd) start() error {
+	// check it first, a running Cmd is used by limiter and tracer
 	if c.Process != nil {
 		return errors.New("exec: already started")
 	}
+	if c.ID == "" {
+		c.ID = strconv.FormatUint(atomic.AddUint64(&runSeq, 1), 10)
+	}
+	c.logger = log.NewRunLogger(c.Logger, c.ID, c.LogFields...)
+	if c.CaptureLog {
+		c.logger.Capture()
+	}
+	c.startMono = monotonicNow()
+	c.mark(TIMELINE_START, "")
+
+	// find it as the process will see it, after chroot and chdir
+	lookup := c.rootLookup()
+	execPath, err := lookup.lookPath(c.Path, c.envv())
+	if err != nil {
+		c.logger.Warning("{} can not exec", c.Path)
+		return err
+	}
+	c.Path = execPath
+
+	// preflight never fails the run, exec will report the error if any
+	if b, err := lookup.preflight(c.Path, c.seccompEnabled()); err != nil {
+		c.logger.Debug("preflight {} with error: {}", c.Path, err)
+	} else if b != nil {
+		c.binary = b
+		c.logger.Debug("preflight {}: {}", c.Path, *b)
+		for _, warning := range b.Warnings {
+			c.logger.Warning("{}", warning)
+		}
+	}
 
-	c.childFiles = make([]*os.File, 0, 3)
-	type F func(*Cmd) (*os.File, error)
-	for _, setupFd := range []F{(*Cmd).stdin, (*Cmd).stdout, (*Cmd).stderr} {
-		fd, err := setupFd(c)
+	// Start will modify SysAttr, copy it so many Cmd can share one SysAttr
+	sys := SysAttr{}
+	if c.Sys != nil {
+		sys = *c.Sys
+	}
+	c.Sys = &sys
+	if c.baseCtx != nil {
+		select {
+		case <-c.baseCtx.Done():
+			return c.baseCtx.Err()
+		default:
+		}
+	}
+
+	c.childFiles = make([]*os.File, 0, 3+len(c.ExtraFiles))
+	if c.Tty != nil {
+		if err := c.setupTty(); err != nil {
+			c.logger.Error("fail to setup tty with error: {}", err)
+			return err
+		}
+	} else {
+		type F func(*Cmd) (*os.File, error)
+		for _, setupFd := range []F{(*Cmd).stdin, (*Cmd).stdout, (*Cmd).stderr} {
+			fd, err := setupFd(c)
+			if err != nil {
+				c.logger.Error("fail to get os.File struct with error: {}", err)
+				c.closeDescriptors(c.closeAfterStart)
+				c.closeDescriptors(c.closeAfterWait)
+				return err
+			}
+			c.childFiles = append(c.childFiles, fd)
+		}
+	}
+	c.childFiles = append(c.childFiles, c.ExtraFiles...)
+
+	if err := checkCPUSet(c.Sys.CPUSet); err != nil {
+		c.closeDescriptors(c.closeAfterStart)
+		c.closeDescriptors(c.closeAfterWait)
+		return err
+	}
+	c.cpu = -1
+	if len(c.Sys.CPUSet) == 1 {
+		c.cpu = c.Sys.CPUSet[0]
+	}
+	if c.Scheduler != nil {
+		c.logger.Debug("waiting a free cpu")
+		emit(func(l Listener) { l.OnQueued(c) })
+		cpu, err := c.Scheduler.acquire(c.baseCtx)
 		if err != nil {
-			log.GetLog().Error("fail to get os.File struct with error: {}", err)
 			c.closeDescriptors(c.closeAfterStart)
 			c.closeDescriptors(c.closeAfterWait)
 			return err
 		}
-		c.childFiles = append(c.childFiles, fd)
+		c.logger.Debug("process will be pinned to cpu {}", cpu)
+		c.mark(TIMELINE_CPU_ACQUIRED, strconv.Itoa(cpu))
+		c.cpu = cpu
+		c.Sys.CPUSet = []int{cpu}
 	}
 
 	// set cpu time and clock time
 	if c.Sys.RlimitList[RLIMIT_CPU] == RLIMIT_UNRESOURCE && c.ResourceLimit.CpuTime != TIME_UNRESOURCE {
-		log.GetLog().Debug("cpu time limit will be set {}s", uint64(c.ResourceLimit.CpuTime/1000+1))
+		c.logger.Debug("cpu time limit will be set {}s", uint64(c.ResourceLimit.CpuTime/1000+1))
 		c.Sys.RlimitList[RLIMIT_CPU] = uint64(c.ResourceLimit.CpuTime/1000 + 1)
 		if c.ResourceLimit.ClockTime == TIME_UNRESOURCE {
-			log.GetLog().Info("Have set cpu time, but not set clock time, clock time will be set {}ms, to keep safe", c.ResourceLimit.CpuTime*10)
+			c.logger.Info("Have set cpu time, but not set clock time, clock time will be set {}ms, to keep safe", c.ResourceLimit.CpuTime*10)
 			if uint64(c.ResourceLimit.CpuTime*10) > uint64(MAX_TIME) {
 				c.ResourceLimit.ClockTime = MAX_TIME
 			} else {
@@ -260,7 +429,7 @@ func (c *Cmd) Start() error {
 			}
 		}
 		if c.ResourceLimit.ClockTime < c.ResourceLimit.CpuTime {
-			log.GetLog().Info("clock time limit is small than cpu time, will change clock time to", c.ResourceLimit.CpuTime+1)
+			c.logger.Info("clock time limit is small than cpu time, will change clock time to", c.ResourceLimit.CpuTime+1)
 			if uint64(c.ResourceLimit.CpuTime+1) > uint64(MAX_TIME) {
 				c.ResourceLimit.ClockTime = MAX_TIME
 			} else {
@@ -269,24 +438,47 @@ func (c *Cmd) Start() error {
 		}
 	}
 
+	// the earlier of context deadline and clock time limit will be used
+	if c.baseCtx != nil {
+		if deadline, ok := c.baseCtx.Deadline(); ok {
+			remain := uint(time.Until(deadline) / time.Millisecond)
+			if remain == 0 {
+				remain = 1
+			}
+			if c.ResourceLimit.ClockTime == TIME_UNRESOURCE || remain < c.ResourceLimit.ClockTime {
+				c.logger.Debug("clock time limit will be set {}ms by context deadline", remain)
+				c.ResourceLimit.ClockTime = remain
+			}
+		}
+	}
+
+	// std streams are counted by sandbox, this limits files created by the process
 	if c.Sys.RlimitList[RLIMIT_FSIZE] == RLIMIT_UNRESOURCE && c.ResourceLimit.Output != BYTE_UNRESOURCE {
 		c.Sys.RlimitList[RLIMIT_FSIZE] = c.ResourceLimit.Output + 1
 	}
 
-	log.GetLog().Debug("will start process")
+	setSubreaper()
+	c.tracked = make(map[int]uint64)
+	// limiter, copy goroutines and signal handlers may kill the process as soon as
+	// it starts, the grace timer of kill selects on it.
+	c.waitDone = make(chan struct{})
+
+	c.logger.Debug("will start process")
 	c.Process, err = c.startProcess()
 	if err != nil {
-		log.GetLog().Error("start process fail with error: {}", err)
+		clearSubreaper()
+		if c.Scheduler != nil {
+			c.Scheduler.release(c.cpu)
+		}
+		c.logger.Error("start process fail with error: {}", err)
 		c.closeDescriptors(c.closeAfterStart)
 		c.closeDescriptors(c.closeAfterWait)
 		return err
 	}
 
-	go c.SentSig()
+	registerSignal(c)
+	c.logger.Debug("process {} started", c.Process.Pid)
 	c.startTimestamp = time.Now()
-	if c.ResourceLimit.ClockTime != TIME_UNRESOURCE {
-		c.ctx, c.ctxCancel = context.WithTimeout(context.Background(), time.Duration(c.ResourceLimit.ClockTime)*time.Millisecond)
-	}
 	c.closeDescriptors(c.closeAfterStart)
 
 	go c.limiter()
@@ -300,12 +492,16 @@ func (c *Cmd) Start() error {
 		}
 	}
 
-	if c.ctx != nil {
-		c.waitDone = make(chan struct{})
+	if c.ResourceLimit.ClockTime != TIME_UNRESOURCE {
+		c.clockTimer = time.AfterFunc(time.Duration(c.ResourceLimit.ClockTime)*time.Millisecond, func() {
+			c.stopBy(EXCEED_CLOCK_TIME, false)
+		})
+	}
+	if c.baseCtx != nil {
 		go func() {
 			select {
-			case <-c.ctx.Done():
-				_ = c.Process.KillGroup()
+			case <-c.baseCtx.Done():
+				c.stopBy(EXCEED_CLOCK_TIME, c.baseCtx.Err() != context.DeadlineExceeded)
 			case <-c.waitDone:
 			}
 		}()
@@ -314,7 +510,28 @@ func (c *Cmd) Start() error {
 	return nil
 }
 
+// stopBy kill the process because clock time is exceeded or context is canceled.
+func (c *Cmd) stopBy(exceed int, cancelled bool) {
+	select {
+	case <-c.waitDone:
+		return
+	default:
+	}
+	c.mu.Lock()
+	if cancelled {
+		c.cancelled = true
+		c.markLocked(TIMELINE_CANCELLED, "")
+	} else if c.exceed == EXCEED_NONE {
+		c.exceed = exceed
+		c.markLocked(TIMELINE_LIMIT_EXCEEDED, EXCEED_STR[exceed])
+	}
+	c.mu.Unlock()
+	c.kill()
+}
+
 func (c *Cmd) NowUsed() Resource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return c.resourceStats
 }
 
@@ -329,18 +546,34 @@ func (c *Cmd) Wait() error {
 
 	state, err, pt := c.wait()
 	c.endTimestamp = time.Now()
-	if c.ctxCancel != nil {
-		c.ctxCancel()
+	c.mu.Lock()
+	if state != nil {
+		c.markLocked(TIMELINE_EXIT, state.String())
+	}
+	if c.paused {
+		c.pausedTime += c.endTimestamp.Sub(c.pauseTimestamp)
+		c.paused = false
+	}
+	c.mu.Unlock()
+	unregisterSignal(c)
+	// descendants may keep the pipes open, must be killed before waiting goroutines
+	c.reapDescendants()
+	clearSubreaper()
+	if c.Scheduler != nil {
+		c.Scheduler.release(c.cpu)
+	}
+	close(c.waitDone)
+	if c.clockTimer != nil {
+		c.clockTimer.Stop()
 	}
 
 	if err != nil {
+		emit(func(l Listener) { l.OnFinish(c, nil) })
 		return err
 	}
-	if c.waitDone != nil {
-		close(c.waitDone)
-	}
 	c.ProcessState = state
 	c.pt = pt
+	c.logger.Debug("process {} exited with status {}", c.Process.Pid, state.status)
 
 	var copyError error
 	for range c.goroutine {
@@ -355,29 +588,118 @@ func (c *Cmd) Wait() error {
 		return err
 	}
 
+	var r *Result
+	emit(func(l Listener) {
+		if r == nil {
+			r = c.Result()
+		}
+		l.OnFinish(c, r)
+	})
 	return copyError
 }
 
 func (c *Cmd) Result() *Result {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	r := &Result{
 		CpuTime:    uint(c.ProcessState.rusage.Utime.Nano()+c.ProcessState.rusage.Stime.Nano()) / 1e6,
-		ClockTime:  uint(c.endTimestamp.UnixNano()-c.startTimestamp.UnixNano()) / 1e6,
-		MemoryUsed: c.resourceStats.Memory,
+		ClockTime:  uint((c.endTimestamp.Sub(c.startTimestamp) - c.pausedTime) / time.Millisecond),
+		MemoryUsed: c.resourceMaxStats.Memory,
 		ExitStatus: c.ProcessState.status,
 		ExitCode:   c.ProcessState.ExitCode(),
+		Exceed:     c.exceed,
+		Output:     c.resourceStats.Output,
+		CPU:        c.cpu,
+		Syscall:    -1,
+		Log:        c.logger.Events(),
+		Timeline:   c.sortedTimeline(),
 	}
+	if c.pt != nil {
+		r.Syscall = int(c.pt.SyscallNo)
+		r.SyscallArch = c.pt.Arch
+	}
+	if c.binary != nil {
+		r.Binary = *c.binary
+	}
+	r.OutputTruncated = c.outputTruncated
+	if r.Exceed == EXCEED_NONE && c.ResourceLimit.CpuTime != TIME_UNRESOURCE && r.CpuTime > c.ResourceLimit.CpuTime {
+		r.Exceed = EXCEED_CPU_TIME
+	}
+	r.Verdict = c.verdict(r)
 
 	return r
 }
 
+func (c *Cmd) verdict(r *Result) int {
+	if c.cancelled {
+		return VERDICT_CANCELLED
+	}
+	switch r.Exceed {
+	case EXCEED_CPU_TIME, EXCEED_CLOCK_TIME:
+		return VERDICT_TIME_LIMIT_EXCEEDED
+	case EXCEED_MEMORY:
+		return VERDICT_MEMORY_LIMIT_EXCEEDED
+	case EXCEED_THREAD:
+		return VERDICT_THREAD_LIMIT_EXCEEDED
+	case EXCEED_OUTPUT:
+		return VERDICT_OUTPUT_LIMIT_EXCEEDED
+	}
+	if c.pt != nil {
+		return VERDICT_BAD_SYSCALL
+	}
+	if r.ExitStatus.Signaled() {
+		switch r.ExitStatus.Signal() {
+		case syscall.SIGSYS:
+			// killed by seccomp, like a syscall of an arch not in the filter
+			return VERDICT_BAD_SYSCALL
+		case syscall.SIGXCPU:
+			return VERDICT_TIME_LIMIT_EXCEEDED
+		case syscall.SIGXFSZ:
+			return VERDICT_OUTPUT_LIMIT_EXCEEDED
+		}
+		return VERDICT_RUNTIME_ERROR
+	}
+	if r.ExitCode != 0 {
+		return VERDICT_RUNTIME_ERROR
+	}
+	return VERDICT_OK
+}
+
+// Run starts the process and waits for it on one locked thread,
+// because the tracer must be the thread which starts the process.
 func (c *Cmd) Run() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 	if err := c.Start(); err != nil {
 		return err
 	}
 	return c.Wait()
 }
 
+// seccompEnabled tells if a seccomp filter will be loaded.
+func (c *Cmd) seccompEnabled() bool {
+	return c.Syscall != nil && (c.Syscall.Helper != "" || c.Syscall.Level != 0)
+}
+
+// scmpArches returns arches added to the filter besides the native arch.
+func (c *Cmd) scmpArches() []string {
+	arches := append([]string{}, c.Syscall.Arches...)
+	// rules of the arch of binary are needed, or its syscalls are not filtered
+	if c.binary != nil {
+		arches = append(arches, c.binary.Arch)
+	}
+	seen := map[string]bool{NativeArch(): true, "native": true}
+	out := arches[:0]
+	for _, arch := range arches {
+		if !seen[arch] {
+			seen[arch] = true
+			out = append(out, arch)
+		}
+	}
+	return out
+}
+
 func (c *Cmd) startProcess() (*Process, error) {
 
 	path0, err := syscall.BytePtrFromString(c.Path)
@@ -388,10 +710,7 @@ func (c *Cmd) startProcess() (*Process, error) {
 	if err != nil {
 		return nil, err
 	}
-	if len(c.Envs) == 0 {
-		c.Envs = os.Environ()
-	}
-	envsp, err := syscall.SlicePtrFromStrings(c.Envs)
+	envsp, err := syscall.SlicePtrFromStrings(c.envv())
 	if err != nil {
 		return nil, err
 	}
@@ -423,8 +742,13 @@ func (c *Cmd) startProcess() (*Process, error) {
 		}
 	}
 
-	if c.Syscall != nil && c.Syscall.Helper != "" && c.Syscall.Level != 0 {
-		scmpHelper := &scmpFilter.ScmpFilterLoadHelper{ExecvePathPointer: unsafe.Pointer(path0), Action: scmpFilter.ScmpAction(c.Syscall.Action)}
+	if c.seccompEnabled() {
+		scmpHelper := &scmpFilter.ScmpFilterLoadHelper{
+			ExecvePathPointer: unsafe.Pointer(path0),
+			Action:            scmpFilter.ScmpAction(c.Syscall.Action),
+			Arches:            c.scmpArches(),
+			BadArchAction:     scmpFilter.ScmpAction(c.Syscall.BadArchAction),
+		}
 		if c.Syscall.Helper != "" {
 			scmpHelper.LrunScmpFilter = c.Syscall.Helper
 			scmpHelper.Level = -1
@@ -438,26 +762,14 @@ func (c *Cmd) startProcess() (*Process, error) {
 		attr.Bpf = filter.BPF
 		attr.Ptrace = filter.SetPrivs
 	}
+	c.traced = attr.Ptrace
 
-	pid, err := forkExec(path0, argsp, envsp, chroot, chdir, attr)
+	pid, steps, err := forkExec(path0, argsp, envsp, chroot, chdir, attr, c.logger)
+	c.markSteps(steps)
 	if err != nil {
-		log.GetLog().Error("exec fail with error: {}", err.Error())
-		return nil, errors.New(err.Error())
+		c.logger.Error("exec fail with error: {}", err.Error())
+		return nil, err
 	}
+	c.mark(TIMELINE_EXEC, "")
 	return newProcess(pid, 0), nil
 }
-
-func (c *Cmd) SentSig() {
-	c.sigchan = make(chan os.Signal)
-	signal.Notify(c.sigchan, syscall.SIGINT, syscall.SIGTERM)
-
-	for sig, ok := <-c.sigchan; (!c.Process.Done()) && ok; {
-		err := c.Process.Signal(sig)
-		if err != nil {
-			log.GetLog().Warning("sent sig meet error: {}", err)
-		}
-	}
-	signal.Stop(c.sigchan)
-	close(c.sigchan)
-	c.sigchan = nil
-}
@@ -0,0 +1,117 @@
+//+build linux
+
+package exec
+
+import (
+	"github.com/sdibtacm/sandbox/units/pstree"
+	"syscall"
+	"time"
+)
+
+// KillPolicy decides how the sandbox kill the process when a limit is exceeded,
+// the context is canceled or the other side of a Pair failed.
+//
+// Signal is sent first, if the process is still alive after Grace, SIGKILL is sent.
+// A nil KillPolicy or a zero Signal means SIGKILL at once.
+type KillPolicy struct {
+	Signal syscall.Signal
+	Grace  time.Duration
+	// Tree also sends Signal to every descendant found in process tree, not only
+	// the process group, because children can leave the group by setsid. SIGKILL
+	// is always sent to every descendant.
+	Tree bool
+}
+
+// kill the process by KillPolicy, it is safe to be called many times.
+func (c *Cmd) kill() {
+	p := c.KillPolicy
+	if p == nil || p.Signal == 0 || p.Signal == syscall.SIGKILL {
+		c.signalAll(syscall.SIGKILL)
+		return
+	}
+
+	c.mu.Lock()
+	first := !c.killing
+	c.killing = true
+	c.mu.Unlock()
+	if !first {
+		return
+	}
+
+	c.logger.Debug("sent {} to {}, will kill after {}", p.Signal, c.Process.Pid, p.Grace)
+	c.signalAll(p.Signal)
+	go func() {
+		t := time.NewTimer(p.Grace)
+		defer t.Stop()
+		select {
+		case <-t.C:
+			c.signalAll(syscall.SIGKILL)
+		case <-c.waitDone:
+		}
+	}()
+}
+
+// signalAll sent sig to the process, its process group, and descendants if KillPolicy.Tree.
+func (c *Cmd) signalAll(sig syscall.Signal) {
+	c.signal(sig, c.KillPolicy != nil && c.KillPolicy.Tree)
+}
+
+// signalTree sent sig to the process, its process group, and all descendants.
+func (c *Cmd) signalTree(sig syscall.Signal) {
+	c.signal(sig, true)
+}
+
+func (c *Cmd) signal(sig syscall.Signal, tree bool) {
+	first := c.markSignal(sig)
+	var pids []int
+	// descendants killed are tracked, or they are lost after being reparented
+	// to sandbox when the process dies, and left after Wait.
+	if tree || (sig == syscall.SIGKILL && first) {
+		if pt, err := pstree.New(); err != nil {
+			c.logger.Warning("pstree scan error: {}", err)
+		} else {
+			pids = c.runPids(pt)
+			c.mu.Lock()
+			for _, pid := range pids {
+				c.tracked[pid] = uint64(pt.Procs[pid].Stat.Starttime)
+			}
+			c.mu.Unlock()
+		}
+	}
+
+	_ = c.Process.SignalGroup(sig)
+	_ = c.Process.Signal(sig)
+	for _, pid := range pids {
+		if pid != c.Process.Pid {
+			_ = syscall.Kill(pid, sig)
+		}
+	}
+}
+
+// runPids returns the process, processes seen in the run which are still alive,
+// and all their descendants found in pt. Processes seen may have been reparented
+// to sandbox, they are not descendants of the process any more.
+func (c *Cmd) runPids(pt *pstree.Tree) []int {
+	queue := []int{c.Process.Pid}
+	c.mu.Lock()
+	for pid, starttime := range c.tracked {
+		if proc, ok := pt.Procs[pid]; ok && pid != c.Process.Pid && uint64(proc.Stat.Starttime) == starttime {
+			queue = append(queue, pid)
+		}
+	}
+	c.mu.Unlock()
+
+	var pids []int
+	seen := make(map[int]bool)
+	for i := 0; i < len(queue); i++ {
+		pid := queue[i]
+		proc, ok := pt.Procs[pid]
+		if !ok || seen[pid] {
+			continue
+		}
+		seen[pid] = true
+		pids = append(pids, pid)
+		queue = append(queue, proc.Children...)
+	}
+	return pids
+}
@@ -0,0 +1,126 @@
+// +build linux
+
+package exec
+
+import (
+	"context"
+	"fmt"
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// signalsSent returns signals recorded in the timeline of r in order.
+func signalsSent(r *Result) []string {
+	var sigs []string
+	for _, e := range r.Timeline {
+		if e.Event == TIMELINE_SIGNAL_SENT {
+			sigs = append(sigs, e.Info)
+		}
+	}
+	return sigs
+}
+
+func TestKillPolicyGrace(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	for _, tt := range []struct {
+		name    string
+		script  string
+		signal  syscall.Signal // signal the process exits by, 0 if exits by itself
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		// SIGTERM is ignored, then SIGKILL is sent after grace
+		{"ignored", `trap '' TERM; sleep 30`, syscall.SIGKILL, 200*time.Millisecond + 500*time.Millisecond, 5 * time.Second},
+		// the process exits by itself before grace
+		{"handled", `trap 'exit 7' TERM; while :; do sleep 0.05; done`, 0, 200 * time.Millisecond, 3 * time.Second},
+	} {
+		c := Command("/bin/sh", "-c", tt.script)
+		c.ResourceLimit.ClockTime = 200
+		c.KillPolicy = &KillPolicy{Signal: syscall.SIGTERM, Grace: 500 * time.Millisecond}
+		start := time.Now()
+		if err := c.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		d := time.Since(start)
+		r := c.Result()
+		if r.Exceed != EXCEED_CLOCK_TIME || d < tt.minWait || d > tt.maxWait {
+			t.Errorf("%s: exceed = %s, run takes %v, want clock time in [%v, %v]", tt.name, EXCEED_STR[r.Exceed], d, tt.minWait, tt.maxWait)
+		}
+		if tt.signal != 0 {
+			if !r.ExitStatus.Signaled() || r.ExitStatus.Signal() != tt.signal {
+				t.Errorf("%s: exit status = %v, want signaled by %v", tt.name, r.ExitStatus, tt.signal)
+			}
+			if sigs := signalsSent(r); len(sigs) != 2 || sigs[0] != syscall.SIGTERM.String() || sigs[1] != syscall.SIGKILL.String() {
+				t.Errorf("%s: signals sent = %q, want SIGTERM and SIGKILL", tt.name, sigs)
+			}
+		} else if r.ExitCode != 7 {
+			t.Errorf("%s: exit code = %d, want 7", tt.name, r.ExitCode)
+		}
+	}
+}
+
+func TestKillPolicyTree(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestKillPolicyTree")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tree := range []bool{true, false} {
+		mark := filepath.Join(dir, fmt.Sprint(tree))
+		// the grandchild leaves the process group by setsid, it writes mark
+		// when it gets SIGTERM, the shell ignores SIGTERM.
+		c := Command("/bin/sh", "-c", fmt.Sprintf(
+			`setsid sh -c 'trap "echo term > %s; exit" TERM; while :; do sleep 0.05; done' & trap '' TERM; wait`, mark))
+		c.ResourceLimit.ClockTime = 300
+		c.KillPolicy = &KillPolicy{Signal: syscall.SIGTERM, Grace: 500 * time.Millisecond, Tree: tree}
+		if err := c.Run(); err != nil {
+			t.Fatalf("tree %v: run with error: %v", tree, err)
+		}
+		if r := c.Result(); r.Exceed != EXCEED_CLOCK_TIME {
+			t.Errorf("tree %v: exceed = %s, want clock time", tree, EXCEED_STR[r.Exceed])
+		}
+		_, err := os.Stat(mark)
+		if got := err == nil; got != tree {
+			t.Errorf("tree %v: grandchild gets SIGTERM = %v", tree, got)
+		}
+	}
+}
+
+// kill right after Start, the grace timer must see the process is waited,
+// run it with -race.
+func TestKillPolicyAfterStart(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	grace := 300 * time.Millisecond
+
+	for _, name := range []string{"limiter", "context"} {
+		ctx, cancel := context.WithCancel(context.Background())
+		c := CommandContext(ctx, "/bin/sh", "-c", "sleep 5")
+		c.Stdout = ioutil.Discard
+		c.KillPolicy = &KillPolicy{Signal: syscall.SIGTERM, Grace: grace}
+		if name == "limiter" {
+			// exceeded at the first sample of limiter
+			c.ResourceLimit.Memory = 1
+		}
+		done := startLocked(t, c)
+		if name == "context" {
+			cancel()
+		}
+		if err := <-done; err != nil {
+			t.Errorf("%s: wait with error: %v", name, err)
+		}
+		cancel()
+		time.Sleep(grace + 200*time.Millisecond)
+		if sigs := signalsSent(c.Result()); len(sigs) != 1 || sigs[0] != syscall.SIGTERM.String() {
+			t.Errorf("%s: signals sent = %q, want only SIGTERM", name, sigs)
+		}
+	}
+}
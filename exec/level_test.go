@@ -0,0 +1,81 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSyscallLevel(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestSyscallLevel")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	const (
+		sum      = `read a b; echo $((a+b))`
+		readOnly = `read x < /dev/null; echo 3`
+		write    = `echo 3 > out; cat out`
+		fork     = `/bin/true; echo 3`
+	)
+	for _, tt := range []struct {
+		level   int
+		action  int
+		script  string
+		verdict int
+	}{
+		{scmpFilter.LEVEL_BASIC, 1, sum, VERDICT_OK},
+		{scmpFilter.LEVEL_BASIC, 1, readOnly, VERDICT_OK},
+		{scmpFilter.LEVEL_BASIC, 1, write, VERDICT_BAD_SYSCALL},
+		// killed by seccomp instead of traced
+		{scmpFilter.LEVEL_BASIC, 0, write, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_THREAD, 1, fork, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_FILE, 1, `echo 3 > out; read x < out; echo $x`, VERDICT_OK},
+		{scmpFilter.LEVEL_FILE, 1, fork, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_PROCESS, 1, fork, VERDICT_OK},
+		{scmpFilter.LEVEL_PROCESS, 1, write, VERDICT_OK},
+		{scmpFilter.LEVEL_ALL, 1, write, VERDICT_OK},
+		// the root process is traced, so exec instead of fork
+		{scmpFilter.LEVEL_ALL, 1, `exec /usr/bin/unshare -U /bin/true`, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_ALL, 1, fork, VERDICT_OK},
+		{scmpFilter.LEVEL_ALL, 1, `exec perl -e 'require "syscall.ph"; syscall(&SYS_clone, 0x10000011, 0, 0, 0, 0)'`, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_ALL, 1, `exec perl -e '$c = "x"; ioctl(STDIN, 0x5412, $c)'`, VERDICT_BAD_SYSCALL},
+		{scmpFilter.LEVEL_PROCESS, 1, `exec perl -e '$c = ""; ioctl(STDIN, 0x5401, $c); print "3\n"'`, VERDICT_OK},
+		// the kernel takes the low 32 bits, so it is TIOCSTI
+		{scmpFilter.LEVEL_PROCESS, 1, `exec perl -e '$c = "x"; ioctl(STDIN, 0x100005412, $c)'`, VERDICT_BAD_SYSCALL},
+	} {
+		var stdout bytes.Buffer
+		c := Command("/bin/sh", "-c", tt.script)
+		c.Chdir = dir
+		c.Stdin = strings.NewReader("1 2\n")
+		c.Stdout = &stdout
+		c.ResourceLimit.ClockTime = 5000
+		c.Syscall = &SyscallLimit{Level: tt.level, Action: tt.action}
+		name := scmpFilter.LEVEL_STR[tt.level] + ": " + tt.script
+		if err := c.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", name, err)
+			continue
+		}
+		r := c.Result()
+		if r.Verdict != tt.verdict {
+			t.Errorf("%s: verdict = %s, syscall = %d, want %s", name, VERDICT_STR[r.Verdict], r.Syscall, VERDICT_STR[tt.verdict])
+		}
+		if tt.verdict == VERDICT_OK && stdout.String() != "3\n" {
+			t.Errorf("%s: stdout = %q, want 3", name, stdout.String())
+		}
+	}
+
+	c := Command("/bin/true")
+	c.Syscall = &SyscallLimit{Level: scmpFilter.LEVEL_MAX + 1}
+	if err := c.Run(); err == nil {
+		t.Error("unknown level is accepted")
+	}
+}
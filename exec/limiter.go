@@ -11,13 +11,14 @@ int tickPreSec() {
 import "C"
 
 import (
-	"github.com/sdibtacm/sandbox/exec/log"
 	"github.com/sdibtacm/sandbox/units/pstree"
+	"os"
 	"time"
 )
 
 var tickPerSec int
 var kernelTimeMod uint
+var pageSize = uint64(os.Getpagesize())
 
 func init() {
 	tickPerSec = int(C.tickPreSec())
@@ -26,33 +27,62 @@ func init() {
 
 func (c *Cmd) limiter() {
 	var used Resource
-	for !c.Process.Done() {
-		_ = calcUsed(&used, c.Process.Pid)
-		//time.Sleep(5 * time.Microsecond)
-		c.resourceStats.ClockTime = uint((time.Now().UnixNano() - c.startTimestamp.UnixNano()) / 1e6)
+	seen := make(map[int]uint64)
+	for first := true; !c.Process.Done(); first = false {
+		if err := calcUsed(&used, c.Process.Pid, seen, c.MemoryMetric); err != nil {
+			c.logger.Warning("pstree scan error: {}", err)
+		}
+		if first {
+			c.mark(TIMELINE_FIRST_SAMPLE, "")
+		}
+
+		c.mu.Lock()
+		for pid, starttime := range seen {
+			c.tracked[pid] = starttime
+			delete(seen, pid)
+		}
+		c.resourceStats.ClockTime = uint(c.activeTime(time.Now()) / time.Millisecond)
 		c.resourceStats.Thread = used.Thread
 		c.resourceStats.Memory = used.Memory
 		c.resourceStats.CpuTime = used.CpuTime
+		c.updateMaxResource()
+		kill := c.checkResource()
+		c.mu.Unlock()
+
+		if kill {
+			c.kill()
+		}
 		used.Memory = 0
 		used.Thread = 0
 		used.CpuTime = 0
-		go c.updateMaxResource()
-		go c.checkResource()
+		time.Sleep(LIMITER_INTERVAL)
 	}
 }
 
-func (c *Cmd) checkResource() {
+// checkResource reports whether the process should be killed, c.mu must be held.
+func (c *Cmd) checkResource() bool {
+	exceed := EXCEED_NONE
 	if c.ResourceLimit.Memory != BYTE_UNRESOURCE && c.resourceMaxStats.Memory > c.ResourceLimit.Memory {
-		_ = c.Process.KillGroup()
+		exceed = EXCEED_MEMORY
 	}
-	if c.resourceMaxStats.Thread > c.ResourceLimit.Thread {
-		_ = c.Process.KillGroup()
+	if c.ResourceLimit.Thread != 0 && c.resourceMaxStats.Thread > c.ResourceLimit.Thread {
+		exceed = EXCEED_THREAD
 	}
 	if c.ResourceLimit.CpuTime != TIME_UNRESOURCE && c.resourceMaxStats.CpuTime > c.ResourceLimit.CpuTime {
-		_ = c.Process.KillGroup()
+		exceed = EXCEED_CPU_TIME
+	}
+	if exceed == EXCEED_NONE {
+		return false
+	}
+	if c.exceed == EXCEED_NONE {
+		c.exceed = exceed
+		c.logger.Info("{} limit exceeded, max usage: {}, will kill", EXCEED_STR[exceed], c.resourceMaxStats)
+		c.markLocked(TIMELINE_LIMIT_EXCEEDED, EXCEED_STR[exceed])
 	}
+	return true
 }
 
+// updateMaxResource c.mu must be held.
 func (c *Cmd) updateMaxResource() {
 	if c.resourceMaxStats.Memory < c.resourceStats.Memory {
 		c.resourceMaxStats.Memory = c.resourceStats.Memory
@@ -65,10 +95,11 @@ func (c *Cmd) updateMaxResource() {
 	}
 }
 
-func calcUsed(r *Resource, rootPid int) error {
+// calcUsed sum usage of rootPid and its descendants, memory is measured by
+// metric, every process found will be put into seen with its start time.
+func calcUsed(r *Resource, rootPid int, seen map[int]uint64, metric int) error {
 	pt, err := pstree.New()
 	if err != nil {
-		log.GetLog().Warning("pstree scan error: {}", err)
 		return err
 	}
 	procs := pt.Procs
@@ -79,8 +110,17 @@ func calcUsed(r *Resource, rootPid int) error {
 		if len(procs[pid].Children) > 0 {
 			pids = append(pids, procs[pid].Children...)
 		}
+		if procs[pid].Stat.Pid != 0 {
+			seen[pid] = uint64(procs[pid].Stat.Starttime)
+		}
 		r.CpuTime += uint(procs[pid].Stat.Stime+procs[pid].Stat.Utime) * kernelTimeMod
-		r.Memory += procs[pid].Stat.Vsize
+		if metric == MEMORY_METRIC_RSS {
+			if procs[pid].Stat.Rss > 0 {
+				r.Memory += uint64(procs[pid].Stat.Rss) * pageSize
+			}
+		} else {
+			r.Memory += procs[pid].Stat.Vsize
+		}
 		r.Thread += uint(procs[pid].Stat.Nthreads)
 	}
 	return nil
@@ -1,10 +1,25 @@
 package log
 
-import "github.com/boxjan/golib/logs"
+import (
+	"github.com/boxjan/golib/logs"
+	"sync"
+)
 
-var log *logs.Logger
+var (
+	log *logs.Logger
+	mu  sync.RWMutex
+)
 
 func GetLog() *logs.Logger {
+	mu.RLock()
+	l := log
+	mu.RUnlock()
+	if l != nil {
+		return l
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
 	if log == nil {
 		log = logs.NewLoggerWithCmdWriterWithTraceLevel()
 		log.Warning("You did't not set before, so new one")
@@ -13,6 +28,8 @@ func GetLog() *logs.Logger {
 }
 
 func SetLog(logger *logs.Logger) {
+	mu.Lock()
+	defer mu.Unlock()
 	if log != nil {
 		log.Warning("logger will be change")
 	}
@@ -20,6 +37,8 @@ func SetLog(logger *logs.Logger) {
 }
 
 func CloseLog() {
+	mu.Lock()
+	defer mu.Unlock()
 	if log != nil {
 		log.Warning("will stop use this logger now")
 		log = nil
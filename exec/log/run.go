@@ -0,0 +1,172 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/boxjan/golib/logs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Field is a key value pair added to every message of a RunLogger.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// Event is a captured log message, it is encoded as one line of JSON.
+type Event struct {
+	Time   time.Time              `json:"time"`
+	Level  string                 `json:"level"`
+	Run    string                 `json:"run"`
+	Msg    string                 `json:"msg"`
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// RunLogger is the logger of one run, every message is prefixed with the run
+// id and fields, and can be captured as JSON lines. A nil RunLogger writes to
+// the global logger without prefix.
+type RunLogger struct {
+	logger *logs.Logger // nil to use the global logger
+	id     string
+	fields []Field
+	prefix string
+
+	mu      sync.Mutex
+	capture *bytes.Buffer // nil if not capture
+}
+
+// NewRunLogger returns a RunLogger writes to logger, the global logger is used if logger is nil.
+func NewRunLogger(logger *logs.Logger, id string, fields ...Field) *RunLogger {
+	l := &RunLogger{logger: logger, id: id, fields: fields}
+
+	var prefix strings.Builder
+	prefix.WriteString("[run " + id)
+	for _, f := range fields {
+		fmt.Fprintf(&prefix, " %s=%+v", f.Key, f.Value)
+	}
+	prefix.WriteString("] ")
+	l.prefix = prefix.String()
+	return l
+}
+
+func (l *RunLogger) ID() string {
+	if l == nil {
+		return ""
+	}
+	return l.id
+}
+
+// Capture makes all messages, including debug ones, captured.
+func (l *RunLogger) Capture() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.capture == nil {
+		l.capture = new(bytes.Buffer)
+	}
+}
+
+// Events returns captured messages as JSON lines, nil if not capture.
+func (l *RunLogger) Events() []byte {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.capture == nil {
+		return nil
+	}
+	return append([]byte(nil), l.capture.Bytes()...)
+}
+
+func (l *RunLogger) Debug(message string, args ...interface{}) {
+	l.log(logs.LevelDebugStr, formatMessage(message, args...))
+}
+
+func (l *RunLogger) Info(message string, args ...interface{}) {
+	l.log(logs.LevelInfoStr, formatMessage(message, args...))
+}
+
+func (l *RunLogger) Warning(message string, args ...interface{}) {
+	l.log(logs.LevelWarningStr, formatMessage(message, args...))
+}
+
+func (l *RunLogger) Error(message string, args ...interface{}) {
+	l.log(logs.LevelErrorStr, formatMessage(message, args...))
+}
+
+func (l *RunLogger) DebugF(message string, args ...interface{}) {
+	l.log(logs.LevelDebugStr, fmt.Sprintf(message, args...))
+}
+
+func (l *RunLogger) log(level string, msg string) {
+	logger := GetLog()
+	if l != nil && l.logger != nil {
+		logger = l.logger
+	}
+	prefix := ""
+	if l != nil {
+		prefix = l.prefix
+		l.record(level, msg)
+	}
+	// message has been formatted, it must not be parsed again
+	switch level {
+	case logs.LevelDebugStr:
+		logger.Debug("{}", prefix+msg)
+	case logs.LevelInfoStr:
+		logger.Info("{}", prefix+msg)
+	case logs.LevelWarningStr:
+		logger.Warning("{}", prefix+msg)
+	default:
+		logger.Error("{}", prefix+msg)
+	}
+}
+
+func (l *RunLogger) record(level string, msg string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.capture == nil {
+		return
+	}
+	e := Event{Time: time.Now(), Level: level, Run: l.id, Msg: msg}
+	if len(l.fields) > 0 {
+		e.Fields = make(map[string]interface{}, len(l.fields))
+		for _, f := range l.fields {
+			e.Fields[f.Key] = f.Value
+		}
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	l.capture.Write(b)
+	l.capture.WriteByte('\n')
+}
+
+// formatMessage formats message like logs.Logger, `{}` is the place of an arg.
+func formatMessage(message string, args ...interface{}) string {
+	places := strings.Count(message, "{}")
+	for i := places; i < len(args); i++ {
+		message += " {}"
+	}
+	for len(args) < places {
+		args = append(args, "[No thing]")
+	}
+	return fmt.Sprintf(strings.Replace(strings.Replace(message, "%", "%%", -1), "{}", "%+v", -1), args...)
+}
+
+// ParseEvents parses captured JSON lines.
+func ParseEvents(b []byte) ([]Event, error) {
+	var events []Event
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var e Event
+		if err := dec.Decode(&e); err != nil {
+			return events, err
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
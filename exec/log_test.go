@@ -0,0 +1,60 @@
+// +build linux
+
+package exec
+
+import (
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec/log"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCaptureLog(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			id := "run-" + strconv.Itoa(i)
+			c := Command("/bin/sh", "-c", "exit "+strconv.Itoa(i))
+			c.ID = id
+			c.LogFields = []log.Field{{Key: "problem", Value: 1000 + i}}
+			c.CaptureLog = true
+			if err := c.Run(); err != nil {
+				t.Errorf("%s: %v", id, err)
+				return
+			}
+
+			events, err := log.ParseEvents(c.Result().Log)
+			if err != nil {
+				t.Errorf("%s: parse events: %v", id, err)
+				return
+			}
+			var started, exited bool
+			for _, e := range events {
+				if e.Run != id || e.Fields["problem"] != float64(1000+i) {
+					t.Errorf("%s: event of other run: %+v", id, e)
+				}
+				// debug messages are captured even if the logger level is warning
+				started = started || e.Level == logs.LevelDebugStr && strings.HasPrefix(e.Msg, "process ") && strings.HasSuffix(e.Msg, " started")
+				exited = exited || strings.Contains(e.Msg, "exited with status")
+			}
+			if !started || !exited {
+				t.Errorf("%s: events miss start or exit: %+v", id, events)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	c := Command("/bin/true")
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID == "" || c.Result().Log != nil {
+		t.Errorf("ID = %q, Log = %q, want a sequence ID and no log", c.ID, c.Result().Log)
+	}
+}
@@ -3,11 +3,16 @@
 package exec
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 type LpError struct {
@@ -15,12 +20,26 @@ type LpError struct {
 	Name string
 	// Err is the underlying error.
 	Err error
+	// Root is the root searched in, empty if the process is not chrooted.
+	Root string
 }
 
 func (e *LpError) Error() string {
+	if e.Root != "" {
+		return "exec: " + strconv.Quote(e.Name) + ": " + e.Err.Error() + " in root " + e.Root
+	}
 	return "exec: " + strconv.Quote(e.Name) + ": " + e.Err.Error()
 }
 
+const (
+	// max symlinks followed when resolving a path in root, same as linux
+	MAX_SYMLINKS = 40
+	// max nested interpreters of a script, same as linux
+	MAX_INTERPRETER_DEPTH = 4
+	// max length of shebang line
+	SHEBANG_MAX = 256
+)
+
 // Copyright 2010 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
@@ -53,7 +72,7 @@ func LookPath(file string) (string, error) {
 		if err == nil {
 			return file, nil
 		}
-		return "", &LpError{file, err}
+		return "", &LpError{Name: file, Err: err}
 	}
 	path := os.Getenv("PATH")
 	for _, dir := range filepath.SplitList(path) {
@@ -66,5 +85,197 @@ func LookPath(file string) (string, error) {
 			return path, nil
 		}
 	}
-	return "", &LpError{file, ErrNotFound}
+	return "", &LpError{Name: file, Err: ErrNotFound}
+}
+
+// rootLookup finds executables as the process will see them after chroot and chdir.
+type rootLookup struct {
+	root string // chroot, empty if not chrooted
+	dir  string // work dir in root, empty means the current dir of host
+}
+
+func (c *Cmd) rootLookup() *rootLookup {
+	l := &rootLookup{root: c.Chroot, dir: c.Chdir}
+	if l.root != "" {
+		// the process is not chdir to root after chroot, but relative path
+		// out of the root makes no sense, so treat it as /
+		if !filepath.IsAbs(l.dir) {
+			l.dir = "/" + l.dir
+		}
+	} else if l.dir != "" {
+		if dir, err := filepath.Abs(l.dir); err == nil {
+			l.dir = dir
+		}
+	}
+	return l
+}
+
+// inside returns the path seen by the process, it is absolute if work dir is known.
+func (l *rootLookup) inside(p string) string {
+	if filepath.IsAbs(p) || l.dir == "" {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(l.dir, p)
+}
+
+// host returns the path of p on host.
+func (l *rootLookup) host(p string) (string, error) {
+	if l.root == "" {
+		return p, nil
+	}
+	return resolveInRoot(l.root, p)
+}
+
+// lookPath is like LookPath, but file is searched in root, by PATH in envs,
+// and relative to the work dir. It returns the path seen by the process.
+func (l *rootLookup) lookPath(file string, envs []string) (string, error) {
+	// like execvp, PATH is searched unless file has a slash,
+	// so files in the work dir never shadow tools in PATH
+	if strings.Contains(file, "/") {
+		p := l.inside(file)
+		if err := l.executable(p, 0); err != nil {
+			return "", &LpError{Name: file, Err: err, Root: l.root}
+		}
+		return p, nil
+	}
+
+	pathEnv, ok := "", false
+	for _, env := range envs {
+		if strings.HasPrefix(env, "PATH=") {
+			pathEnv, ok = env[len("PATH="):], true
+		}
+	}
+	if !ok {
+		pathEnv = DEFAULT_ENV_PATH
+	}
+	var lastErr error = ErrNotFound
+	for _, dir := range filepath.SplitList(pathEnv) {
+		if dir == "" {
+			// Unix shell semantics: path element "" means "."
+			dir = "."
+		}
+		p := l.inside(filepath.Join(dir, file))
+		err := l.executable(p, 0)
+		if err == nil {
+			return p, nil
+		}
+		// report a bad interpreter instead of not found
+		if _, ok := err.(*interpreterError); ok {
+			lastErr = err
+		}
+	}
+	return "", &LpError{Name: file, Err: lastErr, Root: l.root}
+}
+
+type interpreterError struct {
+	Interpreter string
+	Err         error
+}
+
+func (e *interpreterError) Error() string {
+	return "bad interpreter " + strconv.Quote(e.Interpreter) + ": " + e.Err.Error()
+}
+
+// executable checks p in root is executable, and its interpreter if it is a script.
+func (l *rootLookup) executable(p string, depth int) error {
+	hostPath, err := l.host(p)
+	if err != nil {
+		return err
+	}
+	if err = executable(hostPath); err != nil {
+		return err
+	}
+
+	interp, err := readShebang(hostPath)
+	if err != nil || interp == "" {
+		return err
+	}
+	if depth >= MAX_INTERPRETER_DEPTH {
+		return &interpreterError{interp, syscall.ELOOP}
+	}
+	if err = l.executable(l.inside(interp), depth+1); err != nil {
+		if ie, ok := err.(*interpreterError); ok {
+			return ie
+		}
+		return &interpreterError{interp, err}
+	}
+	return nil
+}
+
+// readShebang returns the interpreter of a script, empty if file is not a script.
+func readShebang(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		// executable but not readable, let exec decide
+		return "", nil
+	}
+	defer f.Close()
+
+	buf := make([]byte, SHEBANG_MAX)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", nil
+	}
+	buf = buf[:n]
+	if !bytes.HasPrefix(buf, []byte("#!")) {
+		return "", nil
+	}
+	line := buf[2:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return "", &interpreterError{"", errors.New("empty shebang")}
+	}
+	return fields[0], nil
+}
+
+// resolveInRoot returns the host path of p in root. Symlinks are followed as
+// if root is /, so absolute links and .. will not escape the root.
+func resolveInRoot(root, p string) (string, error) {
+	resolved := "/"
+	rest := strings.Split(p, "/")
+	links := 0
+	for len(rest) > 0 {
+		name := rest[0]
+		rest = rest[1:]
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			resolved = path.Dir(resolved)
+			continue
+		}
+
+		next := path.Join(resolved, name)
+		fi, err := os.Lstat(filepath.Join(root, next))
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", next, underlyingError(err))
+		}
+		if fi.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+
+		if links++; links > MAX_SYMLINKS {
+			return "", fmt.Errorf("%s: %v", p, syscall.ELOOP)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", next, underlyingError(err))
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		rest = append(strings.Split(target, "/"), rest...)
+	}
+	return filepath.Join(root, resolved), nil
+}
+
+func underlyingError(err error) error {
+	if pe, ok := err.(*os.PathError); ok {
+		return pe.Err
+	}
+	return err
 }
@@ -9,6 +9,8 @@ package exec
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -77,3 +79,75 @@ func TestLookPathUnixEmptyPath(t *testing.T) {
 		t.Fatalf("LookPath path == %q when err != nil", path)
 	}
 }
+
+func TestRootLookPath(t *testing.T) {
+	root, err := ioutil.TempDir("", "TestRootLookPath")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"bin/sh":          "\x7fELF",
+		"usr/bin/py":      "#!/bin/sh\n",
+		"usr/bin/bad":     "#! /usr/bin/ruby -w\n",
+		"usr/bin/nested":  "#!/usr/bin/py\n",
+		"work/main":       "\x7fELF",
+		"work/data":       "",
+		"opt/tool/run.sh": "#!sh\n",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mode := os.FileMode(0755)
+		if name == "work/data" {
+			mode = 0644
+		}
+		if err := ioutil.WriteFile(p, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// absolute link must be resolved in root
+	if err = os.Symlink("/usr/bin/py", filepath.Join(root, "usr/bin/python3")); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("../../../../../bin", filepath.Join(root, "opt/bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &rootLookup{root: root, dir: "/work"}
+	envs := []string{"PATH=/usr/bin:/opt/bin"}
+	for _, tt := range []struct {
+		file string
+		want string
+		err  string
+	}{
+		{"python3", "/usr/bin/python3", ""},
+		{"nested", "/usr/bin/nested", ""},
+		{"sh", "/opt/bin/sh", ""},
+		{"./main", "/work/main", ""},
+		// work dir is not searched for a bare name
+		{"main", "", "executable file not found in $PATH in root " + root},
+		{"/opt/tool/run.sh", "", `bad interpreter "sh"`},
+		{"bad", "", `bad interpreter "/usr/bin/ruby"`},
+		{"data", "", "executable file not found in $PATH in root " + root},
+		{"./data", "", "permission denied in root " + root},
+		{"ls", "", "executable file not found in $PATH in root " + root},
+	} {
+		got, err := l.lookPath(tt.file, envs)
+		if tt.err == "" && (err != nil || got != tt.want) {
+			t.Errorf("lookPath(%q) = %q, %v, want %q", tt.file, got, err, tt.want)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("lookPath(%q) = %q, %v, want error %q", tt.file, got, err, tt.err)
+		}
+	}
+
+	c := Command("ls")
+	c.Chroot = root
+	if err = c.Start(); err == nil || !strings.Contains(err.Error(), "in root "+root) {
+		t.Errorf("start in root = %v, want not found in root", err)
+	}
+}
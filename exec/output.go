@@ -0,0 +1,57 @@
+//+build linux
+
+package exec
+
+import "io"
+
+// limitWriter count bytes the process write to one stream and to all streams,
+// when any limit is exceed, the process group will be killed.
+// Bytes before the limit are passed to w, so w keeps a truncated prefix,
+// bytes after the limit are dropped, so the pipe will still be drained.
+type limitWriter struct {
+	c     *Cmd
+	w     io.Writer
+	used  *uint64 // this stream used, point into c.resourceStats
+	limit uint64  // this stream limit
+}
+
+func remain(used, limit uint64) uint64 {
+	if limit == BYTE_UNRESOURCE {
+		return ^uint64(0)
+	}
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
+
+func (l *limitWriter) Write(p []byte) (n int, err error) {
+	c := l.c
+	c.mu.Lock()
+	allow := uint64(len(p))
+	if r := remain(*l.used, l.limit); r < allow {
+		allow = r
+	}
+	if r := remain(c.resourceStats.Output, c.ResourceLimit.Output); r < allow {
+		allow = r
+	}
+	*l.used += allow
+	c.resourceStats.Output += allow
+	overflow := allow < uint64(len(p))
+	if overflow && !c.outputTruncated {
+		c.outputTruncated = true
+		c.exceed = EXCEED_OUTPUT
+		c.markLocked(TIMELINE_LIMIT_EXCEEDED, EXCEED_STR[EXCEED_OUTPUT])
+	}
+	c.mu.Unlock()
+
+	if overflow {
+		c.kill()
+	}
+	if allow > 0 {
+		if _, err = l.w.Write(p[:allow]); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
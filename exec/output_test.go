@@ -0,0 +1,66 @@
+// +build linux
+
+package exec
+
+import (
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutputLimitRegularFile(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestOutputLimitRegularFile")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, tt := range []struct {
+		name           string
+		output, stdout uint64
+		verdict        int
+		size           int64 // total size of stdout and stderr
+	}{
+		{"under limit", 2000, BYTE_UNRESOURCE, VERDICT_OK, 1600},
+		// each file is under the limit, but the total is not
+		{"total", 1000, BYTE_UNRESOURCE, VERDICT_OUTPUT_LIMIT_EXCEEDED, 1000},
+		{"stdout", BYTE_UNRESOURCE, 100, VERDICT_OUTPUT_LIMIT_EXCEEDED, 900},
+	} {
+		stdout, err := os.Create(filepath.Join(dir, tt.name+".out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr, err := os.Create(filepath.Join(dir, tt.name+".err"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := Command("/bin/sh", "-c", "head -c 800 /dev/zero >&2; head -c 800 /dev/zero")
+		c.Stdout, c.Stderr = stdout, stderr
+		c.ResourceLimit.ClockTime = 5000
+		c.ResourceLimit.Output, c.ResourceLimit.Stdout = tt.output, tt.stdout
+		err = c.Run()
+		stdout.Close()
+		stderr.Close()
+		if err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		if r := c.Result(); r.Verdict != tt.verdict {
+			t.Errorf("%s: verdict = %s, want %s", tt.name, VERDICT_STR[r.Verdict], VERDICT_STR[tt.verdict])
+		}
+		var size int64
+		for _, name := range []string{".out", ".err"} {
+			fi, err := os.Stat(filepath.Join(dir, tt.name+name))
+			if err != nil {
+				t.Fatal(err)
+			}
+			size += fi.Size()
+		}
+		if size != tt.size {
+			t.Errorf("%s: size of stdout and stderr = %d, want %d", tt.name, size, tt.size)
+		}
+	}
+}
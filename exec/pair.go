@@ -0,0 +1,240 @@
+//+build linux
+
+package exec
+
+import (
+	"errors"
+	"os"
+	"runtime"
+)
+
+const (
+	PAIR_NONE = iota
+	PAIR_CONTESTANT
+	PAIR_INTERACTOR
+)
+
+var PAIR_SIDE_STR = []string{
+	"none",
+	"contestant",
+	"interactor",
+}
+
+// Pair runs two sandboxes for interactive problem, the contestant's stdout
+// is connected to the interactor's stdin, and the interactor's stdout is
+// connected to the contestant's stdin. Stdin and Stdout of both Cmd will be
+// replaced by the pipes, Stderr and other settings are kept.
+//
+// When one side fails (exceed limit, runtime error, bad syscall...), the other
+// side will be killed. When the interactor exits, the contestant will be killed,
+// but when the contestant exits normally, the interactor will get EOF and
+// finish under its own limit.
+type Pair struct {
+	Contestant *Cmd
+	Interactor *Cmd
+
+	started  bool
+	finished bool
+	first    int // which side exited first
+	killed   [3]bool
+	waitErr  [3]error
+	done     chan int
+}
+
+// PairResult is the combined result of a Pair run.
+type PairResult struct {
+	Contestant *Result
+	Interactor *Result
+	// FirstExited is the side exited first, PAIR_*
+	FirstExited int
+	// FirstFailed is the side failed first, PAIR_NONE if no side failed.
+	// A side killed by the Pair because the other side has finished is not a failure.
+	FirstFailed int
+	// Verdict is VERDICT_*, the interactor exit code is read as testlib:
+	// 0 ok, 1 wrong answer, 2 presentation error, others judge error.
+	Verdict int
+}
+
+func NewPair(contestant, interactor *Cmd) *Pair {
+	return &Pair{Contestant: contestant, Interactor: interactor}
+}
+
+func (p *Pair) cmd(side int) *Cmd {
+	if side == PAIR_CONTESTANT {
+		return p.Contestant
+	}
+	return p.Interactor
+}
+
+func (p *Pair) Start() error {
+	if p.started {
+		return errors.New("exec: pair already started")
+	}
+	if p.Contestant == nil || p.Interactor == nil {
+		return errors.New("exec: pair need both contestant and interactor")
+	}
+
+	c2iR, c2iW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	i2cR, i2cW, err := os.Pipe()
+	if err != nil {
+		c2iR.Close()
+		c2iW.Close()
+		return err
+	}
+	// parent must not keep any pipe end, or the reader will never get EOF
+	defer func() {
+		c2iR.Close()
+		c2iW.Close()
+		i2cR.Close()
+		i2cW.Close()
+	}()
+
+	p.Contestant.Stdin, p.Contestant.Stdout = i2cR, c2iW
+	p.Interactor.Stdin, p.Interactor.Stdout = c2iR, i2cW
+
+	// tracer must be the thread which starts the process, so each side is
+	// started and waited in its own locked goroutine
+	p.done = make(chan int, 2)
+	started := make(chan error)
+	run := func(side int) {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		c := p.cmd(side)
+		err := c.Start()
+		started <- err
+		if err != nil {
+			return
+		}
+		p.waitErr[side] = c.Wait()
+		p.done <- side
+	}
+
+	go run(PAIR_INTERACTOR)
+	if err = <-started; err != nil {
+		return err
+	}
+	go run(PAIR_CONTESTANT)
+	if err = <-started; err != nil {
+		p.kill(PAIR_INTERACTOR)
+		<-p.done
+		return err
+	}
+	p.started = true
+	return nil
+}
+
+func (p *Pair) kill(side int) {
+	c := p.cmd(side)
+	if c.Process == nil || c.Process.Done() {
+		return
+	}
+	p.killed[side] = true
+	c.kill()
+}
+
+func other(side int) int {
+	if side == PAIR_CONTESTANT {
+		return PAIR_INTERACTOR
+	}
+	return PAIR_CONTESTANT
+}
+
+func (p *Pair) Wait() error {
+	if !p.started {
+		return errors.New("exec: pair not started")
+	}
+	if p.finished {
+		return errors.New("exec: pair Wait was already called")
+	}
+	p.finished = true
+
+	for i := 0; i < 2; i++ {
+		side := <-p.done
+		if i == 0 {
+			p.first = side
+		}
+		if p.killed[side] {
+			continue
+		}
+		if side == PAIR_INTERACTOR || p.failed(side) {
+			p.kill(other(side))
+		}
+	}
+
+	if p.waitErr[PAIR_CONTESTANT] != nil {
+		return p.waitErr[PAIR_CONTESTANT]
+	}
+	return p.waitErr[PAIR_INTERACTOR]
+}
+
+func (p *Pair) result(side int) *Result {
+	if p.waitErr[side] != nil {
+		return nil
+	}
+	return p.cmd(side).Result()
+}
+
+func (p *Pair) failed(side int) bool {
+	r := p.result(side)
+	if r == nil {
+		return true
+	}
+	if side == PAIR_INTERACTOR {
+		return interactorVerdict(r) != VERDICT_OK
+	}
+	return r.Verdict != VERDICT_OK
+}
+
+func interactorVerdict(r *Result) int {
+	if r == nil || r.Exceed != EXCEED_NONE || r.ExitStatus.Signaled() || r.Verdict == VERDICT_BAD_SYSCALL {
+		return VERDICT_JUDGE_ERROR
+	}
+	switch r.ExitCode {
+	case 0:
+		return VERDICT_OK
+	case 1:
+		return VERDICT_WRONG_ANSWER
+	case 2:
+		return VERDICT_PRESENTATION_ERROR
+	}
+	return VERDICT_JUDGE_ERROR
+}
+
+func (p *Pair) Result() *PairResult {
+	r := &PairResult{
+		Contestant:  p.result(PAIR_CONTESTANT),
+		Interactor:  p.result(PAIR_INTERACTOR),
+		FirstExited: p.first,
+	}
+
+	// the side exited first is checked first, a side killed by pair never fails
+	for _, side := range []int{p.first, other(p.first)} {
+		if !p.killed[side] && p.failed(side) {
+			r.FirstFailed = side
+			break
+		}
+	}
+
+	switch r.FirstFailed {
+	case PAIR_CONTESTANT:
+		r.Verdict = VERDICT_JUDGE_ERROR
+		if r.Contestant != nil {
+			r.Verdict = r.Contestant.Verdict
+		}
+	case PAIR_INTERACTOR:
+		r.Verdict = interactorVerdict(r.Interactor)
+	default:
+		r.Verdict = VERDICT_OK
+	}
+	return r
+}
+
+func (p *Pair) Run() error {
+	if err := p.Start(); err != nil {
+		return err
+	}
+	return p.Wait()
+}
@@ -0,0 +1,89 @@
+// +build linux
+
+package exec
+
+import (
+	"github.com/boxjan/golib/logs"
+	"testing"
+)
+
+func newTestPair(contestant, interactor string) *Pair {
+	c := Command("/bin/sh", "-c", contestant)
+	i := Command("/bin/sh", "-c", interactor)
+	for _, cmd := range []*Cmd{c, i} {
+		cmd.ResourceLimit.ClockTime = 5000
+		// every stop of a traced process must be handled by the thread which started it
+		cmd.Sys = &SysAttr{Ptrace: true}
+	}
+	return NewPair(c, i)
+}
+
+func TestPair(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	const answer = `read x; echo $((x+1))`
+	for _, tt := range []struct {
+		name        string
+		contestant  string
+		interactor  string
+		verdict     int
+		firstFailed int
+	}{
+		{"ok", answer, `echo 1; read x; [ "$x" = 2 ]`, VERDICT_OK, PAIR_NONE},
+		{"wrong answer", `read x; echo 3`, `echo 1; read x; [ "$x" = 2 ]`, VERDICT_WRONG_ANSWER, PAIR_INTERACTOR},
+		{"presentation error", answer, `echo 1; read x; exit 2`, VERDICT_PRESENTATION_ERROR, PAIR_INTERACTOR},
+		{"judge error", answer, `echo 1; read x; exit 3`, VERDICT_JUDGE_ERROR, PAIR_INTERACTOR},
+		{"interactor killed", answer, `echo 1; read x; kill -9 $$`, VERDICT_JUDGE_ERROR, PAIR_INTERACTOR},
+		// the interactor is killed by pair, so it is not a failure
+		{"contestant failed first", `exit 3`, `read x; sleep 10`, VERDICT_RUNTIME_ERROR, PAIR_CONTESTANT},
+		// the contestant is killed by pair, so it is not a failure
+		{"interactor failed first", `sleep 10`, `exit 1`, VERDICT_WRONG_ANSWER, PAIR_INTERACTOR},
+		{"interactor finished first", `sleep 10`, `exit 0`, VERDICT_OK, PAIR_NONE},
+	} {
+		p := newTestPair(tt.contestant, tt.interactor)
+		if err := p.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		r := p.Result()
+		if r.Verdict != tt.verdict || r.FirstFailed != tt.firstFailed {
+			t.Errorf("%s: verdict %s, first failed %s, want %s, %s", tt.name, VERDICT_STR[r.Verdict],
+				PAIR_SIDE_STR[r.FirstFailed], VERDICT_STR[tt.verdict], PAIR_SIDE_STR[tt.firstFailed])
+		}
+		if r.Contestant == nil || r.Interactor == nil {
+			t.Errorf("%s: result of a side is missing", tt.name)
+			continue
+		}
+		if r.Contestant.Exceed == EXCEED_CLOCK_TIME || r.Interactor.Exceed == EXCEED_CLOCK_TIME {
+			t.Errorf("%s: a side hangs until clock time limit", tt.name)
+		}
+	}
+}
+
+func TestPairOutputLimit(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	for _, tt := range []struct {
+		name        string
+		contestant  string
+		verdict     int
+		firstFailed int
+	}{
+		// output is copied by sandbox, the pipe must be still open after Start
+		{"ok", `read x; echo $((x+1))`, VERDICT_OK, PAIR_NONE},
+		{"exceeded", `yes`, VERDICT_OUTPUT_LIMIT_EXCEEDED, PAIR_CONTESTANT},
+	} {
+		p := newTestPair(tt.contestant, `echo 1; read x; [ "$x" = 2 ] || sleep 10`)
+		p.Contestant.ResourceLimit.Output = 1000
+		p.Interactor.ResourceLimit.Output = 1000
+		if err := p.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		r := p.Result()
+		if r.Verdict != tt.verdict || r.FirstFailed != tt.firstFailed {
+			t.Errorf("%s: verdict %s, first failed %s, want %s, %s", tt.name, VERDICT_STR[r.Verdict],
+				PAIR_SIDE_STR[r.FirstFailed], VERDICT_STR[tt.verdict], PAIR_SIDE_STR[tt.firstFailed])
+		}
+	}
+}
@@ -0,0 +1,135 @@
+//+build linux
+
+package exec
+
+import (
+	"errors"
+	"github.com/sdibtacm/sandbox/units/pstree"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrNotRunning    = errors.New("exec: process is not running")
+	ErrAlreadyPaused = errors.New("exec: process already paused")
+	ErrNotPaused     = errors.New("exec: process not paused")
+	ErrPauseTraced   = errors.New("exec: traced process can not be paused")
+)
+
+// ProcessSnapshot is the usage of one process in the run at the moment.
+type ProcessSnapshot struct {
+	Pid     int
+	Ppid    int
+	Name    string
+	State   byte
+	CpuTime uint   // ms
+	Memory  uint64 // byte
+	Thread  uint
+}
+
+func (c *Cmd) running() bool {
+	if c.Process == nil || c.Process.Done() {
+		return false
+	}
+	select {
+	case <-c.waitDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// activeTime is the clock time the run used, paused time is excluded. c.mu must be held.
+func (c *Cmd) activeTime(now time.Time) time.Duration {
+	d := now.Sub(c.startTimestamp) - c.pausedTime
+	if c.paused {
+		d -= now.Sub(c.pauseTimestamp)
+	}
+	return d
+}
+
+// Pause stops the process and all its descendants by SIGSTOP, the time paused
+// is not counted in clock time. ErrPauseTraced is returned with ptrace (seccomp
+// trace action), because the tracer will continue the tracee.
+func (c *Cmd) Pause() error {
+	if !c.running() {
+		return ErrNotRunning
+	}
+	if c.traced {
+		return ErrPauseTraced
+	}
+	c.mu.Lock()
+	if c.paused {
+		c.mu.Unlock()
+		return ErrAlreadyPaused
+	}
+	c.paused = true
+	c.pauseTimestamp = time.Now()
+	if c.clockTimer != nil {
+		c.clockTimer.Stop()
+	}
+	c.mu.Unlock()
+
+	c.signalTree(syscall.SIGSTOP)
+	return nil
+}
+
+// Resume continues the process paused by Pause.
+func (c *Cmd) Resume() error {
+	if !c.running() {
+		return ErrNotRunning
+	}
+	c.mu.Lock()
+	if !c.paused {
+		c.mu.Unlock()
+		return ErrNotPaused
+	}
+	now := time.Now()
+	c.pausedTime += now.Sub(c.pauseTimestamp)
+	c.paused = false
+	if c.clockTimer != nil {
+		remain := time.Duration(c.ResourceLimit.ClockTime)*time.Millisecond - c.activeTime(now)
+		if remain < 0 {
+			remain = 0
+		}
+		c.clockTimer.Reset(remain)
+	}
+	c.mu.Unlock()
+
+	c.signalTree(syscall.SIGCONT)
+	return nil
+}
+
+// Paused reports whether the process is paused by Pause.
+func (c *Cmd) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Snapshot returns the process and its living descendants with their usage,
+// descendants reparented to sandbox are included.
+func (c *Cmd) Snapshot() ([]ProcessSnapshot, error) {
+	if !c.running() {
+		return nil, ErrNotRunning
+	}
+	pt, err := pstree.New()
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []ProcessSnapshot
+	for _, pid := range c.runPids(pt) {
+		proc := pt.Procs[pid]
+		snapshots = append(snapshots, ProcessSnapshot{
+			Pid:     proc.Stat.Pid,
+			Ppid:    proc.Stat.Ppid,
+			Name:    proc.Name,
+			State:   proc.Stat.State,
+			CpuTime: uint(proc.Stat.Stime+proc.Stat.Utime) * kernelTimeMod,
+			Memory:  proc.Stat.Vsize,
+			Thread:  uint(proc.Stat.Nthreads),
+		})
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,135 @@
+// +build linux
+
+package exec
+
+import (
+	"context"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startLocked starts c on a locked thread, and returns a channel receives the
+// error of Wait.
+func startLocked(t *testing.T, c *Cmd) <-chan error {
+	started := make(chan error, 1)
+	done := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		if err := c.Start(); err != nil {
+			started <- err
+			return
+		}
+		started <- nil
+		done <- c.Wait()
+	}()
+	if err := <-started; err != nil {
+		t.Fatal(err)
+	}
+	return done
+}
+
+// states returns the state of every process in the snapshot of c by pid.
+func states(t *testing.T, c *Cmd) map[int]byte {
+	snapshots, err := c.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[int]byte)
+	for _, s := range snapshots {
+		m[s.Pid] = s.State
+	}
+	return m
+}
+
+func TestPauseOrphan(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	dir, err := ioutil.TempDir("", "TestPauseOrphan")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(dir)
+	out, err := os.Create(filepath.Join(dir, "out"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the orphan is seen by limiter before its parent exits, then it is
+	// reparented to the process, and not in the process group
+	c := CommandContext(ctx, "/bin/sh", "-c", "(setsid sleep 30 & echo $!; sleep 0.2); sleep 30")
+	c.Stdout = out
+	done := startLocked(t, c)
+	time.Sleep(500 * time.Millisecond)
+
+	b, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		t.Fatalf("bad pid %q", b)
+	}
+	if _, ok := states(t, c)[orphan]; !ok {
+		t.Errorf("orphan %d is not in snapshot", orphan)
+	}
+
+	if err := c.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	for pid, state := range states(t, c) {
+		if state != 'T' {
+			t.Errorf("process %d is %c after pause", pid, state)
+		}
+	}
+	if err := c.Pause(); err != ErrAlreadyPaused {
+		t.Errorf("pause again = %v, want %v", err, ErrAlreadyPaused)
+	}
+
+	if err := c.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if state := states(t, c)[orphan]; state == 'T' {
+		t.Errorf("orphan %d is %c after resume", orphan, state)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if r := c.Result(); r.Verdict != VERDICT_CANCELLED {
+		t.Errorf("verdict = %s, want cancelled", VERDICT_STR[r.Verdict])
+	}
+}
+
+func TestPauseTraced(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := CommandContext(ctx, "/bin/sleep", "30")
+	c.Syscall = &SyscallLimit{Level: scmpFilter.LEVEL_ALL, Action: int(scmpFilter.DEFAULT_TRACE)}
+	done := startLocked(t, c)
+
+	if err := c.Pause(); err != ErrPauseTraced {
+		t.Errorf("pause = %v, want %v", err, ErrPauseTraced)
+	}
+	if c.Paused() {
+		t.Error("paused is set")
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
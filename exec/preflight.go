@@ -0,0 +1,167 @@
+//+build linux
+
+package exec
+
+import (
+	"debug/elf"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+)
+
+// Binary is what preflight learned about the executable before it runs,
+// Path is empty if the executable is not an ELF file.
+type Binary struct {
+	Path        string   // path seen by the process, the interpreter if Script is set
+	Script      string   // the script run by Path, empty if it is not a script
+	Arch        string   // seccomp arch name, like amd64, x86 or x32
+	Bits        int      // 32 or 64
+	Static      bool     // no dynamic loader, static-pie is static too
+	Interpreter string   // dynamic loader from PT_INTERP, empty if static
+	Needed      []string // libraries from DT_NEEDED
+	Warnings    []string
+}
+
+// seccomp arch names of GOARCH
+var goArchToScmpArch = map[string]string{
+	"386":      "x86",
+	"amd64":    "amd64",
+	"arm":      "arm",
+	"arm64":    "arm64",
+	"mips":     "mips",
+	"mipsle":   "mipsel",
+	"mips64":   "mips64",
+	"mips64le": "mips64el",
+	"ppc64":    "ppc64",
+	"ppc64le":  "ppc64le",
+	"s390x":    "s390x",
+	"riscv64":  "riscv64",
+}
+
+// NativeArch returns seccomp arch name of the host.
+func NativeArch() string {
+	if arch, ok := goArchToScmpArch[runtime.GOARCH]; ok {
+		return arch
+	}
+	return runtime.GOARCH
+}
+
+// elfArch returns seccomp arch name of f.
+func elfArch(f *elf.File) string {
+	is64 := f.Class == elf.ELFCLASS64
+	little := f.Data == elf.ELFDATA2LSB
+	switch f.Machine {
+	case elf.EM_386:
+		return "x86"
+	case elf.EM_X86_64:
+		if is64 {
+			return "amd64"
+		}
+		return "x32"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_MIPS:
+		arch := "mips"
+		if is64 {
+			arch = "mips64"
+		}
+		if little {
+			arch += "el"
+		}
+		return arch
+	case elf.EM_PPC64:
+		if little {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		if is64 {
+			return "s390x"
+		}
+		return "s390"
+	case elf.EM_RISCV:
+		if is64 {
+			return "riscv64"
+		}
+		return "riscv32"
+	}
+	return strings.ToLower(strings.TrimPrefix(f.Machine.String(), "EM_"))
+}
+
+// preflight reads the ELF of p in root, the interpreter is read if p is a
+// script, it returns nil if it is not an ELF. syscallLimited tells if a
+// seccomp filter will be loaded.
+func (l *rootLookup) preflight(p string, syscallLimited bool) (*Binary, error) {
+	b := &Binary{Path: p}
+	hostPath, err := l.host(p)
+	if err != nil {
+		return nil, err
+	}
+	for depth := 0; depth < MAX_INTERPRETER_DEPTH; depth++ {
+		interp, err := readShebang(hostPath)
+		if err != nil {
+			return nil, err
+		}
+		if interp == "" {
+			break
+		}
+		b.Script = b.Path
+		b.Path = l.inside(interp)
+		if hostPath, err = l.host(b.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := elf.Open(hostPath)
+	if err != nil {
+		// not an ELF, leave it to exec
+		return nil, nil
+	}
+	defer f.Close()
+
+	b.Arch = elfArch(f)
+	b.Bits = 32
+	if f.Class == elf.ELFCLASS64 {
+		b.Bits = 64
+	}
+	for _, prog := range f.Progs {
+		if prog.Type != elf.PT_INTERP {
+			continue
+		}
+		// the header is untrusted, kernel rejects the same size too
+		if prog.Filesz > syscall.PathMax {
+			return nil, fmt.Errorf("PT_INTERP of %d bytes is longer than PATH_MAX", prog.Filesz)
+		}
+		interp := make([]byte, prog.Filesz)
+		if _, err := prog.ReadAt(interp, 0); err != nil {
+			return nil, err
+		}
+		b.Interpreter = strings.TrimRight(string(interp), "\x00")
+	}
+	b.Static = b.Interpreter == ""
+	// static binary has no dynamic section
+	b.Needed, _ = f.ImportedLibraries()
+
+	root := l.root
+	if root == "" {
+		root = "/"
+	}
+	if b.Interpreter != "" {
+		hostInterp, err := l.host(b.Interpreter)
+		if err == nil {
+			_, err = os.Stat(hostInterp)
+		}
+		if err != nil {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("dynamic loader %s not found in root %s", b.Interpreter, root))
+		}
+	}
+	if native := NativeArch(); syscallLimited && b.Arch != native {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%d-bit %s binary on %s may bypass a seccomp filter of native arch only, %s is added to the filter",
+			b.Bits, b.Arch, native, b.Arch))
+	}
+	return b, nil
+}
@@ -0,0 +1,171 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testElfBase = 0x08048000
+
+// buildElf returns a minimal executable ELF, the whole file is loaded at
+// testElfBase and code is the entry. PT_INTERP is added if interp is set.
+func buildElf(class elf.Class, machine elf.Machine, interp string, code []byte) []byte {
+	is64 := class == elf.ELFCLASS64
+	ehsize, phentsize := binary.Size(elf.Header32{}), binary.Size(elf.Prog32{})
+	if is64 {
+		ehsize, phentsize = binary.Size(elf.Header64{}), binary.Size(elf.Prog64{})
+	}
+	phnum := 1
+	if interp != "" {
+		phnum++
+		interp += "\x00"
+	}
+	interpOff := ehsize + phnum*phentsize
+	codeOff := interpOff + len(interp)
+	size := codeOff + len(code)
+
+	var ident [elf.EI_NIDENT]byte
+	copy(ident[:], elf.ELFMAG)
+	ident[elf.EI_CLASS], ident[elf.EI_DATA], ident[elf.EI_VERSION] = byte(class), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)
+
+	type prog struct {
+		typ        elf.ProgType
+		flags      elf.ProgFlag
+		off, size  int
+		vaddr, aln uint64
+	}
+	progs := []prog{{elf.PT_LOAD, elf.PF_R | elf.PF_X, 0, size, testElfBase, 0x1000}}
+	if interp != "" {
+		progs = append([]prog{{elf.PT_INTERP, elf.PF_R, interpOff, len(interp), testElfBase + uint64(interpOff), 1}}, progs...)
+	}
+
+	var buf bytes.Buffer
+	w := func(v interface{}) { _ = binary.Write(&buf, binary.LittleEndian, v) }
+	if is64 {
+		w(elf.Header64{Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT),
+			Entry: testElfBase + uint64(codeOff), Phoff: uint64(ehsize), Ehsize: uint16(ehsize),
+			Phentsize: uint16(phentsize), Phnum: uint16(phnum)})
+		for _, p := range progs {
+			w(elf.Prog64{Type: uint32(p.typ), Flags: uint32(p.flags), Off: uint64(p.off), Vaddr: p.vaddr, Paddr: p.vaddr,
+				Filesz: uint64(p.size), Memsz: uint64(p.size), Align: p.aln})
+		}
+	} else {
+		w(elf.Header32{Ident: ident, Type: uint16(elf.ET_EXEC), Machine: uint16(machine), Version: uint32(elf.EV_CURRENT),
+			Entry: testElfBase + uint32(codeOff), Phoff: uint32(ehsize), Ehsize: uint16(ehsize),
+			Phentsize: uint16(phentsize), Phnum: uint16(phnum)})
+		for _, p := range progs {
+			w(elf.Prog32{Type: uint32(p.typ), Off: uint32(p.off), Vaddr: uint32(p.vaddr), Paddr: uint32(p.vaddr),
+				Filesz: uint32(p.size), Memsz: uint32(p.size), Flags: uint32(p.flags), Align: uint32(p.aln)})
+		}
+	}
+	buf.WriteString(interp)
+	buf.Write(code)
+	return buf.Bytes()
+}
+
+func TestPreflight(t *testing.T) {
+	if NativeArch() != "amd64" {
+		t.Skip("warnings of arch are for amd64")
+	}
+	root, err := ioutil.TempDir("", "TestPreflight")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string][]byte{
+		"lib64/ld.so":    buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", nil),
+		"work/static64":  buildElf(elf.ELFCLASS64, elf.EM_X86_64, "", nil),
+		"work/dynamic64": buildElf(elf.ELFCLASS64, elf.EM_X86_64, "/lib64/ld.so", nil),
+		"work/x86":       buildElf(elf.ELFCLASS32, elf.EM_386, "/lib/ld-linux.so.2", nil),
+		"work/x32":       buildElf(elf.ELFCLASS32, elf.EM_X86_64, "", nil),
+		"work/arm64":     buildElf(elf.ELFCLASS64, elf.EM_AARCH64, "", nil),
+		"work/run.sh":    []byte("#!/work/x86\n"),
+		"work/data":      []byte("data"),
+	}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, content, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := &rootLookup{root: root, dir: "/work"}
+	for _, tt := range []struct {
+		file     string
+		want     *Binary
+		warnings []string
+	}{
+		{"/work/static64", &Binary{Path: "/work/static64", Arch: "amd64", Bits: 64, Static: true}, nil},
+		{"/work/dynamic64", &Binary{Path: "/work/dynamic64", Arch: "amd64", Bits: 64, Interpreter: "/lib64/ld.so"}, nil},
+		{"/work/x86", &Binary{Path: "/work/x86", Arch: "x86", Bits: 32, Interpreter: "/lib/ld-linux.so.2"},
+			[]string{"dynamic loader /lib/ld-linux.so.2 not found in root " + root, "32-bit x86 binary"}},
+		{"/work/run.sh", &Binary{Path: "/work/x86", Script: "/work/run.sh", Arch: "x86", Bits: 32, Interpreter: "/lib/ld-linux.so.2"},
+			[]string{"dynamic loader", "32-bit x86 binary"}},
+		{"/work/x32", &Binary{Path: "/work/x32", Arch: "x32", Bits: 32, Static: true}, []string{"32-bit x32 binary"}},
+		{"/work/arm64", &Binary{Path: "/work/arm64", Arch: "arm64", Bits: 64, Static: true}, []string{"64-bit arm64 binary"}},
+		{"/work/data", nil, nil},
+	} {
+		b, err := l.preflight(tt.file, true)
+		if err != nil {
+			t.Errorf("preflight(%q) with error: %v", tt.file, err)
+			continue
+		}
+		var warnings []string
+		if b != nil {
+			warnings, b.Warnings = b.Warnings, nil
+		}
+		if !reflect.DeepEqual(b, tt.want) {
+			t.Errorf("preflight(%q) = %+v, want %+v", tt.file, b, tt.want)
+		}
+		if len(warnings) != len(tt.warnings) {
+			t.Errorf("preflight(%q) warnings = %q, want %q", tt.file, warnings, tt.warnings)
+			continue
+		}
+		for i, w := range tt.warnings {
+			if !strings.Contains(warnings[i], w) {
+				t.Errorf("preflight(%q) warnings[%d] = %q, want %q", tt.file, i, warnings[i], w)
+			}
+		}
+	}
+
+	// a crafted PT_INTERP size must not be allocated
+	huge := buildElf(elf.ELFCLASS64, elf.EM_X86_64, "/lib64/ld.so", nil)
+	binary.LittleEndian.PutUint64(huge[binary.Size(elf.Header64{})+32:], 1<<62)
+	if err := ioutil.WriteFile(filepath.Join(root, "work/huge"), huge, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := l.preflight("/work/huge", true); err == nil {
+		t.Errorf("preflight of huge PT_INTERP = %+v, want error", b)
+	}
+
+	// no warning of arch if seccomp is not used
+	if b, err := l.preflight("/work/arm64", false); err != nil || len(b.Warnings) != 0 {
+		t.Errorf("preflight without seccomp = %+v, %v, want no warning", b, err)
+	}
+}
+
+func TestResultBinary(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+	c := Command("sh", "-c", "exit 0")
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	r := c.Result()
+	if r.Binary.Path != c.Path || r.Binary.Arch != NativeArch() || len(r.Binary.Warnings) != 0 {
+		t.Errorf("binary = %+v, want %s of %s", r.Binary, c.Path, NativeArch())
+	}
+}
@@ -0,0 +1,164 @@
+//+build linux
+
+package exec
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Tty makes stdin, stdout and stderr of the process a pseudo-terminal,
+// the process will be the session leader and the terminal will be its controlling terminal.
+// Output of the terminal is written to Cmd.Stdout with output limit, Cmd.Stderr is not used.
+// Input is not echoed, and it ends by EOF after Cmd.Stdin is read to the end.
+type Tty struct {
+	Rows uint16
+	Cols uint16
+}
+
+type winsize struct {
+	Row    uint16
+	Col    uint16
+	Xpixel uint16
+	Ypixel uint16
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if e != 0 {
+		return e
+	}
+	return nil
+}
+
+// openPty returns the master and slave of a new pseudo-terminal, the master is
+// non-blocking, so write to it can be stopped by deadline.
+func openPty() (master, slave *os.File, err error) {
+	fd, err := syscall.Open("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, nil, &os.PathError{Op: "open", Path: "/dev/ptmx", Err: err}
+	}
+	// Fd of os.File sets it blocking, so ioctl on the raw fd
+	var unlock int32
+	if err = ioctl(uintptr(fd), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		syscall.Close(fd)
+		return nil, nil, &os.PathError{Op: "unlockpt", Path: "/dev/ptmx", Err: err}
+	}
+	var n uint32
+	if err = ioctl(uintptr(fd), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		syscall.Close(fd)
+		return nil, nil, &os.PathError{Op: "ptsname", Path: "/dev/ptmx", Err: err}
+	}
+	master = os.NewFile(uintptr(fd), "/dev/ptmx")
+
+	slave, err = os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, err
+	}
+	return master, slave, nil
+}
+
+// setupTty replace stdin, stdout, stderr of child by the slave of a new pty,
+// and relay the master to Stdin and Stdout.
+func (c *Cmd) setupTty() (err error) {
+	master, slave, err := openPty()
+	if err != nil {
+		return err
+	}
+
+	// input is not echoed to output
+	var termios syscall.Termios
+	if err = ioctl(slave.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios))); err == nil {
+		termios.Lflag &^= syscall.ECHO
+		err = ioctl(slave.Fd(), syscall.TCSETS, uintptr(unsafe.Pointer(&termios)))
+	}
+	if err != nil {
+		master.Close()
+		slave.Close()
+		return &os.PathError{Op: "set termios", Path: slave.Name(), Err: err}
+	}
+
+	if c.Tty.Rows != 0 || c.Tty.Cols != 0 {
+		ws := winsize{Row: c.Tty.Rows, Col: c.Tty.Cols}
+		if err = ioctl(slave.Fd(), syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws))); err != nil {
+			master.Close()
+			slave.Close()
+			return &os.PathError{Op: "set winsize", Path: slave.Name(), Err: err}
+		}
+	}
+
+	c.childFiles = append(c.childFiles, slave, slave, slave)
+	c.closeAfterStart = append(c.closeAfterStart, slave)
+	c.closeAfterWait = append(c.closeAfterWait, master)
+	c.Sys.Setsid = true
+	c.Sys.Setctty = true
+	c.Sys.Ctty = 0
+
+	// closed when all slave are closed, then input can not be read by anyone
+	hangup := make(chan struct{})
+	if c.Stdin != nil {
+		c.goroutine = append(c.goroutine, func() error {
+			w := &lineWriter{w: master}
+			_, err := io.Copy(w, c.Stdin)
+			if err == nil {
+				// tell the process input is end, EOT is EOF only at the beginning
+				// of a line, otherwise it just passes the partial line
+				eot := []byte{4}
+				if w.partial {
+					eot = append(eot, 4)
+				}
+				_, err = master.Write(eot)
+			}
+			// the process may exit before reading all input, write is
+			// stopped by deadline after hangup
+			select {
+			case <-hangup:
+				err = nil
+			default:
+			}
+			return err
+		})
+	}
+
+	w := c.Stdout
+	if w == nil {
+		w = ioutil.Discard
+	}
+	w = &limitWriter{c: c, w: w, used: &c.resourceStats.Stdout, limit: c.ResourceLimit.Stdout}
+	c.goroutine = append(c.goroutine, func() error {
+		_, err := io.Copy(w, master)
+		// read master return EIO when all slave are closed
+		if isEIO(err) {
+			err = nil
+		}
+		close(hangup)
+		_ = master.SetWriteDeadline(time.Now())
+		return err
+	})
+	return nil
+}
+
+func isEIO(err error) bool {
+	pe, ok := err.(*os.PathError)
+	return ok && pe.Err == syscall.EIO
+}
+
+// lineWriter reports whether the last line written is not ended by a newline.
+type lineWriter struct {
+	w       io.Writer
+	partial bool
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if n > 0 {
+		w.partial = p[n-1] != '\n'
+	}
+	return n, err
+}
@@ -0,0 +1,43 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"strings"
+	"testing"
+)
+
+func TestTty(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	for _, tt := range []struct {
+		name   string
+		script string
+		stdin  string
+		stdout string
+	}{
+		{"no echo", `read a b; echo $((a+b))`, "1 2\n", "3\r\n"},
+		{"tty", `[ -t 0 ] && [ -t 1 ] && stty size`, "", "24 80\r\n"},
+		// EOF after a partial line
+		{"no newline", `cat; echo`, "abc", "abc\r\n"},
+		{"empty", `cat; echo end`, "", "end\r\n"},
+		// the process exits before reading the input
+		{"unread input", `exit 0`, strings.Repeat("0123456789\n", 1<<16), ""},
+	} {
+		var stdout bytes.Buffer
+		c := Command("/bin/sh", "-c", tt.script)
+		c.Tty = &Tty{Rows: 24, Cols: 80}
+		c.Stdin = strings.NewReader(tt.stdin)
+		c.Stdout = &stdout
+		c.ResourceLimit.ClockTime = 5000
+		if err := c.Run(); err != nil {
+			t.Errorf("%s: run with error: %v", tt.name, err)
+			continue
+		}
+		if r := c.Result(); r.Verdict != VERDICT_OK || stdout.String() != tt.stdout {
+			t.Errorf("%s: verdict = %s, stdout = %q, want %q", tt.name, VERDICT_STR[r.Verdict], stdout.String(), tt.stdout)
+		}
+	}
+}
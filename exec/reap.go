@@ -0,0 +1,132 @@
+//+build linux
+
+package exec
+
+import (
+	"github.com/sdibtacm/sandbox/exec/log"
+	"github.com/sdibtacm/sandbox/units/pstree"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const PR_SET_CHILD_SUBREAPER = 36
+
+// reapDescendants scans and kills left processes at most REAP_MAX_ROUNDS times,
+// and sleeps REAP_INTERVAL between rounds in which no process is reaped
+const (
+	REAP_MAX_ROUNDS = 100
+	REAP_INTERVAL   = 10 * time.Millisecond
+)
+
+// subreaper counts the Cmds which are running, sandbox is a child subreaper
+// only while any of them is running.
+var subreaper struct {
+	mu    sync.Mutex
+	count int
+}
+
+// setSubreaper makes sandbox a child subreaper, so a descendant daemonize itself
+// by fork and setsid will be reparented to sandbox instead of init after the
+// process exits, and can be found and killed after the run. It must be paired
+// with clearSubreaper.
+func setSubreaper() {
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+	subreaper.count++
+	if subreaper.count == 1 {
+		prctlSubreaper(1)
+	}
+}
+
+// clearSubreaper stops sandbox being a child subreaper after the last running
+// Cmd is reaped, or orphans of other children of the host become zombies of it.
+func clearSubreaper() {
+	subreaper.mu.Lock()
+	defer subreaper.mu.Unlock()
+	subreaper.count--
+	if subreaper.count == 0 {
+		prctlSubreaper(0)
+	}
+}
+
+func prctlSubreaper(flag uintptr) {
+	_, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, PR_SET_CHILD_SUBREAPER, flag, 0)
+	if e != 0 {
+		log.GetLog().Warning("set child subreaper {} fail with error: {}, descendants may be left after wait", flag, e)
+	}
+}
+
+// reapDescendants kill and reap every process left by the run after the
+// process exits, a process belongs to the run when it has been seen in the
+// process tree by limiter, or any of its ancestors has been seen. The process
+// is a child subreaper, orphans are its descendants until it exits, then they
+// are reparented to sandbox and found by the pid and start time seen before.
+// Other children of sandbox are never killed.
+func (c *Cmd) reapDescendants() {
+	c.mu.Lock()
+	tracked := make(map[int]uint64, len(c.tracked))
+	for pid, starttime := range c.tracked {
+		tracked[pid] = starttime
+	}
+	c.mu.Unlock()
+
+	owned := func(procs map[int]pstree.Process, pid int) bool {
+		for depth := 0; pid > 1 && depth < len(procs); depth++ {
+			proc, ok := procs[pid]
+			if !ok {
+				return false
+			}
+			if starttime, ok := tracked[pid]; ok && starttime == uint64(proc.Stat.Starttime) {
+				return true
+			}
+			pid = proc.Stat.Ppid
+		}
+		return false
+	}
+
+	for round := 0; ; round++ {
+		pt, err := pstree.New()
+		if err != nil {
+			c.logger.Warning("pstree scan error: {}, descendants may be left", err)
+			return
+		}
+
+		var pids []int
+		for pid := range pt.Procs {
+			if pid != c.Process.Pid && owned(pt.Procs, pid) {
+				pids = append(pids, pid)
+			}
+		}
+		if len(pids) == 0 {
+			return
+		}
+		if round == REAP_MAX_ROUNDS {
+			c.logger.Warning("descendants {} are left after {} rounds of kill", pids, round)
+			return
+		}
+
+		c.logger.Debug("kill and reap descendants left: {}", pids)
+		for _, pid := range pids {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+		reaped := false
+		for _, pid := range pids {
+			// only children can be reaped, others will be reparented to
+			// sandbox after their parent is killed, and reaped in next round.
+			if pt.Procs[pid].Stat.Ppid != os.Getpid() {
+				continue
+			}
+			_, err := syscall.Wait4(pid, nil, 0, nil)
+			for err == syscall.EINTR {
+				_, err = syscall.Wait4(pid, nil, 0, nil)
+			}
+			reaped = reaped || err == nil
+		}
+		if !reaped {
+			// killed processes are exiting or being reparented
+			time.Sleep(REAP_INTERVAL)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	osexec "os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// orphans are found only if limiter sees them before the process exits, they
+// are reparented to the process until it exits.
+var orphanTests = []struct {
+	name   string
+	script string
+}{
+	{"double fork", `(sleep 30 & echo $!); sleep 0.3; exit 0`},
+	{"setsid", `setsid sleep 30 & echo $!; sleep 0.3; exit 0`},
+	{"setsid double fork", `setsid sh -c 'sleep 30 & echo $!' & wait; sleep 0.3; exit 0`},
+	{"triple fork", `(sh -c 'sleep 30 & echo $!' &); sleep 0.3; exit 0`},
+}
+
+func TestNoOrphanAfterWait(t *testing.T) {
+	for _, tt := range orphanTests {
+		var stdout bytes.Buffer
+		c := Command("/bin/sh", "-c", tt.script)
+		c.Stdout = &stdout
+
+		done := make(chan error, 1)
+		go func() { done <- c.Run() }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: Wait does not return when orphan holds stdout", tt.name)
+		}
+
+		pid, err := strconv.Atoi(strings.TrimSpace(stdout.String()))
+		if err != nil {
+			t.Fatalf("%s: bad pid %q", tt.name, stdout.String())
+		}
+		if err := syscall.Kill(pid, 0); err != syscall.ESRCH {
+			t.Errorf("%s: orphan %d is still alive after Wait, kill(0) = %v", tt.name, pid, err)
+		}
+	}
+}
+
+const PR_GET_CHILD_SUBREAPER = 37
+
+func TestReapKeepsHostChildren(t *testing.T) {
+	host := osexec.Command("sleep", "30")
+	if err := host.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer host.Wait()
+	defer host.Process.Kill()
+
+	// the process may exit before it is seen by limiter
+	for i := 0; i < 20; i++ {
+		c := Command("/bin/true")
+		if err := c.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := syscall.Kill(host.Process.Pid, 0); err != nil {
+		t.Errorf("child %d of the host is killed by reap, kill(0) = %v", host.Process.Pid, err)
+	}
+
+	// a child of the host starts after the process
+	c := Command("/bin/sh", "-c", "sleep 0.2")
+	done := startLocked(t, c)
+	during := osexec.Command("sleep", "30")
+	if err := during.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer during.Wait()
+	defer during.Process.Kill()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Kill(during.Process.Pid, 0); err != nil {
+		t.Errorf("child %d of the host started during the run is killed by reap, kill(0) = %v", during.Process.Pid, err)
+	}
+
+	var flag int32
+	_, _, e := syscall.RawSyscall(syscall.SYS_PRCTL, PR_GET_CHILD_SUBREAPER, uintptr(unsafe.Pointer(&flag)), 0)
+	if e != 0 {
+		t.Fatal(e)
+	}
+	if flag != 0 {
+		t.Error("sandbox is still a child subreaper after runs")
+	}
+}
@@ -0,0 +1,135 @@
+//+build linux
+
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// MAX_CPU is the max cpu number can be set in SysAttr.CPUSet
+const MAX_CPU = 1024
+
+var (
+	ErrBadCPU     = errors.New("cpu must in [0, MAX_CPU)")
+	ErrBadCPUList = errors.New("bad cpu list, should be like 0-3,6")
+)
+
+// Scheduler keeps a pool of cpu slots, it pins every Cmd started with it
+// to a dedicated cpu and runs at most one Cmd on a cpu, others wait in Start
+// until a cpu is free, so parallel runs get stable cpu time.
+type Scheduler struct {
+	cpus []int
+	free chan int
+}
+
+// NewScheduler returns a Scheduler use cpus, all cpus sandbox can run on are
+// used if cpus is empty, they may be a part of online cpus limited by taskset or cpuset.
+func NewScheduler(cpus []int) (*Scheduler, error) {
+	if len(cpus) == 0 {
+		var err error
+		if cpus, err = affinityCPUs(); err != nil {
+			return nil, err
+		}
+	}
+	s := &Scheduler{free: make(chan int, len(cpus))}
+	seen := make(map[int]bool, len(cpus))
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= MAX_CPU {
+			return nil, ErrBadCPU
+		}
+		if seen[cpu] {
+			continue
+		}
+		seen[cpu] = true
+		s.cpus = append(s.cpus, cpu)
+		s.free <- cpu
+	}
+	return s, nil
+}
+
+// affinityCPUs returns cpus in the affinity mask of sandbox.
+func affinityCPUs() ([]int, error) {
+	var cpuMask [MAX_CPU / 64]uint64
+	_, _, e := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY, 0, unsafe.Sizeof(cpuMask), uintptr(unsafe.Pointer(&cpuMask)))
+	if e != 0 {
+		return nil, os.NewSyscallError("sched_getaffinity", e)
+	}
+	var cpus []int
+	for cpu := 0; cpu < MAX_CPU; cpu++ {
+		if cpuMask[cpu/64]&(1<<uint(cpu%64)) != 0 {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// CPUs returns cpus in the pool.
+func (s *Scheduler) CPUs() []int {
+	return append([]int(nil), s.cpus...)
+}
+
+// Busy returns how many cpus are used now.
+func (s *Scheduler) Busy() int {
+	return len(s.cpus) - len(s.free)
+}
+
+func (s *Scheduler) acquire(ctx context.Context) (int, error) {
+	if ctx == nil {
+		return <-s.free, nil
+	}
+	select {
+	case cpu := <-s.free:
+		return cpu, nil
+	case <-ctx.Done():
+		return -1, ctx.Err()
+	}
+}
+
+func (s *Scheduler) release(cpu int) {
+	s.free <- cpu
+}
+
+// ParseCPUList parse cpu list like `0-3,6`, the format of cpuset.cpus.
+func ParseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, part := range strings.Split(list, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi := part, part
+		if i := strings.Index(part, "-"); i >= 0 {
+			lo, hi = part[:i], part[i+1:]
+		}
+		from, err1 := strconv.Atoi(lo)
+		to, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || from > to {
+			return nil, ErrBadCPUList
+		}
+		if from < 0 || to >= MAX_CPU {
+			return nil, ErrBadCPU
+		}
+		for cpu := from; cpu <= to; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	if len(cpus) == 0 {
+		return nil, ErrBadCPUList
+	}
+	return cpus, nil
+}
+
+func checkCPUSet(cpus []int) error {
+	for _, cpu := range cpus {
+		if cpu < 0 || cpu >= MAX_CPU {
+			return ErrBadCPU
+		}
+	}
+	return nil
+}
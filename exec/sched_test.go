@@ -0,0 +1,113 @@
+// +build linux
+
+package exec
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseCPUList(t *testing.T) {
+	for list, want := range map[string][]int{
+		"0":        {0},
+		"0-3,6":    {0, 1, 2, 3, 6},
+		" 1 , 4-5": {1, 4, 5},
+	} {
+		got, err := ParseCPUList(list)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseCPUList(%q) = %v, %v, want %v", list, got, err, want)
+		}
+	}
+	for _, list := range []string{"", "a", "3-1", "-1", "0-1024"} {
+		if _, err := ParseCPUList(list); err == nil {
+			t.Errorf("ParseCPUList(%q) should fail", list)
+		}
+	}
+}
+
+// allowedCPUs returns cpus the test can run on.
+func allowedCPUs(t *testing.T) []int {
+	b, err := ioutil.ReadFile("/proc/self/status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "Cpus_allowed_list:") {
+			cpus, err := ParseCPUList(strings.TrimPrefix(line, "Cpus_allowed_list:"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return cpus
+		}
+	}
+	t.Fatal("no Cpus_allowed_list in /proc/self/status")
+	return nil
+}
+
+func TestNewSchedulerAffinity(t *testing.T) {
+	sched, err := NewScheduler(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sched.CPUs(), allowedCPUs(t); !reflect.DeepEqual(got, want) {
+		t.Errorf("cpus = %v, want allowed cpus %v", got, want)
+	}
+}
+
+func TestSchedulerPinOneRunPerCPU(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	cpu := allowedCPUs(t)[0]
+	sched, err := NewScheduler([]int{cpu})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 3
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var stdout bytes.Buffer
+			c := Command("/bin/sh", "-c", "grep Cpus_allowed_list /proc/self/status; sleep 0.2")
+			c.Stdout = &stdout
+			c.Scheduler = sched
+			if err := c.Run(); err != nil {
+				t.Errorf("run %d: %v", i, err)
+				return
+			}
+			if r := c.Result(); r.CPU != cpu {
+				t.Errorf("run %d: cpu = %d, want %d", i, r.CPU, cpu)
+			}
+			if got := strings.Fields(stdout.String()); len(got) != 2 || got[1] != strconv.Itoa(cpu) {
+				t.Errorf("run %d: allowed cpus = %q, want %d", i, stdout.String(), cpu)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// runs on the only cpu must be serial
+	if d := time.Since(start); d < n*200*time.Millisecond {
+		t.Errorf("%d runs finished in %v, they are not serial", n, d)
+	}
+	if sched.Busy() != 0 {
+		t.Errorf("busy cpus = %d after all runs finished", sched.Busy())
+	}
+
+	c := Command("/bin/true")
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if r := c.Result(); r.CPU != -1 {
+		t.Errorf("cpu = %d without scheduler, want -1", r.CPU)
+	}
+}
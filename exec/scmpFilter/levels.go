@@ -0,0 +1,290 @@
+package scmpFilter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+	"syscall"
+)
+
+// preset levels of syscall white list, a level allows syscalls of all levels below it
+const (
+	LEVEL_NONE    = 0 // no filter
+	LEVEL_BASIC   = 1 // single thread program reads files and writes stdout, like C, C++ and Rust
+	LEVEL_THREAD  = 2 // threads, signals and polling, like Java, Go and Python
+	LEVEL_FILE    = 3 // create and write files
+	LEVEL_PROCESS = 4 // fork, exec and wait processes, like shell and compilers
+	LEVEL_NETWORK = 5 // sockets
+	LEVEL_SYSTEM  = 6 // owner, ids and priority
+	LEVEL_ALL     = 7 // all syscalls except which break the sandbox or the host
+
+	LEVEL_MAX = LEVEL_ALL
+)
+
+var LEVEL_STR = []string{
+	"none",
+	"basic",
+	"thread",
+	"file",
+	"process",
+	"network",
+	"system",
+	"all",
+}
+
+var (
+	ErrScmpUnknownLevel    = errors.New("unknown syscall limit level")
+	ErrScmpNoExecvePointer = errors.New("execve path pointer is needed by syscall limit level")
+	ErrScmpUnknownSyscall  = errors.New("unknown syscall name")
+)
+
+// open flags which may create or change a file, they are denied below LEVEL_FILE
+const openWriteFlags = syscall.O_ACCMODE | syscall.O_CREAT | syscall.O_TRUNC
+
+// clone flags which create namespaces, the process may get privileges in them,
+// 0x02000000 is CLONE_NEWCGROUP
+var namespaceFlags = []uint64{syscall.CLONE_NEWNS, syscall.CLONE_NEWUTS, syscall.CLONE_NEWIPC,
+	syscall.CLONE_NEWUSER, syscall.CLONE_NEWPID, syscall.CLONE_NEWNET, 0x02000000}
+
+const namespaceMask = syscall.CLONE_NEWNS | syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC |
+	syscall.CLONE_NEWUSER | syscall.CLONE_NEWPID | syscall.CLONE_NEWNET | 0x02000000
+
+// syscalls allowed without condition by each level, with the names of 32-bit
+// arches, names not found on any arch of the filter are skipped
+var levelSyscalls = [LEVEL_ALL][]string{
+	LEVEL_BASIC: {
+		"read", "write", "readv", "writev", "pread64", "pwrite64", "lseek", "close",
+		"fstat", "stat", "lstat", "newfstatat", "statx", "access", "faccessat", "faccessat2",
+		"readlink", "readlinkat", "getcwd", "fcntl", "dup", "dup2", "dup3",
+		"brk", "mmap", "munmap", "mremap", "mprotect", "madvise",
+		"exit", "exit_group", "arch_prctl", "set_thread_area", "get_thread_area",
+		"set_tid_address", "set_robust_list", "get_robust_list", "rseq",
+		"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "sigaltstack",
+		"uname", "getrandom", "getrlimit", "prlimit64", "getrusage", "times", "sysinfo",
+		"clock_gettime", "clock_getres", "gettimeofday", "time", "nanosleep", "clock_nanosleep",
+		"getpid", "gettid", "getppid", "getuid", "geteuid", "getgid", "getegid", "getgroups",
+		"futex", "restart_syscall", "poll", "ppoll", "sched_getaffinity", "sched_yield",
+		// 32-bit
+		"mmap2", "fstat64", "stat64", "lstat64", "fstatat64", "_llseek", "fcntl64", "ugetrlimit",
+		"sigreturn", "sigaction", "sigprocmask", "getuid32", "geteuid32", "getgid32", "getegid32", "getgroups32",
+		"clock_gettime64", "clock_getres_time64", "clock_nanosleep_time64", "futex_time64", "ppoll_time64",
+	},
+	LEVEL_THREAD: {
+		"membarrier", "sched_setaffinity", "sched_getparam", "sched_getscheduler",
+		"sched_get_priority_max", "sched_get_priority_min", "getpriority",
+		"tgkill", "tkill", "rt_sigtimedwait", "rt_sigsuspend", "rt_sigpending", "rt_sigqueueinfo", "rt_tgsigqueueinfo",
+		"pause", "alarm", "getitimer", "setitimer",
+		"timer_create", "timer_settime", "timer_gettime", "timer_getoverrun", "timer_delete",
+		"select", "pselect6",
+		"epoll_create", "epoll_create1", "epoll_ctl", "epoll_wait", "epoll_pwait", "epoll_pwait2",
+		"eventfd", "eventfd2", "pipe", "pipe2", "timerfd_create", "timerfd_settime", "timerfd_gettime",
+		"signalfd", "signalfd4", "getdents", "getdents64", "statfs", "fstatfs", "mincore", "msync",
+		"mlock", "munlock", "prctl", "getpgrp", "getpgid", "getsid", "getresuid", "getresgid",
+		"capget", "fadvise64", "readahead", "sendfile", "splice", "tee", "copy_file_range",
+		"sched_getattr", "getcpu", "pkey_alloc", "pkey_free", "pkey_mprotect",
+		// 32-bit
+		"_newselect", "pselect6_time64", "epoll_pwait2", "sigsuspend", "sigpending", "rt_sigtimedwait_time64",
+		"timer_settime64", "timer_gettime64", "timerfd_settime64", "timerfd_gettime64",
+		"statfs64", "fstatfs64", "fadvise64_64", "sendfile64", "getresuid32", "getresgid32",
+	},
+	LEVEL_FILE: {
+		"creat", "mkdir", "mkdirat", "rmdir", "unlink", "unlinkat", "rename", "renameat", "renameat2",
+		"link", "linkat", "symlink", "symlinkat", "truncate", "ftruncate", "fallocate",
+		"fsync", "fdatasync", "sync_file_range", "syncfs", "chmod", "fchmod", "fchmodat",
+		"utime", "utimes", "utimensat", "futimesat", "flock", "chdir", "fchdir", "umask",
+		"memfd_create", "inotify_init", "inotify_init1", "inotify_add_watch", "inotify_rm_watch",
+		"getxattr", "lgetxattr", "fgetxattr", "listxattr", "llistxattr", "flistxattr", "mknod", "mknodat",
+		// 32-bit
+		"truncate64", "ftruncate64", "utimensat_time64",
+	},
+	LEVEL_PROCESS: {
+		"fork", "vfork", "execve", "execveat", "wait4", "waitid", "kill",
+		"setpgid", "setsid", "pidfd_open", "pidfd_send_signal", "pidfd_getfd",
+		// 32-bit
+		"waitpid",
+	},
+	LEVEL_NETWORK: {
+		"socket", "socketpair", "connect", "bind", "listen", "accept", "accept4",
+		"sendto", "recvfrom", "sendmsg", "recvmsg", "sendmmsg", "recvmmsg", "shutdown",
+		"getsockname", "getpeername", "setsockopt", "getsockopt",
+		// 32-bit, all socket syscalls by one
+		"socketcall", "recvmmsg_time64",
+	},
+	LEVEL_SYSTEM: {
+		"chown", "fchown", "lchown", "fchownat", "setuid", "setgid", "setreuid", "setregid",
+		"setresuid", "setresgid", "setgroups", "setfsuid", "setfsgid", "capset",
+		"setpriority", "sched_setparam", "sched_setscheduler", "sched_setattr", "setrlimit",
+		"setxattr", "lsetxattr", "fsetxattr", "removexattr", "lremovexattr", "fremovexattr",
+		"mlockall", "munlockall", "mlock2", "io_setup", "io_destroy", "io_submit", "io_cancel", "io_getevents",
+		// 32-bit
+		"chown32", "fchown32", "lchown32", "setuid32", "setgid32", "setreuid32", "setregid32",
+		"setresuid32", "setresgid32", "setgroups32", "setfsuid32", "setfsgid32", "io_pgetevents_time64",
+	},
+}
+
+// syscalls denied by LEVEL_ALL, the others are allowed
+var levelAllDenied = []string{
+	"ptrace", "process_vm_readv", "process_vm_writev", "kcmp",
+	"mount", "umount2", "pivot_root", "chroot", "setns", "unshare", "move_mount", "open_tree",
+	"fsopen", "fsconfig", "fsmount", "fspick",
+	"reboot", "kexec_load", "kexec_file_load", "init_module", "finit_module", "delete_module",
+	"swapon", "swapoff", "acct", "quotactl", "syslog", "vhangup",
+	"settimeofday", "clock_settime", "clock_adjtime", "adjtimex", "sethostname", "setdomainname",
+	"iopl", "ioperm", "bpf", "perf_event_open", "userfaultfd", "personality",
+	"add_key", "request_key", "keyctl", "name_to_handle_at", "open_by_handle_at", "lookup_dcookie",
+	"fanotify_init", "seccomp",
+	// 32-bit
+	"umount", "stime", "clock_settime64", "clock_adjtime64", "vm86", "vm86old",
+}
+
+// addLevelRules adds rules of helper.Level to scmp, the default action of scmp
+// must be helper.Action, or allow for LEVEL_ALL.
+func addLevelRules(scmp *seccomp.ScmpFilter, helper *ScmpFilterLoadHelper) error {
+	if helper.Level < LEVEL_BASIC || helper.Level > LEVEL_MAX {
+		return fmt.Errorf("%v: %d", ErrScmpUnknownLevel, helper.Level)
+	}
+	arches, err := filterArches(helper)
+	if err != nil {
+		return err
+	}
+	// clone3 hides flags in memory, so libc falls back to clone by ENOSYS
+	if err := addRule(scmp, arches, "clone3", seccomp.ActErrno.SetReturnCode(ENOSYS)); err != nil {
+		return err
+	}
+	if helper.Level == LEVEL_ALL {
+		deny := helper.Action.scmpAction()
+		for _, name := range levelAllDenied {
+			if err := addRule(scmp, arches, name, deny); err != nil {
+				return err
+			}
+		}
+		// the kernel takes the low 32 bits of the command
+		if err := addRule(scmp, arches, "ioctl", deny, cond(1, seccomp.CompareMaskedEqual, 0xffffffff, syscall.TIOCSTI)); err != nil {
+			return err
+		}
+		// rules of a syscall are or-ed, so a rule for each flag
+		for _, flag := range namespaceFlags {
+			if err := addRule(scmp, arches, "clone", deny, cond(0, seccomp.CompareMaskedEqual, flag, flag)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for level := LEVEL_BASIC; level <= helper.Level; level++ {
+		for _, name := range levelSyscalls[level] {
+			if err := addRule(scmp, arches, name, seccomp.ActAllow); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the sandbox itself execve the program after the filter is loaded
+	if helper.Level < LEVEL_PROCESS {
+		if helper.ExecvePathPointer == nil {
+			return ErrScmpNoExecvePointer
+		}
+		if err := addRule(scmp, arches, "execve", seccomp.ActAllow,
+			cond(0, seccomp.CompareEqual, uint64(uintptr(helper.ExecvePathPointer)))); err != nil {
+			return err
+		}
+	}
+
+	// TIOCSTI pushes input to the terminal, which may be the one of the host, the
+	// kernel takes the low 32 bits of the command, so allow it if any of them differs
+	for bit := uint64(1); bit <= 1<<31; bit <<= 1 {
+		if err := addRule(scmp, arches, "ioctl", seccomp.ActAllow,
+			cond(1, seccomp.CompareMaskedEqual, bit, ^uint64(syscall.TIOCSTI)&bit)); err != nil {
+			return err
+		}
+	}
+
+	if helper.Level < LEVEL_FILE {
+		// open for read only, O_CREAT and O_TRUNC change files even with O_RDONLY
+		if err := addRule(scmp, arches, "open", seccomp.ActAllow,
+			cond(1, seccomp.CompareMaskedEqual, openWriteFlags, syscall.O_RDONLY)); err != nil {
+			return err
+		}
+		if err := addRule(scmp, arches, "openat", seccomp.ActAllow,
+			cond(2, seccomp.CompareMaskedEqual, openWriteFlags, syscall.O_RDONLY)); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range []string{"open", "openat", "openat2"} {
+			if err := addRule(scmp, arches, name, seccomp.ActAllow); err != nil {
+				return err
+			}
+		}
+	}
+
+	if helper.Level >= LEVEL_PROCESS {
+		if err := addRule(scmp, arches, "clone", seccomp.ActAllow,
+			cond(0, seccomp.CompareMaskedEqual, namespaceMask, 0)); err != nil {
+			return err
+		}
+	} else if helper.Level >= LEVEL_THREAD {
+		// threads only
+		if err := addRule(scmp, arches, "clone", seccomp.ActAllow,
+			cond(0, seccomp.CompareMaskedEqual, syscall.CLONE_THREAD|namespaceMask, syscall.CLONE_THREAD)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cond is seccomp.MakeCondition, the arguments are always valid.
+func cond(arg uint, op seccomp.ScmpCompareOp, values ...uint64) seccomp.ScmpCondition {
+	c, _ := seccomp.MakeCondition(arg, op, values...)
+	return c
+}
+
+// filterArches returns the native arch and helper.Arches.
+func filterArches(helper *ScmpFilterLoadHelper) ([]seccomp.ScmpArch, error) {
+	native, err := seccomp.GetNativeArch()
+	if err != nil {
+		return nil, err
+	}
+	arches := []seccomp.ScmpArch{native}
+	for _, name := range helper.Arches {
+		arch, err := seccomp.GetArchFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		arches = append(arches, arch)
+	}
+	return arches, nil
+}
+
+// addRule adds a rule of syscall name, it is skipped if the syscall is on none
+// of arches, like mmap2 of a filter of amd64 only, or the action is the default
+// action. The rule is added by the native number, or a pseudo number if the
+// syscall is not native, and libseccomp translates it to each arch of scmp.
+func addRule(scmp *seccomp.ScmpFilter, arches []seccomp.ScmpArch, name string, action seccomp.ScmpAction, conds ...seccomp.ScmpCondition) error {
+	call, err := seccomp.GetSyscallFromName(name)
+	if err != nil {
+		return fmt.Errorf("%v: %s", ErrScmpUnknownSyscall, name)
+	}
+	found := false
+	for _, arch := range arches {
+		// a pseudo number is negative
+		if n, err := seccomp.GetSyscallFromNameByArch(name, arch); err == nil && n >= 0 {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+	if defaultAction, err := scmp.GetDefaultAction(); err == nil && defaultAction == action {
+		return nil
+	}
+	if len(conds) == 0 {
+		err = scmp.AddRule(call, action)
+	} else {
+		err = scmp.AddRuleConditionals(call, action, conds)
+	}
+	if err != nil {
+		return fmt.Errorf("add rule of %s: %v", name, err)
+	}
+	return nil
+}
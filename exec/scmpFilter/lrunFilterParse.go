@@ -1,12 +1,17 @@
 package scmpFilter
 
-import "github.com/sdibtacm/sandbox/units/seccomp"
+import (
+	"errors"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+)
 
 var (
 	lrunDefaultAction seccomp.ScmpAction
 	lrunInverseAction seccomp.ScmpAction
 )
 
+var ErrScmpLrunNotSupported = errors.New("lrun syscall filter is not supported yet")
+
 func lrunFilterParse(helper *ScmpFilterLoadHelper) (scmp *seccomp.ScmpFilter, err error) {
-	return
+	return nil, ErrScmpLrunNotSupported
 }
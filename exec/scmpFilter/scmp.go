@@ -11,6 +11,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
 	"syscall"
 	"unsafe"
 )
@@ -49,6 +50,14 @@ type ScmpFilterLoadHelper struct {
 
 	Level int
 
+	// Arches are seccomp arch names added besides the native arch, like x86
+	// and x32, rules are translated to each of them by libseccomp
+	Arches []string
+
+	// BadArchAction is the action of syscalls of arches not in the filter,
+	// only the low 4 bits are used, DEFAULT_KILL by default
+	BadArchAction ScmpAction
+
 	ExecvePathPointer unsafe.Pointer
 }
 
@@ -84,7 +93,7 @@ func GetScmpFilter(helper *ScmpFilterLoadHelper) (filter *scmpLoadFilter, err er
 	midFilter := scmpLoadFilter{}
 
 	midFilter.SetPrivs = true
-	midFilter.BPF, err = scmpToBPF(scmp)
+	midFilter.BPF, err = ScmpToBPF(scmp)
 	if err != nil {
 		return
 	}
@@ -93,33 +102,71 @@ func GetScmpFilter(helper *ScmpFilterLoadHelper) (filter *scmpLoadFilter, err er
 	return
 }
 
-func nFilterParse(helper *ScmpFilterLoadHelper) (scmp *seccomp.ScmpFilter, err error) {
-	if helper.Action&0x10 == 0x10 {
-		err = ErrScmpNotAllowDefaultActionAllow
-		g.GetLog().Warning("{}", err)
-		return
+// NewFilter returns a filter of native arch and helper.Arches, the bad arch
+// action is set by helper.BadArchAction. Rules must be added after arches are
+// added, so they are added to every arch.
+func NewFilter(helper *ScmpFilterLoadHelper, defaultAction seccomp.ScmpAction) (*seccomp.ScmpFilter, error) {
+	scmp, err := seccomp.NewFilter(defaultAction)
+	if err != nil {
+		return nil, err
+	}
+	if err = scmp.SetBadArchAction(helper.BadArchAction.scmpAction()); err != nil {
+		scmp.Release()
+		return nil, err
+	}
+	for _, name := range helper.Arches {
+		arch, err := seccomp.GetArchFromString(name)
+		if err != nil {
+			scmp.Release()
+			return nil, err
+		}
+		if err = scmp.AddArch(arch); err != nil {
+			scmp.Release()
+			return nil, err
+		}
 	}
+	return scmp, nil
+}
 
-	switch helper.Action | 0x00 {
-	case 0x00:
-		scmp, err = seccomp.NewFilter(seccomp.ActKill)
+// scmpAction returns the seccomp action of the low 4 bits. Kill is kill process,
+// or a thread of a multi-threaded program dies quietly and the others go on.
+func (a ScmpAction) scmpAction() seccomp.ScmpAction {
+	switch a & 0x0f {
 	case 0x01:
-		scmp, err = seccomp.NewFilter(seccomp.ActTrace)
+		return seccomp.ActTrace
 	case 0x02:
-		scmp, err = seccomp.NewFilter(seccomp.ActErrno.SetReturnCode(EPERM))
+		return seccomp.ActErrno.SetReturnCode(EPERM)
 	case 0x03:
-		scmp, err = seccomp.NewFilter(seccomp.ActErrno.SetReturnCode(ENOSYS))
+		return seccomp.ActErrno.SetReturnCode(ENOSYS)
 	}
+	return seccomp.ActKillProcess
+}
 
-	switch helper.Level {
-	case 1:
-
+func nFilterParse(helper *ScmpFilterLoadHelper) (scmp *seccomp.ScmpFilter, err error) {
+	if helper.Action&0x10 == 0x10 {
+		err = ErrScmpNotAllowDefaultActionAllow
+		g.GetLog().Warning("{}", err)
+		return
 	}
 
+	defaultAction := helper.Action.scmpAction()
+	if helper.Level == LEVEL_ALL {
+		// all syscalls but the denied ones
+		defaultAction = seccomp.ActAllow
+	}
+	scmp, err = NewFilter(helper, defaultAction)
+	if err != nil {
+		return
+	}
+	if err = addLevelRules(scmp, helper); err != nil {
+		scmp.Release()
+		return nil, err
+	}
 	return
 }
 
-func scmpToBPF(filter *seccomp.ScmpFilter) (BPF *syscall.SockFprog, err error) {
+// ScmpToBPF exports filter as sock_filter array which can be loaded by prctl.
+func ScmpToBPF(filter *seccomp.ScmpFilter) (BPF *syscall.SockFprog, err error) {
 
 	scmpBPFTempFile, err := ioutil.TempFile("", "sandbox-ScmpBPF-")
 	defer func() {
@@ -143,7 +190,7 @@ func scmpToBPF(filter *seccomp.ScmpFilter) (BPF *syscall.SockFprog, err error) {
 	BpfFileStat, _ := scmpBPFTempFile.Stat()
 	sockFilterFileSize := BpfFileStat.Size()
 	if sockFilterFileSize%8 != 0 {
-		err = errors.New("sockFilterFileSize error " + string(sockFilterFileSize))
+		err = errors.New("sockFilterFileSize error " + strconv.FormatInt(sockFilterFileSize, 10))
 		return
 	}
 	sockFilters := make([]syscall.SockFilter, sockFilterFileSize/8)
@@ -0,0 +1,60 @@
+//+build linux
+
+package exec
+
+import (
+	"github.com/sdibtacm/sandbox/exec/log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// signal forwarder is shared by all running Cmd, SIGINT and SIGTERM received
+// by sandbox will be sent to every running process.
+// It only catches the signals when there is any Cmd running.
+var forwarder struct {
+	mu      sync.Mutex
+	sigchan chan os.Signal
+	cmds    map[*Cmd]struct{}
+}
+
+func registerSignal(c *Cmd) {
+	forwarder.mu.Lock()
+	defer forwarder.mu.Unlock()
+
+	if forwarder.sigchan == nil {
+		forwarder.sigchan = make(chan os.Signal, 1)
+		forwarder.cmds = make(map[*Cmd]struct{})
+		go forwardSignal(forwarder.sigchan)
+	}
+	if len(forwarder.cmds) == 0 {
+		signal.Notify(forwarder.sigchan, syscall.SIGINT, syscall.SIGTERM)
+	}
+	forwarder.cmds[c] = struct{}{}
+}
+
+func unregisterSignal(c *Cmd) {
+	forwarder.mu.Lock()
+	defer forwarder.mu.Unlock()
+
+	if _, ok := forwarder.cmds[c]; !ok {
+		return
+	}
+	delete(forwarder.cmds, c)
+	if len(forwarder.cmds) == 0 {
+		signal.Stop(forwarder.sigchan)
+	}
+}
+
+func forwardSignal(sigchan chan os.Signal) {
+	for sig := range sigchan {
+		forwarder.mu.Lock()
+		for c := range forwarder.cmds {
+			if err := c.Process.Signal(sig); err != nil && err != errFinished {
+				log.GetLog().Warning("sent sig meet error: {}", err)
+			}
+		}
+		forwarder.mu.Unlock()
+	}
+}
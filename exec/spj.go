@@ -0,0 +1,156 @@
+//+build linux
+
+package exec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// exit codes of special judge, same as testlib
+const (
+	SPJ_OK                 = 0
+	SPJ_WRONG_ANSWER       = 1
+	SPJ_PRESENTATION_ERROR = 2
+	SPJ_FAIL               = 3
+	SPJ_POINTS             = 7  // points are printed at the start of message
+	SPJ_PARTIAL            = 16 // 16 + n means n percent of the score
+)
+
+// SPJ_MESSAGE_LIMIT is the max bytes of stdout and stderr of special judge kept as message.
+const SPJ_MESSAGE_LIMIT = 4096
+
+var ErrNoSpecialJudge = errors.New("exec: special judge need Command to create Cmd")
+
+// SpecialJudge is a Checker runs a program provided by problem setter as
+// `checker input output answer` in its own sandbox.
+//
+// The three files are copied and passed read only as fd 3, 4 and 5, so the
+// checker can run as any user. The arguments are /dev/fd/3, /dev/fd/4 and
+// /dev/fd/5, so /dev/fd must be there when chroot is used. A checker reopens them writable only changes the copies.
+//
+// The message is stderr of the checker, or stdout if stderr is empty.
+type SpecialJudge struct {
+	// Command creates the Cmd of the checker with its own limits and syscall
+	// level, Stdout, Stderr and ExtraFiles will be replaced.
+	Command func() *Cmd
+}
+
+func (s *SpecialJudge) Check(input, output, answer string) (*CheckResult, error) {
+	if s.Command == nil {
+		return nil, ErrNoSpecialJudge
+	}
+
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	for _, name := range []string{input, output, answer} {
+		f, err := readOnlyCopy(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	c := s.Command()
+	c.Args = append(c.argv(), "/dev/fd/3", "/dev/fd/4", "/dev/fd/5")
+	c.ExtraFiles = files
+	stdout := &prefixBuffer{max: SPJ_MESSAGE_LIMIT}
+	stderr := &prefixBuffer{max: SPJ_MESSAGE_LIMIT}
+	c.Stdout, c.Stderr = stdout, stderr
+
+	if err := c.Run(); err != nil {
+		return nil, err
+	}
+
+	r := c.Result()
+	cr := &CheckResult{Checker: r, Message: strings.TrimSpace(stderr.String())}
+	if cr.Message == "" {
+		cr.Message = strings.TrimSpace(stdout.String())
+	}
+	cr.Verdict, cr.Score = specialJudgeVerdict(r, cr.Message)
+	if cr.Verdict == VERDICT_JUDGE_ERROR {
+		msg := fmt.Sprintf("checker %s, %v", VERDICT_STR[r.Verdict], c.ProcessState)
+		if cr.Message != "" {
+			msg += ": " + cr.Message
+		}
+		cr.Message = msg
+	}
+	return cr, nil
+}
+
+// readOnlyCopy opens a copy of name read only, the copy is removed at once, so
+// nothing is left when the file is closed.
+func readOnlyCopy(name string) (*os.File, error) {
+	src, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	tmp, err := ioutil.TempFile("", "sandbox-spj-")
+	if err != nil {
+		return nil, err
+	}
+	defer tmp.Close()
+	f, err := os.Open(tmp.Name())
+	os.Remove(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(tmp, src); err == nil {
+		// the copy can not be opened by path after removed, so it is made readable
+		// for the checker running as another user, which reopens it by /dev/fd
+		err = f.Chmod(0444)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func specialJudgeVerdict(r *Result, message string) (int, float64) {
+	if v := interactorVerdict(r); v != VERDICT_JUDGE_ERROR {
+		return v, 0
+	}
+	if r.Exceed != EXCEED_NONE || r.ExitStatus.Signaled() || r.Verdict == VERDICT_BAD_SYSCALL {
+		return VERDICT_JUDGE_ERROR, 0
+	}
+
+	switch {
+	case r.ExitCode == SPJ_POINTS:
+		if fields := strings.Fields(message); len(fields) > 0 {
+			if score, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				return VERDICT_PARTIAL, score
+			}
+		}
+	case r.ExitCode >= SPJ_PARTIAL && r.ExitCode <= SPJ_PARTIAL+100:
+		return VERDICT_PARTIAL, float64(r.ExitCode - SPJ_PARTIAL)
+	}
+	return VERDICT_JUDGE_ERROR, 0
+}
+
+// prefixBuffer keeps the first max bytes written, others are dropped.
+type prefixBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	if remain := b.max - b.Len(); remain > 0 {
+		if len(p) < remain {
+			remain = len(p)
+		}
+		b.Buffer.Write(p[:remain])
+	}
+	return len(p), nil
+}
@@ -0,0 +1,100 @@
+// +build linux
+
+package exec
+
+import (
+	"context"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+const spjScript = `
+echo x >&4 2>/dev/null && { echo "output is writable" >&2; exit 3; }
+read got < "$2"; read want < "$3"
+# reopen them writable only changes the copies
+{ echo hacked 1<>"$2"; echo hacked 1<>"$3"; } 2>/dev/null
+case "$got" in
+"$want") echo "ok $got" >&2; exit 0 ;;
+half) echo "50 half of it"; exit 7 ;;
+quarter) exit 41 ;;
+crash) kill -9 $$ ;;
+esac
+echo "expected $want, got $got" >&2
+exit 1
+`
+
+func TestSpecialJudge(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	dir, err := ioutil.TempDir("", "spj-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeCases(t, dir, map[string]string{
+		"1.in": "3\n", "1.out": "3\n",
+		"2.in": "4\n", "2.out": "4\n",
+		"3.in": "half\n", "3.out": "1\n",
+		"4.in": "quarter\n", "4.out": "1\n",
+		"5.in": "crash\n", "5.out": "1\n",
+		"6.in": "6\n", "6.out": "7\n",
+	})
+	cases, err := LoadTestCases(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Batch{
+		Cases: cases,
+		Command: func(tc TestCase) (*Cmd, error) {
+			return Command("/bin/cat"), nil
+		},
+		Checker: &SpecialJudge{Command: func() *Cmd {
+			c := Command("/bin/sh", "-c", spjScript, "checker")
+			c.ResourceLimit.ClockTime = 5000
+			// the copies are readable by another user, and the filter is loaded after
+			// the uid is changed
+			if os.Geteuid() == 0 {
+				c.Sys = &SysAttr{Credential: &Credential{Uid: 65534, Gid: 65534}}
+				c.Syscall = &SyscallLimit{Level: scmpFilter.LEVEL_PROCESS, Action: 1}
+			}
+			return c
+		}},
+		Parallel: 3,
+	}
+	r, err := b.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		verdict int
+		score   float64
+		message string
+	}{
+		{VERDICT_OK, 0, "ok 3"},
+		{VERDICT_OK, 0, "ok 4"},
+		{VERDICT_PARTIAL, 50, "50 half of it"},
+		{VERDICT_PARTIAL, 25, ""},
+		{VERDICT_JUDGE_ERROR, 0, "checker runtime error, signal: killed"},
+		{VERDICT_WRONG_ANSWER, 0, "expected 7, got 6"},
+	}
+	for i, cr := range r.Cases {
+		if cr.Verdict != want[i].verdict || cr.Score != want[i].score || cr.Message != want[i].message || cr.Checker == nil {
+			t.Errorf("case %s: verdict = %s, score = %v, message = %q, want %s, %v, %q", cr.Case.Name,
+				VERDICT_STR[cr.Verdict], cr.Score, cr.Message, VERDICT_STR[want[i].verdict], want[i].score, want[i].message)
+		}
+	}
+	if r.Passed != 2 || r.Verdict != VERDICT_PARTIAL {
+		t.Errorf("passed = %d, verdict = %s, want 2 and partial", r.Passed, VERDICT_STR[r.Verdict])
+	}
+	for _, tc := range cases {
+		if b, err := ioutil.ReadFile(tc.Answer); err != nil || strings.Contains(string(b), "hacked") {
+			t.Errorf("answer %s is changed by checker: %q, %v", tc.Answer, b, err)
+		}
+	}
+}
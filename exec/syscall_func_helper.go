@@ -66,6 +66,9 @@ const (
 	PTRACE_EVENT_SECCOMP  = 7
 	PTRACE_O_TRACESECCOMP = 1 << PTRACE_EVENT_SECCOMP
 
+	PTRACE_GET_SYSCALL_INFO     = 0x420e
+	PTRACE_SYSCALL_INFO_SECCOMP = 3
+
 	PR_SET_NO_NEW_PRIVS = 38
 	PR_GET_NO_NEW_PRIVS = 39
 )
@@ -0,0 +1,98 @@
+//+build linux
+
+package exec
+
+import (
+	"sort"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// events recorded by parent in Result.Timeline, steps of child are recorded
+// with the name in SANDBOX_STEP_STR
+const (
+	TIMELINE_START          = "start"
+	TIMELINE_CPU_ACQUIRED   = "cpu acquired"
+	TIMELINE_EXEC           = "exec"
+	TIMELINE_FIRST_SAMPLE   = "first sample"
+	TIMELINE_LIMIT_EXCEEDED = "limit exceeded"
+	TIMELINE_CANCELLED      = "cancelled"
+	TIMELINE_SIGNAL_SENT    = "signal sent"
+	TIMELINE_EXIT           = "exit"
+)
+
+const CLOCK_MONOTONIC = 1
+
+// TimelineEvent is an event in the life of a run.
+type TimelineEvent struct {
+	At    time.Duration // monotonic time since Start is called
+	Event string
+	Child bool   // the event is a step completed by child before exec
+	Info  string // like which limit exceeded, or which signal sent
+}
+
+// stepRecord is written to the step pipe by child when a step is completed
+// or failed, Time is CLOCK_MONOTONIC in nanoseconds.
+type stepRecord struct {
+	Step int64
+	Time int64
+}
+
+func monotonicNow() int64 {
+	var ts syscall.Timespec
+	_, _, _ = syscall.RawSyscall(syscall.SYS_CLOCK_GETTIME, CLOCK_MONOTONIC, uintptr(unsafe.Pointer(&ts)), 0)
+	return int64(ts.Sec)*1e9 + int64(ts.Nsec)
+}
+
+// mark records an event now, c.mu must not be held.
+func (c *Cmd) mark(event, info string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.markLocked(event, info)
+}
+
+// markLocked is mark with c.mu held.
+func (c *Cmd) markLocked(event, info string) {
+	c.timeline = append(c.timeline, TimelineEvent{
+		At:    time.Duration(monotonicNow() - c.startMono),
+		Event: event,
+		Info:  info,
+	})
+}
+
+// markSteps records steps completed by child.
+func (c *Cmd) markSteps(steps []stepRecord) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range steps {
+		if s.Step < 0 || int(s.Step) >= len(SANDBOX_STEP_STR) {
+			continue
+		}
+		c.timeline = append(c.timeline, TimelineEvent{
+			At:    time.Duration(s.Time - c.startMono),
+			Event: SANDBOX_STEP_STR[s.Step],
+			Child: true,
+		})
+	}
+}
+
+// markSignal records a signal sent and reports whether it is recorded, signals
+// sent again without other signals between are not recorded, c.mu must not be held.
+func (c *Cmd) markSignal(sig syscall.Signal) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastSignal == sig {
+		return false
+	}
+	c.lastSignal = sig
+	c.markLocked(TIMELINE_SIGNAL_SENT, sig.String())
+	return true
+}
+
+// sortedTimeline returns a copy of timeline sorted by time, c.mu must be held.
+func (c *Cmd) sortedTimeline() []TimelineEvent {
+	timeline := append([]TimelineEvent(nil), c.timeline...)
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].At < timeline[j].At })
+	return timeline
+}
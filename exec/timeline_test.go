@@ -0,0 +1,96 @@
+// +build linux
+
+package exec
+
+import (
+	"github.com/boxjan/golib/logs"
+	"os"
+	"testing"
+)
+
+func TestTimeline(t *testing.T) {
+	SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	c := Command("/bin/sleep", "10")
+	c.Chdir = "/"
+	c.ResourceLimit.ClockTime = 200
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+	timeline := c.Result().Timeline
+
+	// events must happen in this order, others may be between them
+	want := []struct {
+		event string
+		child bool
+		info  string
+	}{
+		{TIMELINE_START, false, ""},
+		{SANDBOX_STEP_STR[SANDBOX_READY_FOR_CLONE], true, ""},
+		{SANDBOX_STEP_STR[SANDBOX_READY_FOR_CHDIR], true, ""},
+		{SANDBOX_STEP_STR[SANDBOX_READY_FRO_DUP_FILE], true, ""},
+		{SANDBOX_STEP_STR[SANDBOX_READY_FOR_SET_RLIMIT], true, ""},
+		{TIMELINE_EXEC, false, ""},
+		{TIMELINE_FIRST_SAMPLE, false, ""},
+		{TIMELINE_LIMIT_EXCEEDED, false, EXCEED_STR[EXCEED_CLOCK_TIME]},
+		{TIMELINE_SIGNAL_SENT, false, "killed"},
+		{TIMELINE_EXIT, false, "signal: killed"},
+	}
+	i := 0
+	for j, e := range timeline {
+		if j > 0 && e.At < timeline[j-1].At {
+			t.Errorf("timeline is not sorted: %+v", timeline)
+		}
+		if i < len(want) && e.Event == want[i].event && e.Child == want[i].child && e.Info == want[i].info {
+			i++
+		}
+	}
+	if i < len(want) {
+		t.Errorf("event %+v not found in order, timeline: %+v", want[i], timeline)
+	}
+
+	// the failed step is still reported
+	c = Command("/bin/true")
+	c.Chdir = "/no/such/dir"
+	err := c.Start()
+	if e, ok := err.(*ExecError); !ok || e.Step != SANDBOX_READY_FOR_CHDIR {
+		t.Errorf("err = %v, want fail at chdir", err)
+	}
+
+	// step pipe must not be stomped by extra files
+	var files []*os.File
+	for i := 0; i < 10; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer r.Close()
+		defer w.Close()
+		files = append(files, w)
+	}
+	c = Command("/bin/true")
+	c.ExtraFiles = files
+	if err := c.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second Start must not touch a running Cmd
+	c = Command("/bin/sleep", "10")
+	c.ResourceLimit.ClockTime = 200
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	c.mu.Lock()
+	id, logger, mono := c.ID, c.logger, c.startMono
+	c.mu.Unlock()
+	if err := c.Start(); err == nil {
+		t.Error("second Start succeeded")
+	}
+	c.mu.Lock()
+	changed := c.ID != id || c.logger != logger || c.startMono != mono
+	c.mu.Unlock()
+	if changed {
+		t.Error("second Start changed the running Cmd")
+	}
+	c.Wait()
+}
@@ -12,62 +12,130 @@ import (
 
 type ptrace struct {
 	SyscallNo uint64
+	Arch      string // seccomp arch name of the syscall
+}
+
+// seccomp arch names of AUDIT_ARCH_* in seccomp_data
+var auditArchName = map[uint32]string{
+	0x40000003: "x86",
+	0xc000003e: "amd64",
+	0x40000028: "arm",
+	0xc00000b7: "arm64",
+	0x00000008: "mips",
+	0x40000008: "mipsel",
+	0x80000008: "mips64",
+	0xc0000008: "mips64el",
+	0x80000015: "ppc64",
+	0xc0000015: "ppc64le",
+	0x80000016: "s390x",
+	0xc00000f3: "riscv64",
+}
+
+// X32_SYSCALL_BIT is set in number of x32 syscalls, their arch is amd64 in seccomp_data
+const X32_SYSCALL_BIT = 0x40000000
+
+func newPtrace(nr uint64, auditArch uint32) *ptrace {
+	arch, ok := auditArchName[auditArch]
+	if !ok {
+		arch = NativeArch()
+	}
+	if arch == "amd64" && nr&X32_SYSCALL_BIT != 0 {
+		arch = "x32"
+	}
+	return &ptrace{SyscallNo: nr, Arch: arch}
+}
+
+// struct ptrace_syscall_info of seccomp stop
+type ptraceSyscallInfo struct {
+	Op                 uint8
+	_                  [3]uint8
+	Arch               uint32
+	InstructionPointer uint64
+	StackPointer       uint64
+	Nr                 uint64
+	Args               [6]uint64
+	RetData            uint32
+	_                  uint32
+}
+
+// seccompEvent returns the syscall which makes the seccomp stop, the arch is
+// known only if PTRACE_GET_SYSCALL_INFO is supported (linux 5.3).
+func seccompEvent(pid int) *ptrace {
+	var info ptraceSyscallInfo
+	_, _, e := syscall.Syscall6(syscall.SYS_PTRACE, PTRACE_GET_SYSCALL_INFO, uintptr(pid),
+		unsafe.Sizeof(info), uintptr(unsafe.Pointer(&info)), 0, 0)
+	if e == 0 && info.Op == PTRACE_SYSCALL_INFO_SECCOMP {
+		return newPtrace(info.Nr, info.Arch)
+	}
+	var regs syscall.PtraceRegs
+	_ = syscall.PtraceGetRegs(pid, &regs)
+	return newPtrace(regs.Orig_rax, 0)
 }
 
 func (c *Cmd) wait() (ps *ProcessState, err error, pt *ptrace) {
 	if c.Sys.Ptrace {
-		return c.Process.ptraceWait()
+		return c.Process.ptraceWait(c.logger)
 	}
 
 	ps, err = c.Process.Wait()
 	return
 }
 
-func (p *Process) ptraceWait() (ps *ProcessState, err error, pt *ptrace) {
-	log.GetLog().Debug("ptrace waiting up")
+// ptraceWait waits the traced process, messages are written to l.
+func (p *Process) ptraceWait(l *log.RunLogger) (ps *ProcessState, err error, pt *ptrace) {
+	l.Debug("ptrace waiting up")
 
 	var rusage syscall.Rusage
-	var regs syscall.PtraceRegs
 	var status syscall.WaitStatus
 	var wpid int
 	ps = &ProcessState{rusage: &rusage}
 
 	wpid, err = syscall.Wait4(p.Pid, &status, 0, &rusage)
-	log.GetLog().Debug("wait pid: {} status:{}, err: {}, the message is tell parent the child is ready", wpid, status, err)
+	l.Debug("wait pid: {} status:{}, err: {}, the message is tell parent the child is ready", wpid, status, err)
 	ps.status = status
 	ps.pid = wpid
 
 	// parent will trace only seccomp event
 	_ = syscall.PtraceSetOptions(p.Pid, PTRACE_O_TRACESECCOMP)
 
+	sig := 0
 	for {
-		_ = syscall.PtraceCont(wpid, 0)
+		_ = syscall.PtraceCont(wpid, sig)
+		sig = 0
 		wpid, err = syscall.Wait4(p.Pid, &status, 0, &rusage)
 		ps.status = status
 		ps.pid = wpid
-		log.GetLog().Debug("wait pid: {} status:{}, err: {}", wpid, status, err)
+		l.Debug("wait pid: {} status:{}, err: {}", wpid, status, err)
 
 		if err != nil {
-			log.GetLog().Warning("wait4 error, error msg: {}", err)
+			l.Warning("wait4 error, error msg: {}", err)
 			_ = p.KillGroup()
+			p.setDone()
 			return nil, err, nil
 		}
 
 		if status.Exited() || status.Signaled() {
-			log.GetLog().Info("termination, exit status = {}, signal number = {}", status.ExitStatus(), status.Signaled())
+			l.Info("termination, exit status = {}, signal number = {}", status.ExitStatus(), status.Signaled())
+			p.setDone()
 			return
 		}
 
 		if status.Stopped() {
-			log.GetLog().DebugF("child stopped, signal number=%d", status.StopSignal())
+			l.DebugF("child stopped, signal number=%d", status.StopSignal())
 			if status.TrapCause() == PTRACE_EVENT_SECCOMP {
-				log.GetLog().Debug("cache a seccomp event")
-				_ = syscall.PtraceGetRegs(wpid, &regs)
-				pt = &ptrace{SyscallNo: uint64(regs.Orig_rax)}
+				l.Debug("cache a seccomp event")
+				// the first one is reported, the process may make more before SIGSYS
+				if pt == nil {
+					pt = seccompEvent(wpid)
+				}
 				_ = p.SignalGroup(syscall.SIGSYS)
+			} else if status.StopSignal() != syscall.SIGTRAP {
+				// signal-delivery-stop, pass the signal to child,
+				// or signals like the first one of KillPolicy will be lost
+				sig = int(status.StopSignal())
 			}
 		} else {
-			log.GetLog().Warning("don't know what happen, pid: {}, status: {}, err: {}", wpid, status, err)
+			l.Warning("don't know what happen, pid: {}, status: {}, err: {}", wpid, status, err)
 			_ = p.KillGroup()
 		}
 
@@ -4,5 +4,8 @@ go 1.12
 
 require (
 	github.com/boxjan/golib v0.0.0-20191111060024-5a3f8f0d606d
+	github.com/golang/protobuf v1.3.2
 	github.com/spf13/cobra v0.0.6-0.20191019221741-77e4d5aecc4d
+	github.com/spf13/pflag v1.0.3
+	google.golang.org/grpc v1.25.1
 )
@@ -0,0 +1,250 @@
+// +build linux
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/checker"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"github.com/sdibtacm/sandbox/server"
+	"github.com/sdibtacm/sandbox/units/helper"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+)
+
+var (
+	cmdJudgeTests         string
+	cmdJudgeParallel      int
+	cmdJudgeStopOnFailure bool
+	cmdJudgeOutputDir     string
+	cmdJudgeJson          bool
+	cmdJudgeChecker       string
+
+	cmdJudgeSpj             string
+	cmdJudgeSpjTimeLimit    uint // ms
+	cmdJudgeSpjMemoryLimit  string
+	cmdJudgeSpjSyscallLevel int
+	cmdJudgeSpjUid          int
+	cmdJudgeSpjGid          int
+)
+
+var judgeCmd = &cobra.Command{
+	Use:   "judge --tests dir [flags] COMMANDS",
+	Short: "Run a program against every test case in a dir and compare the outputs",
+	Long: `Run a program against every test case in a dir and compare the outputs.
+
+Every name.in in the dir is the stdin of a case, name.out (or name.ans) is its answer.
+If the dir has a file named manifest, cases are read from it, one "input answer" per line.
+All cases run with the same limits, the output is compared with the answer by --checker,
+or by a testlib style special judge given by --spj, its exit code 0 is ok, 1 wrong answer,
+2 presentation error, 7 partial with points printed first, 16+n partial with n percent.`,
+	Example:       "sandbox judge --tests tests/ --parallel 4 -t 1000 -m 256m ./main.out",
+	Args:          cobra.MinimumNArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		Init()
+		return applyConfig(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return judge(args)
+	},
+}
+
+func init() {
+	flags := judgeCmd.Flags()
+	flags.SetInterspersed(false)
+	addJudgeFlags(judgeCmd)
+	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
+	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
+	flags.BoolVar(&cmdLogVerbose, "verbose", false, "Record log verbose")
+	addRunFlags(judgeCmd)
+
+	cmd.AddCommand(judgeCmd)
+}
+
+// addJudgeFlags adds flags about test cases and checker
+func addJudgeFlags(c *cobra.Command) {
+	flags := c.Flags()
+	flags.StringVar(&cmdJudgeTests, "tests", "", "Test cases `dir`")
+	flags.IntVar(&cmdJudgeParallel, "parallel", 1, "Max cases run at the same time")
+	flags.BoolVar(&cmdJudgeStopOnFailure, "stop-on-failure", false, "Skip the rest cases after a case failed")
+	flags.StringVar(&cmdJudgeOutputDir, "output-dir", "", "Keep the output of every case in `dir`")
+	flags.BoolVar(&cmdJudgeJson, "json", false, "Print the result as JSON")
+	flags.StringVar(&cmdJudgeChecker, "checker", checker.CHECKER_LINES, "Compare output by `checker`, one of "+strings.Join(checker.CHECKER_STR, ", ")+", float can set epsilon like float:1e-4")
+	flags.StringVar(&cmdJudgeSpj, "spj", "", "Use special judge `program` as checker, it runs as `program input output answer` in its own sandbox")
+	flags.UintVar(&cmdJudgeSpjTimeLimit, "spj-max-time", 10000, "Limit clock time of special judge in milliseconds(ms)")
+	flags.StringVar(&cmdJudgeSpjMemoryLimit, "spj-max-memory", "256m", "Limit memory of special judge, `bytes` supports common suffix like `k`, `m`, `g`")
+	flags.IntVar(&cmdJudgeSpjSyscallLevel, "spj-syscall-limit-level", scmpFilter.LEVEL_BASIC, "Syscall limit level preset[0-7] of special judge, 0 disables it")
+	flags.IntVar(&cmdJudgeSpjUid, "spj-uid", server.DEFAULT_RUN_ID, "Run special judge as `uid` (must > 0) when sandbox runs as root")
+	flags.IntVar(&cmdJudgeSpjGid, "spj-gid", server.DEFAULT_RUN_ID, "Run special judge as `gid` (must > 0) when sandbox runs as root")
+	_ = c.MarkFlagRequired("tests")
+}
+
+func judge(args []string) error {
+	cases, err := exec.LoadTestCases(cmdJudgeTests)
+	if err != nil {
+		return err
+	}
+	ch, err := judgeChecker()
+	if err != nil {
+		return err
+	}
+	// check options once, so Command will not meet error
+	if err = handleCmd(exec.Command(args[0], args[1:]...)); err != nil {
+		return fmt.Errorf("handle option error: %v", err)
+	}
+
+	// handleCmd uses global files, so it can not be called at the same time
+	var mu sync.Mutex
+	b := &exec.Batch{
+		Cases: cases,
+		Command: func(tc exec.TestCase) (*exec.Cmd, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			c := exec.Command(args[0], args[1:]...)
+			if err := handleCmd(c); err != nil {
+				return nil, fmt.Errorf("handle option error: %v", err)
+			}
+			c.Stderr = nil
+			return c, nil
+		},
+		Checker:       ch,
+		Parallel:      cmdJudgeParallel,
+		StopOnFailure: cmdJudgeStopOnFailure,
+		OutputDir:     cmdJudgeOutputDir,
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	r, err := b.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if cmdJudgeJson {
+		return printJudgeJson(r)
+	}
+	printJudgeResult(r)
+	return nil
+}
+
+func judgeChecker() (exec.Checker, error) {
+	if cmdJudgeSpj != "" {
+		if cmdJudgeSpjUid <= 0 || cmdJudgeSpjGid <= 0 {
+			return nil, errors.New("--spj-uid and --spj-gid must > 0")
+		}
+		return &exec.SpecialJudge{Command: specialJudgeCmd}, nil
+	}
+	return checker.Parse(cmdJudgeChecker)
+}
+
+// signalContext returns a context canceled by SIGINT or SIGTERM
+func signalContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+	}()
+	return ctx, cancel
+}
+
+func specialJudgeCmd() *exec.Cmd {
+	c := exec.Command(cmdJudgeSpj)
+	c.ResourceLimit.ClockTime = cmdJudgeSpjTimeLimit
+	c.ResourceLimit.Memory = helper.StrToBytes(cmdJudgeSpjMemoryLimit)
+	c.Syscall = &exec.SyscallLimit{Level: cmdJudgeSpjSyscallLevel}
+	// only root can change them, others run it as themselves
+	if os.Geteuid() == 0 {
+		c.Sys = &exec.SysAttr{Credential: &exec.Credential{Uid: cmdJudgeSpjUid, Gid: cmdJudgeSpjGid}}
+	}
+	return c
+}
+
+func printJudgeResult(r *exec.BatchResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CASE\tVERDICT\tCPU(ms)\tCLOCK(ms)\tMEMORY\tMESSAGE")
+	for _, cr := range r.Cases {
+		if cr.Result == nil {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t-\t-\t-\t%s\n", cr.Case.Name, exec.VERDICT_STR[cr.Verdict], cr.Message)
+			continue
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", cr.Case.Name, exec.VERDICT_STR[cr.Verdict],
+			cr.Result.CpuTime, cr.Result.ClockTime, cr.Result.MemoryUsed, cr.Message)
+	}
+	_ = w.Flush()
+	fmt.Printf("%s, %d/%d passed, max cpu %dms, max clock %dms, max memory %d\n", exec.VERDICT_STR[r.Verdict],
+		r.Passed, len(r.Cases), r.CpuTime, r.ClockTime, r.MemoryUsed)
+}
+
+type judgeCaseJson struct {
+	Name       string  `json:"name"`
+	Verdict    string  `json:"verdict"`
+	Message    string  `json:"message,omitempty"`
+	Score      float64 `json:"score,omitempty"`
+	Skipped    bool    `json:"skipped,omitempty"`
+	CpuTime    uint    `json:"cpu_time"`
+	ClockTime  uint    `json:"clock_time"`
+	MemoryUsed uint64  `json:"memory_used"`
+	ExitCode   int     `json:"exit_code"`
+}
+
+type judgeResultJson struct {
+	Verdict    string          `json:"verdict"`
+	Passed     int             `json:"passed"`
+	Total      int             `json:"total"`
+	CpuTime    uint            `json:"cpu_time"`
+	ClockTime  uint            `json:"clock_time"`
+	MemoryUsed uint64          `json:"memory_used"`
+	Cases      []judgeCaseJson `json:"cases"`
+}
+
+func printJudgeJson(r *exec.BatchResult) error {
+	return printJson(newJudgeResultJson(r))
+}
+
+func newJudgeResultJson(r *exec.BatchResult) *judgeResultJson {
+	out := &judgeResultJson{
+		Verdict:    exec.VERDICT_STR[r.Verdict],
+		Passed:     r.Passed,
+		Total:      len(r.Cases),
+		CpuTime:    r.CpuTime,
+		ClockTime:  r.ClockTime,
+		MemoryUsed: r.MemoryUsed,
+	}
+	for _, cr := range r.Cases {
+		c := judgeCaseJson{
+			Name:    cr.Case.Name,
+			Verdict: exec.VERDICT_STR[cr.Verdict],
+			Message: cr.Message,
+			Score:   cr.Score,
+			Skipped: cr.Skipped,
+		}
+		if cr.Result != nil {
+			c.CpuTime, c.ClockTime, c.MemoryUsed = cr.Result.CpuTime, cr.Result.ClockTime, cr.Result.MemoryUsed
+			c.ExitCode = cr.Result.ExitCode
+		}
+		out.Cases = append(out.Cases, c)
+	}
+	return out
+}
+
+func printJson(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
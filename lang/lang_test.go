@@ -0,0 +1,138 @@
+// +build linux
+
+package lang
+
+import (
+	"context"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const shProfile = `[{
+	"name": "sh",
+	"source": "main.sh",
+	"compile": ["/bin/sh", "-n", "main.sh"],
+	"run": ["/bin/sh", "main.sh"],
+	"env": ["PATH=/usr/bin:/bin", "WORK={dir}"],
+	"run_limit": {"clock_time": 3000},
+	"syscall_level": 1,
+	"memory_metric": "rss"
+}]`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubmission(t *testing.T) {
+	exec.SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	dir, err := ioutil.TempDir("", "lang-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tests := filepath.Join(dir, "tests")
+	if err = os.Mkdir(tests, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFiles(t, tests, map[string]string{"1.in": "1 2\n", "1.out": "3\n", "2.in": "5 6\n", "2.out": "11\n"})
+	writeFiles(t, dir, map[string]string{
+		"profiles.json": shProfile,
+		"add.sh":        `read a b; [ "$PWD" = "$WORK" ] && echo $((a+b))`,
+		"bad.sh":        "if then fi\n",
+		"fork.sh":       "/bin/true\n",
+	})
+	cases, err := exec.LoadTestCases(tests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	profiles := DefaultProfiles()
+	if err = profiles.Load(filepath.Join(dir, "profiles.json")); err != nil {
+		t.Fatal(err)
+	}
+	p, err := profiles.Get("sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Submission{Profile: p, Source: filepath.Join(dir, "add.sh"), Batch: &exec.Batch{Cases: cases}}
+	r, err := s.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != exec.VERDICT_OK || r.Compile == nil || r.Batch == nil || r.Batch.Passed != 2 {
+		t.Errorf("verdict = %s, result = %+v, want ok", exec.VERDICT_STR[r.Verdict], r)
+	}
+
+	// syscall level of the profile is used
+	s.Source = filepath.Join(dir, "fork.sh")
+	if r, err = s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != exec.VERDICT_BAD_SYSCALL {
+		t.Errorf("verdict = %s, want bad syscall", exec.VERDICT_STR[r.Verdict])
+	}
+
+	s.Source = filepath.Join(dir, "bad.sh")
+	if r, err = s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != exec.VERDICT_COMPILE_ERROR || r.Batch != nil || !strings.Contains(string(r.Compile.Output), "main.sh") {
+		t.Errorf("verdict = %s, output = %q, want compile error", exec.VERDICT_STR[r.Verdict], r.Compile.Output)
+	}
+
+	// builtin profile
+	if _, err := exec.LookPath("gcc"); err != nil {
+		t.Skip("gcc not found")
+	}
+	writeFiles(t, dir, map[string]string{"add.c": `#include <stdio.h>
+int main() { int a, b; scanf("%d%d", &a, &b); printf("%d\n", a + b); return 0; }`})
+	if p, err = profiles.Get("c"); err != nil {
+		t.Fatal(err)
+	}
+	s = &Submission{Profile: p, Source: filepath.Join(dir, "add.c"), Batch: &exec.Batch{Cases: cases}}
+	if r, err = s.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r.Verdict != exec.VERDICT_OK {
+		t.Errorf("verdict = %s, compile output = %q", exec.VERDICT_STR[r.Verdict], r.Compile.Output)
+	}
+}
+
+func TestLoadBadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lang-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, profile := range []string{
+		`[{"name": "a", "source": "a.c"}]`,
+		`[{"name": "a", "source": "../a.c", "run": ["./a"]}]`,
+		`[{"name": "a", "source": "a.c", "run": ["./a"], "memory_metric": "pss"}]`,
+		`[{"name": "a", "source": "a.c", "run": ["./a"], "syscall_level": 8}]`,
+		`{"name": "a"}`,
+	} {
+		writeFiles(t, dir, map[string]string{"p.json": profile})
+		if err := DefaultProfiles().Load(filepath.Join(dir, "p.json")); err == nil {
+			t.Errorf("load %s should fail", profile)
+		}
+	}
+	if _, err := DefaultProfiles().Get("cobol"); err == nil {
+		t.Error("get unknown profile should fail")
+	}
+	p, _ := DefaultProfiles().Get("c")
+	if _, err := (&Submission{Profile: p}).Run(context.Background()); err != ErrNoBatch {
+		t.Errorf("run without batch = %v, want %v", err, ErrNoBatch)
+	}
+}
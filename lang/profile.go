@@ -0,0 +1,285 @@
+// +build linux
+
+// Package lang describes how to compile and run programs of a language, and
+// runs the compile-then-run pipeline of a submission.
+//
+//	profiles := lang.DefaultProfiles()
+//	p, _ := profiles.Get("cpp17")
+//	s := &lang.Submission{Profile: p, Source: "main.cpp", Batch: &exec.Batch{Cases: cases}}
+//	r, err := s.Run(ctx)
+package lang
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const DEFAULT_PATH = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// DIR_PLACEHOLDER in commands and env is replaced by the work dir.
+const DIR_PLACEHOLDER = "{dir}"
+
+var (
+	ErrUnknownLang  = errors.New("lang: unknown language")
+	ErrBadProfile   = errors.New("lang: bad profile")
+	ErrNoRunCommand = errors.New("lang: profile need run command")
+	ErrNoBatch      = errors.New("lang: submission need batch")
+)
+
+// Limit is the limits of compile or run, 0 means no limit.
+type Limit struct {
+	CpuTime   uint   `json:"cpu_time,omitempty"`   // ms
+	ClockTime uint   `json:"clock_time,omitempty"` // ms
+	Memory    uint64 `json:"memory,omitempty"`     // byte
+	Output    uint64 `json:"output,omitempty"`     // byte, stdout + stderr
+	Thread    uint   `json:"thread,omitempty"`
+}
+
+// Profile describes how to compile and run programs of a language. The source
+// is saved as Source in the work dir, and commands run in the work dir.
+type Profile struct {
+	Name         string   `json:"name"`
+	Source       string   `json:"source"`            // file name of the source, like main.cpp
+	Compile      []string `json:"compile,omitempty"` // empty if no need to compile
+	Run          []string `json:"run"`
+	Env          []string `json:"env,omitempty"`
+	CompileLimit Limit    `json:"compile_limit"`
+	RunLimit     Limit    `json:"run_limit"`
+	SyscallLevel int      `json:"syscall_level,omitempty"` // scmpFilter.LEVEL_* of run, compile is not limited
+	MemoryMetric string   `json:"memory_metric,omitempty"` // one of exec.MEMORY_METRIC_STR, default vsize
+}
+
+var defaultCompileLimit = Limit{
+	CpuTime:   10000,
+	ClockTime: 30000,
+	Memory:    2 << 30,
+	Thread:    64,
+}
+
+func runLimit(thread uint) Limit {
+	return Limit{
+		CpuTime:   1000,
+		ClockTime: 3000,
+		Memory:    256 << 20,
+		Output:    64 << 20,
+		Thread:    thread,
+	}
+}
+
+var builtinProfiles = []Profile{
+	{
+		Name:         "c11",
+		Source:       "main.c",
+		Compile:      []string{"gcc", "-O2", "-std=c11", "-DONLINE_JUDGE", "-o", "main", "main.c", "-lm"},
+		Run:          []string{DIR_PLACEHOLDER + "/main"},
+		Env:          []string{DEFAULT_PATH},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(8),
+		SyscallLevel: scmpFilter.LEVEL_BASIC,
+	},
+	{
+		Name:         "cpp14",
+		Source:       "main.cpp",
+		Compile:      []string{"g++", "-O2", "-std=c++14", "-DONLINE_JUDGE", "-o", "main", "main.cpp"},
+		Run:          []string{DIR_PLACEHOLDER + "/main"},
+		Env:          []string{DEFAULT_PATH},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(8),
+		SyscallLevel: scmpFilter.LEVEL_BASIC,
+	},
+	{
+		Name:         "cpp17",
+		Source:       "main.cpp",
+		Compile:      []string{"g++", "-O2", "-std=c++17", "-DONLINE_JUDGE", "-o", "main", "main.cpp"},
+		Run:          []string{DIR_PLACEHOLDER + "/main"},
+		Env:          []string{DEFAULT_PATH},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(8),
+		SyscallLevel: scmpFilter.LEVEL_BASIC,
+	},
+	{
+		Name:         "java",
+		Source:       "Main.java",
+		Compile:      []string{"javac", "-encoding", "UTF-8", "-J-Xmx1g", "Main.java"},
+		Run:          []string{"java", "-Xmx256m", "-Xss64m", "-XX:+UseSerialGC", "-cp", ".", "Main"},
+		Env:          []string{DEFAULT_PATH, "HOME=" + DIR_PLACEHOLDER},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(64),
+		MemoryMetric: "rss",
+		// JVM writes its perf data to /tmp/hsperfdata_*
+		SyscallLevel: scmpFilter.LEVEL_FILE,
+	},
+	{
+		Name:         "python3",
+		Source:       "main.py",
+		Compile:      []string{"python3", "-m", "py_compile", "main.py"},
+		Run:          []string{"python3", "main.py"},
+		Env:          []string{DEFAULT_PATH, "PYTHONIOENCODING=utf-8"},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(8),
+		SyscallLevel: scmpFilter.LEVEL_THREAD,
+	},
+	{
+		Name:    "go",
+		Source:  "main.go",
+		Compile: []string{"go", "build", "-o", "main", "main.go"},
+		Run:     []string{DIR_PLACEHOLDER + "/main"},
+		Env: []string{DEFAULT_PATH, "HOME=" + DIR_PLACEHOLDER, "GOCACHE=" + DIR_PLACEHOLDER + "/.cache",
+			"GOPATH=" + DIR_PLACEHOLDER + "/.go", "GO111MODULE=off", "CGO_ENABLED=0"},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(32),
+		MemoryMetric: "rss",
+		SyscallLevel: scmpFilter.LEVEL_THREAD,
+	},
+	{
+		Name:         "rust",
+		Source:       "main.rs",
+		Compile:      []string{"rustc", "-O", "--edition", "2018", "-o", "main", "main.rs"},
+		Run:          []string{DIR_PLACEHOLDER + "/main"},
+		Env:          []string{DEFAULT_PATH, "HOME=" + DIR_PLACEHOLDER},
+		CompileLimit: defaultCompileLimit,
+		RunLimit:     runLimit(8),
+		MemoryMetric: "rss",
+		SyscallLevel: scmpFilter.LEVEL_BASIC,
+	},
+}
+
+// aliases of builtin profiles
+var profileAlias = map[string]string{
+	"c":      "c11",
+	"cpp":    "cpp17",
+	"c++":    "cpp17",
+	"python": "python3",
+	"golang": "go",
+}
+
+// Profiles are profiles by name.
+type Profiles map[string]*Profile
+
+// DefaultProfiles returns builtin profiles for C, C++, Java, Python, Go and Rust.
+func DefaultProfiles() Profiles {
+	ps := Profiles{}
+	for i := range builtinProfiles {
+		p := builtinProfiles[i]
+		ps[p.Name] = &p
+	}
+	return ps
+}
+
+// Load reads a JSON array of Profile from path, a profile replaces the one
+// with the same name.
+func (ps Profiles) Load(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var profiles []*Profile
+	if err = json.Unmarshal(b, &profiles); err != nil {
+		return fmt.Errorf("%v: %v", ErrBadProfile, err)
+	}
+	for _, p := range profiles {
+		if err = p.Validate(); err != nil {
+			return err
+		}
+		ps[p.Name] = p
+	}
+	return nil
+}
+
+// Get returns the profile by name or alias.
+func (ps Profiles) Get(name string) (*Profile, error) {
+	if p, ok := ps[name]; ok {
+		return p, nil
+	}
+	if p, ok := ps[profileAlias[name]]; ok {
+		return p, nil
+	}
+	return nil, fmt.Errorf("%v: %s", ErrUnknownLang, name)
+}
+
+// Names returns names of the profiles in order.
+func (ps Profiles) Names() []string {
+	var names []string
+	for name := range ps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (p *Profile) Validate() error {
+	if p.Name == "" || p.Source == "" || strings.ContainsRune(p.Source, '/') {
+		return fmt.Errorf("%v: need name and source file name", ErrBadProfile)
+	}
+	if len(p.Run) == 0 {
+		return fmt.Errorf("%v: %s", ErrNoRunCommand, p.Name)
+	}
+	if p.SyscallLevel < scmpFilter.LEVEL_NONE || p.SyscallLevel > scmpFilter.LEVEL_MAX {
+		return fmt.Errorf("%v: unknown syscall level %d", ErrBadProfile, p.SyscallLevel)
+	}
+	if _, err := p.memoryMetric(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *Profile) memoryMetric() (int, error) {
+	if p.MemoryMetric == "" {
+		return exec.MEMORY_METRIC_VSIZE, nil
+	}
+	for metric, str := range exec.MEMORY_METRIC_STR {
+		if str == p.MemoryMetric {
+			return metric, nil
+		}
+	}
+	return 0, fmt.Errorf("%v: unknown memory metric %s", ErrBadProfile, p.MemoryMetric)
+}
+
+func expand(list []string, dir string) []string {
+	expanded := make([]string, len(list))
+	for i, s := range list {
+		expanded[i] = strings.Replace(s, DIR_PLACEHOLDER, dir, -1)
+	}
+	return expanded
+}
+
+func (p *Profile) command(ctx context.Context, dir string, argv []string, limit Limit) *exec.Cmd {
+	argv = expand(argv, dir)
+	var c *exec.Cmd
+	if ctx != nil {
+		c = exec.CommandContext(ctx, argv[0], argv[1:]...)
+	} else {
+		c = exec.Command(argv[0], argv[1:]...)
+	}
+	c.Chdir = dir
+	c.Envs = exec.ExpandEnvs(expand(p.Env, dir), map[string]string{exec.ENV_WORKDIR: dir})
+	c.ResourceLimit.CpuTime = limit.CpuTime
+	c.ResourceLimit.ClockTime = limit.ClockTime
+	c.ResourceLimit.Memory = limit.Memory
+	c.ResourceLimit.Output = limit.Output
+	c.ResourceLimit.Thread = limit.Thread
+	c.MemoryMetric, _ = p.memoryMetric()
+	return c
+}
+
+// CompileCmd returns the Cmd to compile the source in dir, nil if no need to compile.
+func (p *Profile) CompileCmd(ctx context.Context, dir string) *exec.Cmd {
+	if len(p.Compile) == 0 {
+		return nil
+	}
+	return p.command(ctx, dir, p.Compile, p.CompileLimit)
+}
+
+// RunCmd returns the Cmd to run the program in dir with the limit.
+func (p *Profile) RunCmd(dir string, limit Limit) *exec.Cmd {
+	c := p.command(nil, dir, p.Run, limit)
+	c.Syscall = &exec.SyscallLimit{Level: p.SyscallLevel}
+	return c
+}
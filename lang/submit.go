@@ -0,0 +1,135 @@
+// +build linux
+
+package lang
+
+import (
+	"bytes"
+	"context"
+	"github.com/sdibtacm/sandbox/checker"
+	"github.com/sdibtacm/sandbox/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// COMPILE_OUTPUT_LIMIT is the max bytes of compiler diagnostics, the compiler
+// is killed when it writes more.
+const COMPILE_OUTPUT_LIMIT = 64 * 1024
+
+// CompileResult is the result of compiling a source.
+type CompileResult struct {
+	Verdict int          // exec.VERDICT_OK or exec.VERDICT_COMPILE_ERROR
+	Result  *exec.Result // result of the compiler run
+	Output  []byte       // stdout and stderr of the compiler, at most COMPILE_OUTPUT_LIMIT bytes
+}
+
+// Compile compiles the source saved in dir by p, it returns nil if p need not compile.
+func Compile(ctx context.Context, p *Profile, dir string) (*CompileResult, error) {
+	c := p.CompileCmd(ctx, dir)
+	if c == nil {
+		return nil, nil
+	}
+	var output bytes.Buffer
+	c.Stdout, c.Stderr = &output, &output
+	if c.ResourceLimit.Output == exec.BYTE_UNRESOURCE || c.ResourceLimit.Output > COMPILE_OUTPUT_LIMIT {
+		c.ResourceLimit.Output = COMPILE_OUTPUT_LIMIT
+	}
+
+	if err := c.Run(); err != nil {
+		return nil, err
+	}
+
+	r := &CompileResult{Verdict: exec.VERDICT_OK, Result: c.Result(), Output: output.Bytes()}
+	if r.Result.Verdict != exec.VERDICT_OK {
+		r.Verdict = exec.VERDICT_COMPILE_ERROR
+	}
+	return r, nil
+}
+
+// Submission is a source to compile and run against test cases.
+type Submission struct {
+	Profile *Profile
+	Source  string // path of the source file
+	// RunLimit is used instead of Profile.RunLimit if it is not zero.
+	RunLimit Limit
+	// Batch runs the compiled program against test cases, if its Command is nil,
+	// it is set to run the program by the profile, and a nil Checker is set to
+	// checker.Lines().
+	Batch *exec.Batch
+	// WorkDir keeps the source and the compiled program, if empty, a temporary
+	// dir is used and removed when Run returns.
+	WorkDir string
+}
+
+// SubmitResult is the result of a submission.
+type SubmitResult struct {
+	// Verdict is VERDICT_COMPILE_ERROR if compile failed, otherwise the verdict of Batch.
+	Verdict int
+	Compile *CompileResult    // nil if the profile need not compile
+	Batch   *exec.BatchResult // nil if compile failed
+}
+
+// Run saves the source into the work dir, compiles it, and runs the test cases.
+func (s *Submission) Run(ctx context.Context) (*SubmitResult, error) {
+	if err := s.Profile.Validate(); err != nil {
+		return nil, err
+	}
+	if s.Batch == nil {
+		return nil, ErrNoBatch
+	}
+	dir := s.WorkDir
+	if dir == "" {
+		tmp, err := ioutil.TempDir("", "sandbox-submit")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(tmp)
+		dir = tmp
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// commands run in dir, so the path must not be relative
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err = copySource(s.Source, filepath.Join(dir, s.Profile.Source)); err != nil {
+		return nil, err
+	}
+
+	r := &SubmitResult{}
+	if r.Compile, err = Compile(ctx, s.Profile, dir); err != nil {
+		return nil, err
+	}
+	if r.Compile != nil && r.Compile.Verdict != exec.VERDICT_OK {
+		r.Verdict = r.Compile.Verdict
+		return r, nil
+	}
+
+	limit := s.RunLimit
+	if limit == (Limit{}) {
+		limit = s.Profile.RunLimit
+	}
+	b := *s.Batch
+	if b.Command == nil {
+		b.Command = func(tc exec.TestCase) (*exec.Cmd, error) {
+			return s.Profile.RunCmd(dir, limit), nil
+		}
+	}
+	if b.Checker == nil {
+		b.Checker = checker.Lines()
+	}
+	if r.Batch, err = b.Run(ctx); err != nil {
+		return nil, err
+	}
+	r.Verdict = r.Batch.Verdict
+	return r, nil
+}
+
+func copySource(src, dst string) error {
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, b, 0644)
+}
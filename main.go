@@ -8,12 +8,17 @@ import (
 	"github.com/boxjan/golib/logs"
 	"github.com/sdibtacm/sandbox/exec"
 	"github.com/sdibtacm/sandbox/g"
+	"github.com/sdibtacm/sandbox/metrics"
 	"github.com/sdibtacm/sandbox/units/helper"
 	"github.com/sdibtacm/sandbox/units/version"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 )
 
@@ -28,29 +33,56 @@ import (
 
 var ErrNotFile = errors.New("not a file")
 var ErrNotDir = errors.New("not a dir")
+var ErrBadFdSpec = errors.New("fd setting must like `3=path:r`")
 
 var stdinFile *os.File
 var stdoutFile *os.File
 var stderrFile *os.File
+var extraFiles []*os.File
 
 var cmd = &cobra.Command{
 	Use:   "sandbox [flags] [COMMANDS]",
 	Short: "Sandbox design for OnlineJudge",
 	Long: `A sandbox design for OnlineJudge, but also can use for calc time, memory used.
-Note: When you setting rlimit_*, will no check if the value is legal`,
-	Example:       "sandbox -t 1000 -m 16m ./main.out",
-	Args:          cobra.MinimumNArgs(1),
+
+Without subcommand, it is the same as run, see "sandbox run --help" for flags.`,
+	Example:       "sandbox run -t 1000 -m 16m ./main.out",
+	Args:          cobra.ArbitraryArgs,
 	Version:       version.Get(),
 	SilenceErrors: true,
 	SilenceUsage:  true,
-	PreRun: func(cmd *cobra.Command, args []string) {
+	PreRunE: func(cmd *cobra.Command, args []string) error {
 		Init()
+		return applyConfig(cmd)
 	},
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if cmdShowSyscallHelp {
-
+			printSyscallHelp()
+			return nil
+		}
+		if len(args) == 0 {
+			return cmd.Help()
 		}
 		run(args[0], args...)
+		return nil
+	},
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [flags] COMMANDS",
+	Short: "Run a program in sandbox and print its result",
+	Long: `Run a program in sandbox and print its result.
+Note: When you setting rlimit_*, will no check if the value is legal`,
+	Example:       "sandbox run -t 1000 -m 16m ./main.out",
+	Args:          cobra.MinimumNArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		Init()
+		return applyConfig(cmd)
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		run(args[0], args...)
 	},
 }
 
@@ -64,8 +96,11 @@ var (
 	cmdInputFilePath     string
 	cmdOutputFilePath    string
 	cmdErrOutputFilePath string
+	cmdExtraFds          []string
 
-	cmdEnvs        string
+	cmdEnvs        []string
+	cmdEnvFiles    []string
+	cmdEnvPass     []string
 	cmdUseHostEnvs bool
 	cmdChdir       string
 	cmdChroot      string
@@ -82,10 +117,23 @@ var (
 	cmdNoNewPrivs           bool
 	cmdScmpDefaultAction    int
 	cmdScmpBadSyscallAction int
+	cmdScmpArches           []string
+	cmdScmpBadArchAction    int
 
 	cmdUid   int
 	cmdGid   int
 	cmdUmask uint
+
+	cmdKillSignal string
+	cmdKillGrace  uint // ms
+	cmdKillTree   bool
+
+	cmdTty     bool
+	cmdTtySize string
+
+	cmdCPUSet string
+
+	cmdMetricsFile string
 )
 
 func init() {
@@ -108,6 +156,11 @@ func doBeforeExit() {
 	if stderrFile != nil {
 		stderrFile.Close()
 	}
+	for _, f := range extraFiles {
+		if f != nil {
+			f.Close()
+		}
+	}
 
 }
 
@@ -153,6 +206,16 @@ func run(command string, args ...string) {
 		return
 	}
 
+	if cmdMetricsFile != "" {
+		reg := metrics.New()
+		exec.AddListener(reg)
+		defer func() {
+			if err := reg.WriteFile(cmdMetricsFile); err != nil {
+				_, _ = fmt.Fprintf(os.Stderr, "write metrics error: %v\n", err)
+			}
+		}()
+	}
+
 	runtime.LockOSThread()
 	err = c.Start()
 	if err != nil {
@@ -207,6 +270,12 @@ func handleCmd(c *exec.Cmd) (err error) {
 		c.Sys.RlimitList[i] = cmdRlimit[i]
 	}
 
+	if cmdCPUSet != "" {
+		if c.Sys.CPUSet, err = exec.ParseCPUList(cmdCPUSet); err != nil {
+			return
+		}
+	}
+
 	if cmdUid > 0 || cmdGid > 0 || cmdUmask > 0 {
 		if os.Getuid() == 0 {
 			c.Sys.Credential = &exec.Credential{
@@ -238,12 +307,29 @@ func handleCmd(c *exec.Cmd) (err error) {
 	} else {
 		c.Stderr = os.Stdout
 	}
+	c.ExtraFiles = extraFiles
+
+	if cmdTty {
+		c.Tty = &exec.Tty{}
+		if cmdTtySize != "" {
+			var cols, rows uint16
+			if _, err := fmt.Sscanf(cmdTtySize, "%dx%d", &cols, &rows); err != nil {
+				return errors.New("tty size must like `80x24`")
+			}
+			c.Tty.Cols, c.Tty.Rows = cols, rows
+		}
+	}
 
 	if cmdChdir != "" {
-		stat, err1 := os.Stat(cmdChdir)
+		// chdir is in the root after chroot
+		dir := cmdChdir
+		if cmdChroot != "" {
+			dir = filepath.Join(cmdChroot, cmdChdir)
+		}
+		stat, err1 := os.Stat(dir)
 		if err1 != nil {
-			g.GetLog().Error("{} get stat with error: {}", cmdChdir, err1)
-			return &FileError{Name: cmdChdir, Err: err}
+			g.GetLog().Error("{} get stat with error: {}", dir, err1)
+			return &FileError{Name: cmdChdir, Err: err1}
 		}
 		if !stat.IsDir() {
 			g.GetLog().Error("{} not a dir", cmdChdir)
@@ -256,7 +342,7 @@ func handleCmd(c *exec.Cmd) (err error) {
 		stat, err1 := os.Stat(cmdChroot)
 		if err1 != nil {
 			g.GetLog().Error("{} get stat with error: {}", cmdChroot, err1)
-			return &FileError{Name: cmdChroot, Err: err}
+			return &FileError{Name: cmdChroot, Err: err1}
 		}
 		if !stat.IsDir() {
 			g.GetLog().Error("{} not a dir", cmdChroot)
@@ -265,13 +351,8 @@ func handleCmd(c *exec.Cmd) (err error) {
 		c.Chroot = cmdChroot
 	}
 
-	if cmdEnvs != "" {
-		envs := strings.Split(cmdEnvs, " ")
-		c.Envs = envs
-	}
-
-	if cmdUseHostEnvs {
-		c.Envs = append(c.Envs, os.Environ()...)
+	if c.Envs, err = buildEnvs(); err != nil {
+		return
 	}
 
 	c.Syscall = &exec.SyscallLimit{}
@@ -283,11 +364,90 @@ func handleCmd(c *exec.Cmd) (err error) {
 	if cmdNoNewPrivs {
 		c.Sys.SetNoNewPrivs = true
 	}
-	c.Syscall.Action = cmdScmpDefaultAction << 8 & cmdScmpBadSyscallAction
+	c.Syscall.Action = cmdScmpDefaultAction<<4 | cmdScmpBadSyscallAction
+	c.Syscall.Arches = cmdScmpArches
+	c.Syscall.BadArchAction = cmdScmpBadArchAction
+
+	if cmdKillSignal != "" || cmdKillTree {
+		c.KillPolicy = &exec.KillPolicy{
+			Grace: time.Duration(cmdKillGrace) * time.Millisecond,
+			Tree:  cmdKillTree,
+		}
+		if cmdKillSignal != "" {
+			sig, ok := parseSignal(cmdKillSignal)
+			if !ok {
+				return errors.New("unknown signal: " + cmdKillSignal)
+			}
+			c.KillPolicy.Signal = sig
+		}
+	}
 
 	return
 }
 
+// buildEnvs returns the environment of the process, later ones override the
+// same key in former ones: default env, host env, --env-pass, --env-file, --env.
+func buildEnvs() ([]string, error) {
+	workdir := cmdChdir
+	if workdir == "" {
+		workdir = "/"
+	}
+	envs := exec.DefaultEnvs(workdir)
+	if cmdUseHostEnvs {
+		envs = append(envs, os.Environ()...)
+	}
+	for _, name := range cmdEnvPass {
+		if value, ok := os.LookupEnv(name); ok {
+			envs = append(envs, name+"="+value)
+		}
+	}
+
+	vars := map[string]string{exec.ENV_WORKDIR: workdir}
+	define := func(list []string) {
+		for _, env := range list {
+			if eq := strings.IndexByte(env, '='); eq > 0 {
+				vars[env[:eq]] = env[eq+1:]
+			}
+		}
+	}
+	define(envs)
+	for _, path := range cmdEnvFiles {
+		fileEnvs, err := exec.ReadEnvFile(path, vars)
+		if err != nil {
+			return nil, err
+		}
+		define(fileEnvs)
+		envs = append(envs, fileEnvs...)
+	}
+	for _, env := range cmdEnvs {
+		if strings.IndexByte(env, '=') <= 0 {
+			return nil, errors.New("env must like `KEY=VALUE`: " + env)
+		}
+	}
+	return append(envs, exec.ExpandEnvs(cmdEnvs, vars)...), nil
+}
+
+var signalName = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"ALRM": syscall.SIGALRM,
+	"TERM": syscall.SIGTERM,
+	"XCPU": syscall.SIGXCPU,
+}
+
+// parseSignal accept signal number or name like `TERM`, `SIGTERM`
+func parseSignal(str string) (syscall.Signal, bool) {
+	if n, err := strconv.Atoi(str); err == nil && n > 0 && n < 65 {
+		return syscall.Signal(n), true
+	}
+	sig, ok := signalName[strings.TrimPrefix(strings.ToUpper(str), "SIG")]
+	return sig, ok
+}
+
 func parseFile() (err FileError) {
 	if err = parseInput(); err.Err != nil {
 		return
@@ -295,6 +455,9 @@ func parseFile() (err FileError) {
 	if err = parseOutputAndErrOutput(); err.Err != nil {
 		return
 	}
+	if err = parseExtraFds(); err.Err != nil {
+		return
+	}
 	return
 }
 
@@ -352,9 +515,100 @@ func parseOutput(path string, filePtr **os.File) (err FileError) {
 	return
 }
 
+// parseExtraFds opens files given by --fd, the format is `fd=path:mode`,
+// mode can be r, w or rw. fd must >= 3, the gap between fds will be closed in child.
+func parseExtraFds() (err FileError) {
+	for _, spec := range cmdExtraFds {
+		eq := strings.Index(spec, "=")
+		colon := strings.LastIndex(spec, ":")
+		if eq <= 0 || colon < eq {
+			g.GetLog().Error("{} not a valid fd setting", spec)
+			return FileError{Name: spec, Err: ErrBadFdSpec}
+		}
+		fd, err1 := strconv.Atoi(spec[:eq])
+		if err1 != nil || fd < 3 {
+			g.GetLog().Error("{} fd must be a number >= 3", spec)
+			return FileError{Name: spec, Err: ErrBadFdSpec}
+		}
+		path := spec[eq+1 : colon]
+
+		var flag int
+		switch spec[colon+1:] {
+		case "r":
+			flag = os.O_RDONLY
+		case "w":
+			flag = os.O_TRUNC | os.O_CREATE | os.O_WRONLY
+		case "rw":
+			flag = os.O_CREATE | os.O_RDWR
+		default:
+			g.GetLog().Error("{} mode must be r, w or rw", spec)
+			return FileError{Name: spec, Err: ErrBadFdSpec}
+		}
+
+		stat, err1 := os.Stat(path)
+		if err1 == nil && stat.IsDir() {
+			g.GetLog().Error("{} not a file", path)
+			return FileError{Name: path, Err: ErrNotFile}
+		}
+		file, err1 := os.OpenFile(path, flag, 0664)
+		if err1 != nil {
+			g.GetLog().Error("{} open file meet error: {}", path, err1)
+			return FileError{Name: path, Err: err1}
+		}
+
+		for len(extraFiles) <= fd-3 {
+			extraFiles = append(extraFiles, nil)
+		}
+		if extraFiles[fd-3] != nil {
+			file.Close()
+			g.GetLog().Error("fd {} set more than once", fd)
+			return FileError{Name: spec, Err: ErrBadFdSpec}
+		}
+		extraFiles[fd-3] = file
+	}
+	return
+}
+
 func initCmd() {
-	flags := cmd.Flags()
+	// flags of root are kept for running without subcommand
+	addRunCmdFlags(cmd)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		f.Hidden = true
+	})
+	addRunCmdFlags(runCmd)
+	cmd.AddCommand(runCmd)
+}
+
+func addRunCmdFlags(c *cobra.Command) {
+	flags := c.Flags()
 	flags.SetInterspersed(false)
+	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
+	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
+	flags.BoolVar(&cmdLogVerbose, "verbose", false, "Record log verbose")
+	flags.StringVar(&cmdMetricsFile, "metrics-file", "", "Write metrics of the run to `file` in Prometheus text format")
+
+	addIOFlags(c)
+	addRunFlags(c)
+}
+
+// addIOFlags adds flags about stdin, stdout, stderr and extra files
+func addIOFlags(c *cobra.Command) {
+	flags := c.Flags()
+	flags.StringVarP(&cmdInputFilePath, "input-path", "i", "", "stdin redirect file")
+	flags.StringVarP(&cmdOutputFilePath, "output-path", "o", "", "stdout redirect file")
+	flags.StringVarP(&cmdErrOutputFilePath, "error-path", "x", "", "stderr redirect file")
+	flags.BoolVar(&cmdTty, "tty", false, "Run with a pseudo-terminal as stdin, stdout and stderr, error-path will not be used")
+	flags.StringVar(&cmdTtySize, "tty-size", "", "Terminal size as `COLSxROWS`, like 80x24")
+	flags.StringArrayVar(&cmdExtraFds, "fd", nil, "Pass extra file to child as `fd=path:mode`, mode is r, w or rw, fd must >= 3. Can repeat")
+}
+
+// addRunFlags adds flags about limits and sandbox settings, they are shared by
+// commands run programs, and used by handleCmd.
+func addRunFlags(c *cobra.Command) {
+	flags := c.Flags()
+	flags.StringVar(&cmdConfigPath, "config", "", "Read options from JSON, YAML or TOML config `file` by its extension, flags in command line override it")
+	flags.StringVar(&cmdConfigProfile, "config-profile", "", "Use profile `name` in config file")
+
 	flags.Uint64Var(&cmdRlimit[exec.RLIMIT_CPU], "rlimit-cpu", exec.RLIMIT_UNRESOURCE, "Set rlimit_cpu")
 	flags.Uint64Var(&cmdRlimit[exec.RLIMIT_FSIZE], "rlimit-fsize", exec.RLIMIT_UNRESOURCE, "Set rlimit_fsize")
 	flags.Uint64Var(&cmdRlimit[exec.RLIMIT_DATA], "rlimit-data", exec.RLIMIT_UNRESOURCE, "Set rlimit_data")
@@ -373,16 +627,10 @@ func initCmd() {
 	flags.Uint64Var(&cmdRlimit[exec.RLIMIT_RTTIME], "rlimit-rttime", exec.RLIMIT_UNRESOURCE, "Set rlimit_rttime")
 	flags.Uint64Var(&cmdRlimit[exec.RLIMIT_NLIMITS], "rlimit-nlimits", exec.RLIMIT_UNRESOURCE, "Set rlimit_nlimits")
 
-	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
-	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
-	flags.BoolVar(&cmdLogVerbose, "verbose", false, "Record log verbose")
-
-	flags.StringVarP(&cmdInputFilePath, "input-path", "i", "", "stdin redirect file")
-	flags.StringVarP(&cmdOutputFilePath, "output-path", "o", "", "stdout redirect file")
-	flags.StringVarP(&cmdErrOutputFilePath, "error-path", "x", "", "stderr redirect file")
-
-	flags.StringVarP(&cmdEnvs, "env", "e", "", "Set exec environment, null is default")
-	flags.BoolVar(&cmdUseHostEnvs, "use-host-env", false, "Use host env, will append from envs setting")
+	flags.StringArrayVarP(&cmdEnvs, "env", "e", nil, "Set exec environment as `KEY=VALUE`, $NAME in VALUE is expanded, "+exec.ENV_WORKDIR+" is the work dir. Can repeat")
+	flags.StringArrayVar(&cmdEnvFiles, "env-file", nil, "Read environment from `file`, one KEY=VALUE per line. Can repeat")
+	flags.StringArrayVar(&cmdEnvPass, "env-pass", nil, "Pass host environment `NAME` to the process. Can repeat")
+	flags.BoolVar(&cmdUseHostEnvs, "use-host-env", false, "Pass all host environment to the process, it may leak secrets")
 	flags.StringVar(&cmdChroot, "chroot", "", "Chroot to specified `path` before exec")
 	flags.StringVar(&cmdChdir, "chdir", "", "Chdir to specified `path` after chroot")
 
@@ -395,11 +643,19 @@ func initCmd() {
 	flags.IntVarP(&cmdSyscallLevel, "syscall-limit-level", "p", 0, "Syscall limit level preset[0-7]")
 	flags.StringVar(&cmdSyscallHelper, "syscall", "", "Apply a syscall filter")
 	flags.BoolVar(&cmdShowSyscallHelp, "syscall-help", false, "show help about syscall")
+	_ = flags.MarkDeprecated("syscall-help", "use `sandbox syscalls` instead")
 	flags.BoolVar(&cmdNoNewPrivs, "no-new-privs", false, "Do not allow getting higher privileges using exec. This disables things like sudo, ping, etc. If you set syscall limit the flag will be true")
 	flags.IntVar(&cmdScmpDefaultAction, "syscall-default-action", 0, "seccomp default action, only use when user have, 0: Deny, 1: Allow")
 	flags.IntVar(&cmdScmpBadSyscallAction, "syscall-bad-syscall-action", 1, "seccomp default action, only use when user have, 0: kill, 1: Trace, 2: EPERM")
+	flags.StringArrayVar(&cmdScmpArches, "syscall-arch", nil, "Add seccomp `arch` like x86 or x32 to the filter, rules are translated to it. Arch of the executable is always added. Can repeat")
+	flags.IntVar(&cmdScmpBadArchAction, "syscall-bad-arch-action", 0, "seccomp action of syscalls of arches not in the filter, 0: kill, 1: Trace, 2: EPERM")
 
 	flags.IntVarP(&cmdUid, "uid", "u", 0, "Set uid (`uid` must > 0). Only root can use this")
 	flags.IntVarP(&cmdGid, "gid", "g", 0, "Set gid (`gid` must > 0). Only root can use this")
 	flags.UintVar(&cmdUmask, "umask", 0, "Set Mask")
+	flags.StringVar(&cmdCPUSet, "cpu-set", "", "Pin the process to `cpus`, like 0-3,6")
+
+	flags.StringVar(&cmdKillSignal, "kill-signal", "", "Signal sent first when kill the process, like TERM or XCPU, SIGKILL will be sent after grace time")
+	flags.UintVar(&cmdKillGrace, "kill-grace", 0, "Grace time in micro seconds(ms) between kill signal and SIGKILL")
+	flags.BoolVar(&cmdKillTree, "kill-tree", false, "Kill all descendants in process tree, not only the process group")
 }
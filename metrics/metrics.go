@@ -0,0 +1,228 @@
+// +build linux
+
+// Package metrics collects metrics of sandbox runs from exec.Cmd lifecycle
+// events, and exposes them in Prometheus text format.
+//
+//	reg := metrics.New()
+//	exec.AddListener(reg)
+//	http.Handle("/metrics", reg)
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// DURATION_BUCKETS are buckets of run duration histograms, in seconds.
+	DURATION_BUCKETS = []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10, 30, 60}
+	// MEMORY_BUCKETS are buckets of peak memory histogram, in bytes.
+	MEMORY_BUCKETS = []float64{1 << 20, 4 << 20, 16 << 20, 64 << 20, 128 << 20, 256 << 20, 512 << 20, 1 << 30, 2 << 30}
+)
+
+type counterVec struct {
+	name, help, label string
+	values            map[string]float64
+}
+
+func newCounterVec(name, help, label string) *counterVec {
+	return &counterVec{name: name, help: help, label: label, values: make(map[string]float64)}
+}
+
+func (v *counterVec) inc(value string) {
+	v.values[value]++
+}
+
+func (v *counterVec) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", v.name, v.help, v.name)
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s{%s=%s} %s\n", v.name, v.label, quote(k), formatFloat(v.values[k]))
+	}
+}
+
+type histogram struct {
+	name, help string
+	buckets    []float64
+	counts     []uint64 // counts[i] is number of observations <= buckets[i]
+	sum        float64
+	count      uint64
+}
+
+func newHistogram(name, help string, buckets []float64) *histogram {
+	return &histogram{name: name, help: help, buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.name, h.help, h.name)
+	for i, b := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=%s} %d\n", h.name, quote(formatFloat(b)), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, h.count)
+}
+
+func writeGauge(w io.Writer, name, help string, v float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", name, help, name, name, formatFloat(v))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func quote(s string) string {
+	return `"` + labelEscaper.Replace(s) + `"`
+}
+
+// Registry is an exec.Listener collecting metrics of runs.
+type Registry struct {
+	// QueueFunc returns runs queued outside exec, like runs waiting for a
+	// worker of server.Server, it is added to the queue depth.
+	QueueFunc func() int
+
+	mu       sync.Mutex
+	started  float64
+	running  int
+	queued   map[*exec.Cmd]bool // waiting a cpu of exec.Scheduler
+	finished *counterVec
+	failures *counterVec
+	seccomp  *counterVec
+	clock    *histogram
+	cpu      *histogram
+	memory   *histogram
+}
+
+func New() *Registry {
+	return &Registry{
+		queued:   make(map[*exec.Cmd]bool),
+		finished: newCounterVec("sandbox_runs_finished_total", "Runs finished, by verdict.", "verdict"),
+		failures: newCounterVec("sandbox_start_failures_total", "Runs failed to start, by the step of fork and exec.", "step"),
+		seccomp:  newCounterVec("sandbox_seccomp_violations_total", "Runs killed by a bad syscall, by syscall.", "syscall"),
+		clock:    newHistogram("sandbox_run_clock_seconds", "Clock time of finished runs.", DURATION_BUCKETS),
+		cpu:      newHistogram("sandbox_run_cpu_seconds", "Cpu time of finished runs.", DURATION_BUCKETS),
+		memory:   newHistogram("sandbox_run_memory_bytes", "Peak memory of finished runs.", MEMORY_BUCKETS),
+	}
+}
+
+func (r *Registry) OnQueued(c *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queued[c] = true
+}
+
+func (r *Registry) OnStart(c *exec.Cmd) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queued, c)
+	r.started++
+	r.running++
+}
+
+func (r *Registry) OnStartError(c *exec.Cmd, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.queued, c)
+	step := "prepare"
+	if e, ok := err.(*exec.ExecError); ok && e.Step >= 0 && e.Step < len(exec.SANDBOX_STEP_STR) {
+		step = exec.SANDBOX_STEP_STR[e.Step]
+	}
+	r.failures.inc(step)
+}
+
+func (r *Registry) OnFinish(c *exec.Cmd, res *exec.Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.running--
+	if res == nil {
+		r.finished.inc("wait error")
+		return
+	}
+	r.finished.inc(exec.VERDICT_STR[res.Verdict])
+	r.clock.observe(float64(res.ClockTime) / 1000)
+	r.cpu.observe(float64(res.CpuTime) / 1000)
+	r.memory.observe(float64(res.MemoryUsed))
+	if res.Syscall >= 0 {
+		name, err := seccomp.ScmpSyscall(res.Syscall).GetName()
+		if err != nil {
+			name = strconv.Itoa(res.Syscall)
+		}
+		r.seccomp.inc(name)
+	}
+}
+
+// WriteTo writes all metrics in Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	var queueOutside int
+	if r.QueueFunc != nil {
+		queueOutside = r.QueueFunc()
+	}
+
+	var buf bytes.Buffer
+	r.mu.Lock()
+	fmt.Fprintf(&buf, "# HELP sandbox_runs_started_total Runs started.\n# TYPE sandbox_runs_started_total counter\nsandbox_runs_started_total %s\n", formatFloat(r.started))
+	r.finished.write(&buf)
+	r.failures.write(&buf)
+	r.seccomp.write(&buf)
+	r.clock.write(&buf)
+	r.cpu.write(&buf)
+	r.memory.write(&buf)
+	writeGauge(&buf, "sandbox_runs_running", "Runs running now.", float64(r.running))
+	writeGauge(&buf, "sandbox_queue_depth", "Runs waiting to start.", float64(len(r.queued)+queueOutside))
+	r.mu.Unlock()
+
+	return buf.WriteTo(w)
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = r.WriteTo(w)
+}
+
+// WriteFile writes all metrics to path atomically, it can be read by textfile
+// collector of node_exporter.
+func (r *Registry) WriteFile(path string) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err = r.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
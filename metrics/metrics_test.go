@@ -0,0 +1,71 @@
+// +build linux
+
+package metrics
+
+import (
+	"bytes"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	exec.SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	reg := New()
+	reg.QueueFunc = func() int { return 2 }
+	exec.AddListener(reg)
+	defer exec.RemoveListener(reg)
+
+	for _, script := range []string{"exit 0", "exit 1", "sleep 10"} {
+		c := exec.Command("/bin/sh", "-c", script)
+		c.ResourceLimit.ClockTime = 200
+		if err := c.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := exec.Command("/bin/true")
+	c.Chdir = "/no/such/dir"
+	if err := c.Run(); err == nil {
+		t.Fatal("chdir to a missing dir should fail")
+	}
+
+	var buf bytes.Buffer
+	if _, err := reg.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, line := range []string{
+		"sandbox_runs_started_total 3",
+		`sandbox_runs_finished_total{verdict="ok"} 1`,
+		`sandbox_runs_finished_total{verdict="runtime error"} 1`,
+		`sandbox_runs_finished_total{verdict="time limit exceeded"} 1`,
+		`sandbox_start_failures_total{step="chdir"} 1`,
+		`sandbox_run_clock_seconds_bucket{le="+Inf"} 3`,
+		"sandbox_run_clock_seconds_count 3",
+		"sandbox_run_memory_bytes_count 3",
+		"sandbox_runs_running 0",
+		"sandbox_queue_depth 2",
+	} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("metrics has no line %q:\n%s", line, out)
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "sandbox-metrics-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sandbox.prom")
+	if err = reg.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Errorf("metrics file is not written, err: %v", err)
+	}
+}
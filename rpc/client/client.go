@@ -0,0 +1,112 @@
+// Package client is a Go client of the gRPC Sandbox service, judge workers
+// use it to call a local sandbox daemon over a unix socket.
+package client
+
+import (
+	"context"
+	"github.com/sdibtacm/sandbox/rpc/sandboxpb"
+	"google.golang.org/grpc"
+	"io"
+	"net"
+	"strings"
+)
+
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  sandboxpb.SandboxClient
+}
+
+// Dial connects to the sandbox daemon, addr like `unix:///run/sandbox-grpc.sock`
+// or `tcp://127.0.0.1:8081`, a path without scheme is a unix socket.
+func Dial(addr string, opts ...grpc.DialOption) (*Client, error) {
+	network, address := "unix", addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, address = addr[:i], addr[i+3:]
+	}
+	opts = append([]grpc.DialOption{
+		grpc.WithInsecure(),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}),
+	}, opts...)
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, rpc: sandboxpb.NewSandboxClient(conn)}, nil
+}
+
+// WithToken is a dial option sends token to a daemon which requires it.
+func WithToken(token string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(tokenCredentials(token))
+}
+
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity returns false, the daemon is called over a unix
+// socket or a trusted network.
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Run starts a run and waits it finished, the run is cancelled if ctx done.
+func (c *Client) Run(ctx context.Context, req *sandboxpb.RunRequest) (*sandboxpb.RunStatus, error) {
+	return c.rpc.Run(ctx, req)
+}
+
+// Start queues a run, the id in returned status is used by other methods.
+func (c *Client) Start(ctx context.Context, req *sandboxpb.RunRequest) (*sandboxpb.RunStatus, error) {
+	return c.rpc.Start(ctx, req)
+}
+
+func (c *Client) Wait(ctx context.Context, id string) (*sandboxpb.RunStatus, error) {
+	return c.rpc.Wait(ctx, &sandboxpb.RunId{Id: id})
+}
+
+func (c *Client) Cancel(ctx context.Context, id string) (*sandboxpb.RunStatus, error) {
+	return c.rpc.Cancel(ctx, &sandboxpb.RunId{Id: id})
+}
+
+// Output copies stdout and stderr of a run to stdout and stderr until the run
+// finished, a nil writer means the stream is not followed.
+func (c *Client) Output(ctx context.Context, id string, stdout, stderr io.Writer) error {
+	req := &sandboxpb.OutputRequest{Id: id}
+	if stdout != nil {
+		req.Streams = append(req.Streams, sandboxpb.Stream_STDOUT)
+	}
+	if stderr != nil {
+		req.Streams = append(req.Streams, sandboxpb.Stream_STDERR)
+	}
+	if len(req.Streams) == 0 {
+		return nil
+	}
+	stream, err := c.rpc.Output(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		w := stdout
+		if chunk.GetStream() == sandboxpb.Stream_STDERR {
+			w = stderr
+		}
+		if _, err := w.Write(chunk.GetData()); err != nil {
+			return err
+		}
+	}
+}
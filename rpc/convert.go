@@ -0,0 +1,104 @@
+// +build linux
+
+package rpc
+
+import (
+	"github.com/sdibtacm/sandbox/rpc/sandboxpb"
+	"github.com/sdibtacm/sandbox/server"
+)
+
+// FromRunRequest converts a protobuf RunRequest to server.RunRequest.
+func FromRunRequest(req *sandboxpb.RunRequest) *server.RunRequest {
+	r := &server.RunRequest{
+		Command:    req.GetCommand(),
+		Args:       req.GetArgs(),
+		Env:        req.GetEnv(),
+		Chroot:     req.GetChroot(),
+		Chdir:      req.GetChdir(),
+		Stdin:      string(req.GetStdin()),
+		StdinFile:  req.GetStdinFile(),
+		StdoutFile: req.GetStdoutFile(),
+		StderrFile: req.GetStderrFile(),
+		CaptureLog: req.GetCaptureLog(),
+	}
+	for _, f := range req.GetFiles() {
+		r.Files = append(r.Files, server.FileSpec{Fd: int(f.GetFd()), Path: f.GetPath(), Mode: f.GetMode()})
+	}
+
+	limit := req.GetLimit()
+	r.Limit = server.Limit{
+		CpuTime:   uint(limit.GetCpuTime()),
+		ClockTime: uint(limit.GetClockTime()),
+		Memory:    limit.GetMemory(),
+		Output:    limit.GetOutput(),
+		Stdout:    limit.GetStdout(),
+		Stderr:    limit.GetStderr(),
+		Thread:    uint(limit.GetThread()),
+	}
+
+	sys := req.GetSys()
+	r.Sys = server.Sys{
+		Ptrace:     sys.GetPtrace(),
+		Setsid:     sys.GetSetsid(),
+		Setctty:    sys.GetSetctty(),
+		Ctty:       int(sys.GetCtty()),
+		Rlimit:     sys.GetRlimit(),
+		Cloneflags: sys.GetCloneflags(),
+		Pdeathsig:  uint(sys.GetPdeathsig()),
+		Umask:      uint(sys.GetCredential().GetUmask()),
+		Bpf:        sys.GetBpf(),
+	}
+	for _, cpu := range sys.GetCpuSet() {
+		r.Sys.CPUSet = append(r.Sys.CPUSet, int(cpu))
+	}
+	r.Uid = int(sys.GetCredential().GetUid())
+	r.Gid = int(sys.GetCredential().GetGid())
+
+	scmp := req.GetSyscall()
+	r.Seccomp = server.Seccomp{
+		Level:         int(scmp.GetLevel()),
+		Action:        int(scmp.GetAction()),
+		Helper:        scmp.GetHelper(),
+		NoNewPrivs:    sys.GetSetNoNewPrivs(),
+		Arches:        scmp.GetArches(),
+		BadArchAction: int(scmp.GetBadArchAction()),
+	}
+	return r
+}
+
+// ToRunStatus converts server.RunStatus to a protobuf RunStatus.
+func ToRunStatus(st *server.RunStatus) *sandboxpb.RunStatus {
+	s := &sandboxpb.RunStatus{Id: st.ID, State: st.State, Error: st.Error}
+	if st.Result == nil {
+		return s
+	}
+	s.Stdout = []byte(st.Result.Stdout)
+	s.Stderr = []byte(st.Result.Stderr)
+	s.Result = &sandboxpb.Result{
+		CpuTime:         uint32(st.Result.CpuTime),
+		ClockTime:       uint32(st.Result.ClockTime),
+		MemoryUsed:      st.Result.Memory,
+		ExitStatus:      st.Result.ExitStatus,
+		ExitCode:        int32(st.Result.ExitCode),
+		Output:          st.Result.Output,
+		OutputTruncated: st.Result.OutputTruncated,
+		HelpStr:         st.Result.HelpStr,
+		Signal:          int32(st.Result.Signal),
+		Cpu:             int32(st.Result.CPU),
+		Syscall:         int32(st.Result.Syscall),
+		SyscallArch:     st.Result.SyscallArch,
+		Log:             []byte(st.Result.Log),
+	}
+	for _, e := range st.Result.Timeline {
+		s.Result.Timeline = append(s.Result.Timeline, &sandboxpb.TimelineEvent{At: e.At, Event: e.Event, Child: e.Child, Info: e.Info})
+	}
+	if b := st.Result.Binary; b != nil {
+		s.Result.Binary = &sandboxpb.Binary{Path: b.Path, Script: b.Script, Arch: b.Arch, Bits: int32(b.Bits), Static: b.Static,
+			Interpreter: b.Interpreter, Needed: b.Needed, Warnings: b.Warnings}
+	}
+	if raw := st.Result.Raw(); raw != nil {
+		s.Result.Exceed = int32(raw.Exceed)
+		s.Result.Verdict = int32(raw.Verdict)
+	}
+	return s
+}
@@ -0,0 +1,171 @@
+// +build linux
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/rpc/client"
+	"github.com/sdibtacm/sandbox/rpc/sandboxpb"
+	"github.com/sdibtacm/sandbox/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server lets requests run host commands without chroot as nobody.
+func newTestServer(workers int) *server.Server {
+	s := server.New(workers)
+	s.Policy.Unsafe = true
+	s.Policy.Dirs = []string{"/bin", "/usr/bin"}
+	return s
+}
+
+func startServer(t *testing.T, s *server.Server, opts ...grpc.DialOption) (*client.Client, func()) {
+	exec.SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	dir, err := ioutil.TempDir("", "sandbox-rpc-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "sandbox.sock")
+	l, err := server.Listen("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := NewServer(s)
+	go g.Serve(l)
+
+	c, err := client.Dial(sock, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, func() {
+		c.Close()
+		g.Stop()
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRun(t *testing.T) {
+	c, stop := startServer(t, newTestServer(2))
+	defer stop()
+	ctx := context.Background()
+
+	st, err := c.Run(ctx, &sandboxpb.RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "cat; echo err >&2; exit 3"},
+		Stdin:   []byte("hello"),
+		Limit:   &sandboxpb.Resource{CpuTime: 1000, ClockTime: 3000, Memory: 256 << 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.State != server.STATE_FINISHED || st.Result == nil {
+		t.Fatalf("unexpected status: %v", st)
+	}
+	if string(st.Stdout) != "hello" || string(st.Stderr) != "err\n" {
+		t.Errorf("unexpected output: %q %q", st.Stdout, st.Stderr)
+	}
+	if st.Result.ExitCode != 3 || st.Result.Verdict != exec.VERDICT_RUNTIME_ERROR {
+		t.Errorf("unexpected result: %v", st.Result)
+	}
+
+	st, err = c.Run(ctx, &sandboxpb.RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "sleep 10"},
+		Limit:   &sandboxpb.Resource{ClockTime: 200},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Result.Exceed != exec.EXCEED_CLOCK_TIME || st.Result.Verdict != exec.VERDICT_TIME_LIMIT_EXCEEDED {
+		t.Errorf("unexpected result: %v", st.Result)
+	}
+
+	_, err = c.Run(ctx, &sandboxpb.RunRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expect InvalidArgument, got %v", err)
+	}
+}
+
+func TestStartOutputCancel(t *testing.T) {
+	c, stop := startServer(t, newTestServer(2))
+	defer stop()
+	ctx := context.Background()
+
+	st, err := c.Start(ctx, &sandboxpb.RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo out; echo err >&2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err = c.Output(ctx, st.Id, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("unexpected output: %q %q", stdout.String(), stderr.String())
+	}
+	st, err = c.Wait(ctx, st.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Result.Verdict != exec.VERDICT_OK {
+		t.Errorf("unexpected result: %v", st.Result)
+	}
+
+	st, err = c.Start(ctx, &sandboxpb.RunRequest{Command: "/bin/sleep", Args: []string{"10"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	st, err = c.Cancel(ctx, st.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) > 5*time.Second || st.State != server.STATE_FINISHED || st.Result.Verdict != exec.VERDICT_CANCELLED {
+		t.Errorf("unexpected status: %v", st)
+	}
+
+	if _, err = c.Wait(ctx, "no-such-run"); status.Code(err) != codes.NotFound {
+		t.Errorf("expect NotFound, got %v", err)
+	}
+}
+
+func TestTokenAndPolicy(t *testing.T) {
+	newServer := func() *server.Server {
+		s := newTestServer(1)
+		s.Token = "secret"
+		return s
+	}
+	ctx := context.Background()
+	req := &sandboxpb.RunRequest{Command: "/bin/true", Limit: &sandboxpb.Resource{ClockTime: 3000}}
+
+	c, stop := startServer(t, newServer())
+	_, err := c.Run(ctx, req)
+	stop()
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("run without token: err = %v, want unauthenticated", err)
+	}
+
+	c, stop = startServer(t, newServer(), client.WithToken("secret"))
+	defer stop()
+	if st, err := c.Run(ctx, req); err != nil || st.GetResult().GetVerdict() != exec.VERDICT_OK {
+		t.Errorf("run with token: status = %v, err = %v", st, err)
+	}
+	req.StdinFile = "/etc/passwd"
+	if _, err := c.Run(ctx, req); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("run with host file: err = %v, want permission denied", err)
+	}
+}
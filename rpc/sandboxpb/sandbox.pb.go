@@ -0,0 +1,1492 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: sandbox.proto
+
+package sandboxpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type Stream int32
+
+const (
+	Stream_STDOUT Stream = 0
+	Stream_STDERR Stream = 1
+)
+
+var Stream_name = map[int32]string{
+	0: "STDOUT",
+	1: "STDERR",
+}
+
+var Stream_value = map[string]int32{
+	"STDOUT": 0,
+	"STDERR": 1,
+}
+
+func (x Stream) String() string {
+	return proto.EnumName(Stream_name, int32(x))
+}
+
+func (Stream) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{0}
+}
+
+// RunRequest is exec.Cmd, paths are paths on the host of the server.
+type RunRequest struct {
+	Command string   `protobuf:"bytes,1,opt,name=command,proto3" json:"command,omitempty"`
+	Args    []string `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	Env     []string `protobuf:"bytes,3,rep,name=env,proto3" json:"env,omitempty"`
+	Chroot  string   `protobuf:"bytes,4,opt,name=chroot,proto3" json:"chroot,omitempty"`
+	Chdir   string   `protobuf:"bytes,5,opt,name=chdir,proto3" json:"chdir,omitempty"`
+	// stdin is used when stdin_file is empty.
+	Stdin      []byte        `protobuf:"bytes,6,opt,name=stdin,proto3" json:"stdin,omitempty"`
+	StdinFile  string        `protobuf:"bytes,7,opt,name=stdin_file,json=stdinFile,proto3" json:"stdin_file,omitempty"`
+	StdoutFile string        `protobuf:"bytes,8,opt,name=stdout_file,json=stdoutFile,proto3" json:"stdout_file,omitempty"`
+	StderrFile string        `protobuf:"bytes,9,opt,name=stderr_file,json=stderrFile,proto3" json:"stderr_file,omitempty"`
+	Files      []*FileSpec   `protobuf:"bytes,10,rep,name=files,proto3" json:"files,omitempty"`
+	Limit      *Resource     `protobuf:"bytes,11,opt,name=limit,proto3" json:"limit,omitempty"`
+	Sys        *SysAttr      `protobuf:"bytes,12,opt,name=sys,proto3" json:"sys,omitempty"`
+	Syscall    *SyscallLimit `protobuf:"bytes,13,opt,name=syscall,proto3" json:"syscall,omitempty"`
+	// capture_log captures log messages of the run into Result.log.
+	CaptureLog           bool     `protobuf:"varint,14,opt,name=capture_log,json=captureLog,proto3" json:"capture_log,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RunRequest) Reset()         { *m = RunRequest{} }
+func (m *RunRequest) String() string { return proto.CompactTextString(m) }
+func (*RunRequest) ProtoMessage()    {}
+func (*RunRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{0}
+}
+
+func (m *RunRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RunRequest.Unmarshal(m, b)
+}
+func (m *RunRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RunRequest.Marshal(b, m, deterministic)
+}
+func (m *RunRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RunRequest.Merge(m, src)
+}
+func (m *RunRequest) XXX_Size() int {
+	return xxx_messageInfo_RunRequest.Size(m)
+}
+func (m *RunRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RunRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RunRequest proto.InternalMessageInfo
+
+func (m *RunRequest) GetCommand() string {
+	if m != nil {
+		return m.Command
+	}
+	return ""
+}
+
+func (m *RunRequest) GetArgs() []string {
+	if m != nil {
+		return m.Args
+	}
+	return nil
+}
+
+func (m *RunRequest) GetEnv() []string {
+	if m != nil {
+		return m.Env
+	}
+	return nil
+}
+
+func (m *RunRequest) GetChroot() string {
+	if m != nil {
+		return m.Chroot
+	}
+	return ""
+}
+
+func (m *RunRequest) GetChdir() string {
+	if m != nil {
+		return m.Chdir
+	}
+	return ""
+}
+
+func (m *RunRequest) GetStdin() []byte {
+	if m != nil {
+		return m.Stdin
+	}
+	return nil
+}
+
+func (m *RunRequest) GetStdinFile() string {
+	if m != nil {
+		return m.StdinFile
+	}
+	return ""
+}
+
+func (m *RunRequest) GetStdoutFile() string {
+	if m != nil {
+		return m.StdoutFile
+	}
+	return ""
+}
+
+func (m *RunRequest) GetStderrFile() string {
+	if m != nil {
+		return m.StderrFile
+	}
+	return ""
+}
+
+func (m *RunRequest) GetFiles() []*FileSpec {
+	if m != nil {
+		return m.Files
+	}
+	return nil
+}
+
+func (m *RunRequest) GetLimit() *Resource {
+	if m != nil {
+		return m.Limit
+	}
+	return nil
+}
+
+func (m *RunRequest) GetSys() *SysAttr {
+	if m != nil {
+		return m.Sys
+	}
+	return nil
+}
+
+func (m *RunRequest) GetSyscall() *SyscallLimit {
+	if m != nil {
+		return m.Syscall
+	}
+	return nil
+}
+
+func (m *RunRequest) GetCaptureLog() bool {
+	if m != nil {
+		return m.CaptureLog
+	}
+	return false
+}
+
+// FileSpec is an extra file passed to the process as fd.
+type FileSpec struct {
+	Fd                   int32    `protobuf:"varint,1,opt,name=fd,proto3" json:"fd,omitempty"`
+	Path                 string   `protobuf:"bytes,2,opt,name=path,proto3" json:"path,omitempty"`
+	Mode                 string   `protobuf:"bytes,3,opt,name=mode,proto3" json:"mode,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *FileSpec) Reset()         { *m = FileSpec{} }
+func (m *FileSpec) String() string { return proto.CompactTextString(m) }
+func (*FileSpec) ProtoMessage()    {}
+func (*FileSpec) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{1}
+}
+
+func (m *FileSpec) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_FileSpec.Unmarshal(m, b)
+}
+func (m *FileSpec) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_FileSpec.Marshal(b, m, deterministic)
+}
+func (m *FileSpec) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_FileSpec.Merge(m, src)
+}
+func (m *FileSpec) XXX_Size() int {
+	return xxx_messageInfo_FileSpec.Size(m)
+}
+func (m *FileSpec) XXX_DiscardUnknown() {
+	xxx_messageInfo_FileSpec.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_FileSpec proto.InternalMessageInfo
+
+func (m *FileSpec) GetFd() int32 {
+	if m != nil {
+		return m.Fd
+	}
+	return 0
+}
+
+func (m *FileSpec) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *FileSpec) GetMode() string {
+	if m != nil {
+		return m.Mode
+	}
+	return ""
+}
+
+// Resource is exec.Resource.
+type Resource struct {
+	CpuTime              uint32   `protobuf:"varint,1,opt,name=cpu_time,json=cpuTime,proto3" json:"cpu_time,omitempty"`
+	ClockTime            uint32   `protobuf:"varint,2,opt,name=clock_time,json=clockTime,proto3" json:"clock_time,omitempty"`
+	Memory               uint64   `protobuf:"varint,3,opt,name=memory,proto3" json:"memory,omitempty"`
+	Output               uint64   `protobuf:"varint,4,opt,name=output,proto3" json:"output,omitempty"`
+	Stdout               uint64   `protobuf:"varint,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr               uint64   `protobuf:"varint,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	Thread               uint32   `protobuf:"varint,7,opt,name=thread,proto3" json:"thread,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Resource) Reset()         { *m = Resource{} }
+func (m *Resource) String() string { return proto.CompactTextString(m) }
+func (*Resource) ProtoMessage()    {}
+func (*Resource) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{2}
+}
+
+func (m *Resource) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Resource.Unmarshal(m, b)
+}
+func (m *Resource) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Resource.Marshal(b, m, deterministic)
+}
+func (m *Resource) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Resource.Merge(m, src)
+}
+func (m *Resource) XXX_Size() int {
+	return xxx_messageInfo_Resource.Size(m)
+}
+func (m *Resource) XXX_DiscardUnknown() {
+	xxx_messageInfo_Resource.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Resource proto.InternalMessageInfo
+
+func (m *Resource) GetCpuTime() uint32 {
+	if m != nil {
+		return m.CpuTime
+	}
+	return 0
+}
+
+func (m *Resource) GetClockTime() uint32 {
+	if m != nil {
+		return m.ClockTime
+	}
+	return 0
+}
+
+func (m *Resource) GetMemory() uint64 {
+	if m != nil {
+		return m.Memory
+	}
+	return 0
+}
+
+func (m *Resource) GetOutput() uint64 {
+	if m != nil {
+		return m.Output
+	}
+	return 0
+}
+
+func (m *Resource) GetStdout() uint64 {
+	if m != nil {
+		return m.Stdout
+	}
+	return 0
+}
+
+func (m *Resource) GetStderr() uint64 {
+	if m != nil {
+		return m.Stderr
+	}
+	return 0
+}
+
+func (m *Resource) GetThread() uint32 {
+	if m != nil {
+		return m.Thread
+	}
+	return 0
+}
+
+// SysAttr is exec.SysAttr, files are set by RunRequest.files.
+type SysAttr struct {
+	Ptrace               bool        `protobuf:"varint,1,opt,name=ptrace,proto3" json:"ptrace,omitempty"`
+	Setsid               bool        `protobuf:"varint,2,opt,name=setsid,proto3" json:"setsid,omitempty"`
+	Setctty              bool        `protobuf:"varint,3,opt,name=setctty,proto3" json:"setctty,omitempty"`
+	Ctty                 int32       `protobuf:"varint,4,opt,name=ctty,proto3" json:"ctty,omitempty"`
+	Rlimit               []uint64    `protobuf:"varint,5,rep,packed,name=rlimit,proto3" json:"rlimit,omitempty"`
+	SetNoNewPrivs        bool        `protobuf:"varint,6,opt,name=set_no_new_privs,json=setNoNewPrivs,proto3" json:"set_no_new_privs,omitempty"`
+	Cloneflags           uint64      `protobuf:"varint,7,opt,name=cloneflags,proto3" json:"cloneflags,omitempty"`
+	Pdeathsig            uint32      `protobuf:"varint,8,opt,name=pdeathsig,proto3" json:"pdeathsig,omitempty"`
+	Credential           *Credential `protobuf:"bytes,9,opt,name=credential,proto3" json:"credential,omitempty"`
+	Bpf                  []byte      `protobuf:"bytes,10,opt,name=bpf,proto3" json:"bpf,omitempty"`
+	CpuSet               []int32     `protobuf:"varint,11,rep,packed,name=cpu_set,json=cpuSet,proto3" json:"cpu_set,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *SysAttr) Reset()         { *m = SysAttr{} }
+func (m *SysAttr) String() string { return proto.CompactTextString(m) }
+func (*SysAttr) ProtoMessage()    {}
+func (*SysAttr) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{3}
+}
+
+func (m *SysAttr) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SysAttr.Unmarshal(m, b)
+}
+func (m *SysAttr) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SysAttr.Marshal(b, m, deterministic)
+}
+func (m *SysAttr) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SysAttr.Merge(m, src)
+}
+func (m *SysAttr) XXX_Size() int {
+	return xxx_messageInfo_SysAttr.Size(m)
+}
+func (m *SysAttr) XXX_DiscardUnknown() {
+	xxx_messageInfo_SysAttr.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SysAttr proto.InternalMessageInfo
+
+func (m *SysAttr) GetPtrace() bool {
+	if m != nil {
+		return m.Ptrace
+	}
+	return false
+}
+
+func (m *SysAttr) GetSetsid() bool {
+	if m != nil {
+		return m.Setsid
+	}
+	return false
+}
+
+func (m *SysAttr) GetSetctty() bool {
+	if m != nil {
+		return m.Setctty
+	}
+	return false
+}
+
+func (m *SysAttr) GetCtty() int32 {
+	if m != nil {
+		return m.Ctty
+	}
+	return 0
+}
+
+func (m *SysAttr) GetRlimit() []uint64 {
+	if m != nil {
+		return m.Rlimit
+	}
+	return nil
+}
+
+func (m *SysAttr) GetSetNoNewPrivs() bool {
+	if m != nil {
+		return m.SetNoNewPrivs
+	}
+	return false
+}
+
+func (m *SysAttr) GetCloneflags() uint64 {
+	if m != nil {
+		return m.Cloneflags
+	}
+	return 0
+}
+
+func (m *SysAttr) GetPdeathsig() uint32 {
+	if m != nil {
+		return m.Pdeathsig
+	}
+	return 0
+}
+
+func (m *SysAttr) GetCredential() *Credential {
+	if m != nil {
+		return m.Credential
+	}
+	return nil
+}
+
+func (m *SysAttr) GetBpf() []byte {
+	if m != nil {
+		return m.Bpf
+	}
+	return nil
+}
+
+func (m *SysAttr) GetCpuSet() []int32 {
+	if m != nil {
+		return m.CpuSet
+	}
+	return nil
+}
+
+type Credential struct {
+	Uid                  int32    `protobuf:"varint,1,opt,name=uid,proto3" json:"uid,omitempty"`
+	Gid                  int32    `protobuf:"varint,2,opt,name=gid,proto3" json:"gid,omitempty"`
+	Umask                uint32   `protobuf:"varint,3,opt,name=umask,proto3" json:"umask,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Credential) Reset()         { *m = Credential{} }
+func (m *Credential) String() string { return proto.CompactTextString(m) }
+func (*Credential) ProtoMessage()    {}
+func (*Credential) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{4}
+}
+
+func (m *Credential) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Credential.Unmarshal(m, b)
+}
+func (m *Credential) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Credential.Marshal(b, m, deterministic)
+}
+func (m *Credential) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Credential.Merge(m, src)
+}
+func (m *Credential) XXX_Size() int {
+	return xxx_messageInfo_Credential.Size(m)
+}
+func (m *Credential) XXX_DiscardUnknown() {
+	xxx_messageInfo_Credential.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Credential proto.InternalMessageInfo
+
+func (m *Credential) GetUid() int32 {
+	if m != nil {
+		return m.Uid
+	}
+	return 0
+}
+
+func (m *Credential) GetGid() int32 {
+	if m != nil {
+		return m.Gid
+	}
+	return 0
+}
+
+func (m *Credential) GetUmask() uint32 {
+	if m != nil {
+		return m.Umask
+	}
+	return 0
+}
+
+// SyscallLimit is exec.SyscallLimit.
+type SyscallLimit struct {
+	Level                int32    `protobuf:"varint,1,opt,name=level,proto3" json:"level,omitempty"`
+	Action               int32    `protobuf:"varint,2,opt,name=action,proto3" json:"action,omitempty"`
+	Helper               string   `protobuf:"bytes,3,opt,name=helper,proto3" json:"helper,omitempty"`
+	Arches               []string `protobuf:"bytes,4,rep,name=arches,proto3" json:"arches,omitempty"`
+	BadArchAction        int32    `protobuf:"varint,5,opt,name=bad_arch_action,json=badArchAction,proto3" json:"bad_arch_action,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *SyscallLimit) Reset()         { *m = SyscallLimit{} }
+func (m *SyscallLimit) String() string { return proto.CompactTextString(m) }
+func (*SyscallLimit) ProtoMessage()    {}
+func (*SyscallLimit) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{5}
+}
+
+func (m *SyscallLimit) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SyscallLimit.Unmarshal(m, b)
+}
+func (m *SyscallLimit) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SyscallLimit.Marshal(b, m, deterministic)
+}
+func (m *SyscallLimit) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SyscallLimit.Merge(m, src)
+}
+func (m *SyscallLimit) XXX_Size() int {
+	return xxx_messageInfo_SyscallLimit.Size(m)
+}
+func (m *SyscallLimit) XXX_DiscardUnknown() {
+	xxx_messageInfo_SyscallLimit.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SyscallLimit proto.InternalMessageInfo
+
+func (m *SyscallLimit) GetLevel() int32 {
+	if m != nil {
+		return m.Level
+	}
+	return 0
+}
+
+func (m *SyscallLimit) GetAction() int32 {
+	if m != nil {
+		return m.Action
+	}
+	return 0
+}
+
+func (m *SyscallLimit) GetHelper() string {
+	if m != nil {
+		return m.Helper
+	}
+	return ""
+}
+
+func (m *SyscallLimit) GetArches() []string {
+	if m != nil {
+		return m.Arches
+	}
+	return nil
+}
+
+func (m *SyscallLimit) GetBadArchAction() int32 {
+	if m != nil {
+		return m.BadArchAction
+	}
+	return 0
+}
+
+// Result is exec.Result.
+type Result struct {
+	CpuTime              uint32           `protobuf:"varint,1,opt,name=cpu_time,json=cpuTime,proto3" json:"cpu_time,omitempty"`
+	ClockTime            uint32           `protobuf:"varint,2,opt,name=clock_time,json=clockTime,proto3" json:"clock_time,omitempty"`
+	MemoryUsed           uint64           `protobuf:"varint,3,opt,name=memory_used,json=memoryUsed,proto3" json:"memory_used,omitempty"`
+	ExitStatus           uint32           `protobuf:"varint,4,opt,name=exit_status,json=exitStatus,proto3" json:"exit_status,omitempty"`
+	ExitCode             int32            `protobuf:"varint,5,opt,name=exit_code,json=exitCode,proto3" json:"exit_code,omitempty"`
+	Exceed               int32            `protobuf:"varint,6,opt,name=exceed,proto3" json:"exceed,omitempty"`
+	Verdict              int32            `protobuf:"varint,7,opt,name=verdict,proto3" json:"verdict,omitempty"`
+	Output               uint64           `protobuf:"varint,8,opt,name=output,proto3" json:"output,omitempty"`
+	OutputTruncated      bool             `protobuf:"varint,9,opt,name=output_truncated,json=outputTruncated,proto3" json:"output_truncated,omitempty"`
+	HelpStr              string           `protobuf:"bytes,10,opt,name=help_str,json=helpStr,proto3" json:"help_str,omitempty"`
+	Signal               int32            `protobuf:"varint,11,opt,name=signal,proto3" json:"signal,omitempty"`
+	Cpu                  int32            `protobuf:"varint,12,opt,name=cpu,proto3" json:"cpu,omitempty"`
+	Syscall              int32            `protobuf:"varint,13,opt,name=syscall,proto3" json:"syscall,omitempty"`
+	Log                  []byte           `protobuf:"bytes,14,opt,name=log,proto3" json:"log,omitempty"`
+	Timeline             []*TimelineEvent `protobuf:"bytes,15,rep,name=timeline,proto3" json:"timeline,omitempty"`
+	Binary               *Binary          `protobuf:"bytes,16,opt,name=binary,proto3" json:"binary,omitempty"`
+	SyscallArch          string           `protobuf:"bytes,17,opt,name=syscall_arch,json=syscallArch,proto3" json:"syscall_arch,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *Result) Reset()         { *m = Result{} }
+func (m *Result) String() string { return proto.CompactTextString(m) }
+func (*Result) ProtoMessage()    {}
+func (*Result) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{6}
+}
+
+func (m *Result) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Result.Unmarshal(m, b)
+}
+func (m *Result) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Result.Marshal(b, m, deterministic)
+}
+func (m *Result) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Result.Merge(m, src)
+}
+func (m *Result) XXX_Size() int {
+	return xxx_messageInfo_Result.Size(m)
+}
+func (m *Result) XXX_DiscardUnknown() {
+	xxx_messageInfo_Result.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Result proto.InternalMessageInfo
+
+func (m *Result) GetCpuTime() uint32 {
+	if m != nil {
+		return m.CpuTime
+	}
+	return 0
+}
+
+func (m *Result) GetClockTime() uint32 {
+	if m != nil {
+		return m.ClockTime
+	}
+	return 0
+}
+
+func (m *Result) GetMemoryUsed() uint64 {
+	if m != nil {
+		return m.MemoryUsed
+	}
+	return 0
+}
+
+func (m *Result) GetExitStatus() uint32 {
+	if m != nil {
+		return m.ExitStatus
+	}
+	return 0
+}
+
+func (m *Result) GetExitCode() int32 {
+	if m != nil {
+		return m.ExitCode
+	}
+	return 0
+}
+
+func (m *Result) GetExceed() int32 {
+	if m != nil {
+		return m.Exceed
+	}
+	return 0
+}
+
+func (m *Result) GetVerdict() int32 {
+	if m != nil {
+		return m.Verdict
+	}
+	return 0
+}
+
+func (m *Result) GetOutput() uint64 {
+	if m != nil {
+		return m.Output
+	}
+	return 0
+}
+
+func (m *Result) GetOutputTruncated() bool {
+	if m != nil {
+		return m.OutputTruncated
+	}
+	return false
+}
+
+func (m *Result) GetHelpStr() string {
+	if m != nil {
+		return m.HelpStr
+	}
+	return ""
+}
+
+func (m *Result) GetSignal() int32 {
+	if m != nil {
+		return m.Signal
+	}
+	return 0
+}
+
+func (m *Result) GetCpu() int32 {
+	if m != nil {
+		return m.Cpu
+	}
+	return 0
+}
+
+func (m *Result) GetSyscall() int32 {
+	if m != nil {
+		return m.Syscall
+	}
+	return 0
+}
+
+func (m *Result) GetLog() []byte {
+	if m != nil {
+		return m.Log
+	}
+	return nil
+}
+
+func (m *Result) GetTimeline() []*TimelineEvent {
+	if m != nil {
+		return m.Timeline
+	}
+	return nil
+}
+
+func (m *Result) GetBinary() *Binary {
+	if m != nil {
+		return m.Binary
+	}
+	return nil
+}
+
+func (m *Result) GetSyscallArch() string {
+	if m != nil {
+		return m.SyscallArch
+	}
+	return ""
+}
+
+// TimelineEvent is exec.TimelineEvent.
+type TimelineEvent struct {
+	At                   int64    `protobuf:"varint,1,opt,name=at,proto3" json:"at,omitempty"`
+	Event                string   `protobuf:"bytes,2,opt,name=event,proto3" json:"event,omitempty"`
+	Child                bool     `protobuf:"varint,3,opt,name=child,proto3" json:"child,omitempty"`
+	Info                 string   `protobuf:"bytes,4,opt,name=info,proto3" json:"info,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TimelineEvent) Reset()         { *m = TimelineEvent{} }
+func (m *TimelineEvent) String() string { return proto.CompactTextString(m) }
+func (*TimelineEvent) ProtoMessage()    {}
+func (*TimelineEvent) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{7}
+}
+
+func (m *TimelineEvent) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TimelineEvent.Unmarshal(m, b)
+}
+func (m *TimelineEvent) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TimelineEvent.Marshal(b, m, deterministic)
+}
+func (m *TimelineEvent) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TimelineEvent.Merge(m, src)
+}
+func (m *TimelineEvent) XXX_Size() int {
+	return xxx_messageInfo_TimelineEvent.Size(m)
+}
+func (m *TimelineEvent) XXX_DiscardUnknown() {
+	xxx_messageInfo_TimelineEvent.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TimelineEvent proto.InternalMessageInfo
+
+func (m *TimelineEvent) GetAt() int64 {
+	if m != nil {
+		return m.At
+	}
+	return 0
+}
+
+func (m *TimelineEvent) GetEvent() string {
+	if m != nil {
+		return m.Event
+	}
+	return ""
+}
+
+func (m *TimelineEvent) GetChild() bool {
+	if m != nil {
+		return m.Child
+	}
+	return false
+}
+
+func (m *TimelineEvent) GetInfo() string {
+	if m != nil {
+		return m.Info
+	}
+	return ""
+}
+
+// Binary is exec.Binary, found by preflight before exec.
+type Binary struct {
+	Path                 string   `protobuf:"bytes,1,opt,name=path,proto3" json:"path,omitempty"`
+	Script               string   `protobuf:"bytes,2,opt,name=script,proto3" json:"script,omitempty"`
+	Arch                 string   `protobuf:"bytes,3,opt,name=arch,proto3" json:"arch,omitempty"`
+	Bits                 int32    `protobuf:"varint,4,opt,name=bits,proto3" json:"bits,omitempty"`
+	Static               bool     `protobuf:"varint,5,opt,name=static,proto3" json:"static,omitempty"`
+	Interpreter          string   `protobuf:"bytes,6,opt,name=interpreter,proto3" json:"interpreter,omitempty"`
+	Needed               []string `protobuf:"bytes,7,rep,name=needed,proto3" json:"needed,omitempty"`
+	Warnings             []string `protobuf:"bytes,8,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Binary) Reset()         { *m = Binary{} }
+func (m *Binary) String() string { return proto.CompactTextString(m) }
+func (*Binary) ProtoMessage()    {}
+func (*Binary) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{8}
+}
+
+func (m *Binary) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Binary.Unmarshal(m, b)
+}
+func (m *Binary) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Binary.Marshal(b, m, deterministic)
+}
+func (m *Binary) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Binary.Merge(m, src)
+}
+func (m *Binary) XXX_Size() int {
+	return xxx_messageInfo_Binary.Size(m)
+}
+func (m *Binary) XXX_DiscardUnknown() {
+	xxx_messageInfo_Binary.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Binary proto.InternalMessageInfo
+
+func (m *Binary) GetPath() string {
+	if m != nil {
+		return m.Path
+	}
+	return ""
+}
+
+func (m *Binary) GetScript() string {
+	if m != nil {
+		return m.Script
+	}
+	return ""
+}
+
+func (m *Binary) GetArch() string {
+	if m != nil {
+		return m.Arch
+	}
+	return ""
+}
+
+func (m *Binary) GetBits() int32 {
+	if m != nil {
+		return m.Bits
+	}
+	return 0
+}
+
+func (m *Binary) GetStatic() bool {
+	if m != nil {
+		return m.Static
+	}
+	return false
+}
+
+func (m *Binary) GetInterpreter() string {
+	if m != nil {
+		return m.Interpreter
+	}
+	return ""
+}
+
+func (m *Binary) GetNeeded() []string {
+	if m != nil {
+		return m.Needed
+	}
+	return nil
+}
+
+func (m *Binary) GetWarnings() []string {
+	if m != nil {
+		return m.Warnings
+	}
+	return nil
+}
+
+type RunId struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RunId) Reset()         { *m = RunId{} }
+func (m *RunId) String() string { return proto.CompactTextString(m) }
+func (*RunId) ProtoMessage()    {}
+func (*RunId) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{9}
+}
+
+func (m *RunId) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RunId.Unmarshal(m, b)
+}
+func (m *RunId) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RunId.Marshal(b, m, deterministic)
+}
+func (m *RunId) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RunId.Merge(m, src)
+}
+func (m *RunId) XXX_Size() int {
+	return xxx_messageInfo_RunId.Size(m)
+}
+func (m *RunId) XXX_DiscardUnknown() {
+	xxx_messageInfo_RunId.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RunId proto.InternalMessageInfo
+
+func (m *RunId) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type RunStatus struct {
+	Id                   string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	State                string   `protobuf:"bytes,2,opt,name=state,proto3" json:"state,omitempty"`
+	Error                string   `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	Result               *Result  `protobuf:"bytes,4,opt,name=result,proto3" json:"result,omitempty"`
+	Stdout               []byte   `protobuf:"bytes,5,opt,name=stdout,proto3" json:"stdout,omitempty"`
+	Stderr               []byte   `protobuf:"bytes,6,opt,name=stderr,proto3" json:"stderr,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RunStatus) Reset()         { *m = RunStatus{} }
+func (m *RunStatus) String() string { return proto.CompactTextString(m) }
+func (*RunStatus) ProtoMessage()    {}
+func (*RunStatus) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{10}
+}
+
+func (m *RunStatus) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RunStatus.Unmarshal(m, b)
+}
+func (m *RunStatus) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RunStatus.Marshal(b, m, deterministic)
+}
+func (m *RunStatus) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RunStatus.Merge(m, src)
+}
+func (m *RunStatus) XXX_Size() int {
+	return xxx_messageInfo_RunStatus.Size(m)
+}
+func (m *RunStatus) XXX_DiscardUnknown() {
+	xxx_messageInfo_RunStatus.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RunStatus proto.InternalMessageInfo
+
+func (m *RunStatus) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *RunStatus) GetState() string {
+	if m != nil {
+		return m.State
+	}
+	return ""
+}
+
+func (m *RunStatus) GetError() string {
+	if m != nil {
+		return m.Error
+	}
+	return ""
+}
+
+func (m *RunStatus) GetResult() *Result {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+func (m *RunStatus) GetStdout() []byte {
+	if m != nil {
+		return m.Stdout
+	}
+	return nil
+}
+
+func (m *RunStatus) GetStderr() []byte {
+	if m != nil {
+		return m.Stderr
+	}
+	return nil
+}
+
+type OutputRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	// streams to follow, both stdout and stderr if empty.
+	Streams              []Stream `protobuf:"varint,2,rep,packed,name=streams,proto3,enum=sandbox.Stream" json:"streams,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OutputRequest) Reset()         { *m = OutputRequest{} }
+func (m *OutputRequest) String() string { return proto.CompactTextString(m) }
+func (*OutputRequest) ProtoMessage()    {}
+func (*OutputRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{11}
+}
+
+func (m *OutputRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OutputRequest.Unmarshal(m, b)
+}
+func (m *OutputRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OutputRequest.Marshal(b, m, deterministic)
+}
+func (m *OutputRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OutputRequest.Merge(m, src)
+}
+func (m *OutputRequest) XXX_Size() int {
+	return xxx_messageInfo_OutputRequest.Size(m)
+}
+func (m *OutputRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_OutputRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OutputRequest proto.InternalMessageInfo
+
+func (m *OutputRequest) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *OutputRequest) GetStreams() []Stream {
+	if m != nil {
+		return m.Streams
+	}
+	return nil
+}
+
+type OutputChunk struct {
+	Stream               Stream   `protobuf:"varint,1,opt,name=stream,proto3,enum=sandbox.Stream" json:"stream,omitempty"`
+	Data                 []byte   `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *OutputChunk) Reset()         { *m = OutputChunk{} }
+func (m *OutputChunk) String() string { return proto.CompactTextString(m) }
+func (*OutputChunk) ProtoMessage()    {}
+func (*OutputChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_6fddaeda1f9b863c, []int{12}
+}
+
+func (m *OutputChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OutputChunk.Unmarshal(m, b)
+}
+func (m *OutputChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OutputChunk.Marshal(b, m, deterministic)
+}
+func (m *OutputChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OutputChunk.Merge(m, src)
+}
+func (m *OutputChunk) XXX_Size() int {
+	return xxx_messageInfo_OutputChunk.Size(m)
+}
+func (m *OutputChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_OutputChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OutputChunk proto.InternalMessageInfo
+
+func (m *OutputChunk) GetStream() Stream {
+	if m != nil {
+		return m.Stream
+	}
+	return Stream_STDOUT
+}
+
+func (m *OutputChunk) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterEnum("sandbox.Stream", Stream_name, Stream_value)
+	proto.RegisterType((*RunRequest)(nil), "sandbox.RunRequest")
+	proto.RegisterType((*FileSpec)(nil), "sandbox.FileSpec")
+	proto.RegisterType((*Resource)(nil), "sandbox.Resource")
+	proto.RegisterType((*SysAttr)(nil), "sandbox.SysAttr")
+	proto.RegisterType((*Credential)(nil), "sandbox.Credential")
+	proto.RegisterType((*SyscallLimit)(nil), "sandbox.SyscallLimit")
+	proto.RegisterType((*Result)(nil), "sandbox.Result")
+	proto.RegisterType((*TimelineEvent)(nil), "sandbox.TimelineEvent")
+	proto.RegisterType((*Binary)(nil), "sandbox.Binary")
+	proto.RegisterType((*RunId)(nil), "sandbox.RunId")
+	proto.RegisterType((*RunStatus)(nil), "sandbox.RunStatus")
+	proto.RegisterType((*OutputRequest)(nil), "sandbox.OutputRequest")
+	proto.RegisterType((*OutputChunk)(nil), "sandbox.OutputChunk")
+}
+
+func init() { proto.RegisterFile("sandbox.proto", fileDescriptor_6fddaeda1f9b863c) }
+
+var fileDescriptor_6fddaeda1f9b863c = []byte{
+	// 1263 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x56, 0xdd, 0x8e, 0xdb, 0xb6,
+	0x12, 0x3e, 0xfe, 0x91, 0x2d, 0x8f, 0xed, 0x5d, 0x87, 0x27, 0x27, 0xd1, 0xc9, 0x39, 0x6d, 0x5c,
+	0x5f, 0x34, 0x9b, 0xa0, 0x48, 0x83, 0xcd, 0x4d, 0x6f, 0x93, 0x6d, 0x02, 0x24, 0x08, 0x92, 0x82,
+	0xde, 0xa0, 0x40, 0x6f, 0x04, 0x5a, 0x9a, 0xb5, 0x89, 0xc8, 0x92, 0x4a, 0x52, 0x9b, 0xec, 0x13,
+	0xf4, 0x05, 0xfa, 0x00, 0x7d, 0x8d, 0x02, 0xbd, 0xee, 0x45, 0x5f, 0xaa, 0xc5, 0x0c, 0x29, 0xaf,
+	0x77, 0x91, 0x02, 0x01, 0x7a, 0x37, 0xdf, 0x37, 0xc3, 0x11, 0x35, 0xfc, 0x66, 0x48, 0x98, 0x5a,
+	0x55, 0xe6, 0xab, 0xea, 0xc3, 0xc3, 0xda, 0x54, 0xae, 0x12, 0xc3, 0x00, 0x17, 0xbf, 0xf6, 0x00,
+	0x64, 0x53, 0x4a, 0xfc, 0xb1, 0x41, 0xeb, 0x44, 0x02, 0xc3, 0xac, 0xda, 0x6e, 0x55, 0x99, 0x27,
+	0x9d, 0x79, 0xe7, 0x68, 0x24, 0x5b, 0x28, 0x04, 0xf4, 0x95, 0x59, 0xdb, 0xa4, 0x3b, 0xef, 0x1d,
+	0x8d, 0x24, 0xdb, 0x62, 0x06, 0x3d, 0x2c, 0xcf, 0x93, 0x1e, 0x53, 0x64, 0x8a, 0x5b, 0x30, 0xc8,
+	0x36, 0xa6, 0xaa, 0x5c, 0xd2, 0xe7, 0xe5, 0x01, 0x89, 0x9b, 0x10, 0x65, 0x9b, 0x5c, 0x9b, 0x24,
+	0x62, 0xda, 0x03, 0x62, 0xad, 0xcb, 0x75, 0x99, 0x0c, 0xe6, 0x9d, 0xa3, 0x89, 0xf4, 0x40, 0x7c,
+	0x06, 0xc0, 0x46, 0x7a, 0xa6, 0x0b, 0x4c, 0x86, 0xbc, 0x60, 0xc4, 0xcc, 0x73, 0x5d, 0xa0, 0xb8,
+	0x0b, 0x63, 0xeb, 0xf2, 0xaa, 0x71, 0xde, 0x1f, 0xb3, 0x1f, 0x3c, 0xb5, 0x17, 0x80, 0xc6, 0xf8,
+	0x80, 0xd1, 0x2e, 0x00, 0x8d, 0xe1, 0x80, 0x7b, 0x10, 0x91, 0xc7, 0x26, 0x30, 0xef, 0x1d, 0x8d,
+	0x8f, 0x6f, 0x3c, 0x6c, 0x6b, 0x43, 0xde, 0x65, 0x8d, 0x99, 0xf4, 0x7e, 0x0a, 0x2c, 0xf4, 0x56,
+	0xbb, 0x64, 0x3c, 0xef, 0x5c, 0x09, 0x94, 0x68, 0xab, 0xc6, 0x64, 0x28, 0xbd, 0x5f, 0x2c, 0xa0,
+	0x67, 0x2f, 0x6c, 0x32, 0xe1, 0xb0, 0xd9, 0x2e, 0x6c, 0x79, 0x61, 0x9f, 0x38, 0x67, 0x24, 0x39,
+	0xc5, 0xd7, 0x30, 0xb4, 0x17, 0x36, 0x53, 0x45, 0x91, 0x4c, 0x39, 0xee, 0x3f, 0xfb, 0x71, 0xc4,
+	0xbf, 0xa2, 0x5c, 0xb2, 0x8d, 0xa2, 0xff, 0xc8, 0x54, 0xed, 0x1a, 0x83, 0x69, 0x51, 0xad, 0x93,
+	0x83, 0x79, 0xe7, 0x28, 0x96, 0x10, 0xa8, 0x57, 0xd5, 0x7a, 0xf1, 0x14, 0xe2, 0x76, 0xc7, 0xe2,
+	0x00, 0xba, 0x67, 0xfe, 0xcc, 0x22, 0xd9, 0x3d, 0xe3, 0xe3, 0xaa, 0x95, 0xdb, 0x24, 0x5d, 0xfe,
+	0x7b, 0xb6, 0x89, 0xdb, 0x56, 0x39, 0x26, 0x3d, 0xcf, 0x91, 0xbd, 0xf8, 0xad, 0x03, 0x71, 0xfb,
+	0x37, 0xe2, 0xbf, 0x10, 0x67, 0x75, 0x93, 0x3a, 0xbd, 0x45, 0x4e, 0x35, 0x95, 0xc3, 0xac, 0x6e,
+	0x4e, 0xf5, 0x16, 0xe9, 0x50, 0xb2, 0xa2, 0xca, 0xde, 0x79, 0x67, 0x97, 0x9d, 0x23, 0x66, 0xd8,
+	0x7d, 0x0b, 0x06, 0x5b, 0xdc, 0x56, 0xe6, 0x82, 0x93, 0xf7, 0x65, 0x40, 0xc4, 0x57, 0x8d, 0xab,
+	0x1b, 0xaf, 0x87, 0xbe, 0x0c, 0x88, 0x78, 0x7f, 0x62, 0x2c, 0x88, 0xbe, 0x0c, 0x28, 0xf0, 0x68,
+	0x4c, 0x32, 0xd8, 0xf1, 0x68, 0x0c, 0xf1, 0x6e, 0x63, 0x50, 0xe5, 0xac, 0x87, 0xa9, 0x0c, 0x68,
+	0xf1, 0x7b, 0x17, 0x86, 0xa1, 0xca, 0x14, 0x53, 0x3b, 0xa3, 0x32, 0xbf, 0xf7, 0x58, 0x06, 0xc4,
+	0x39, 0xd1, 0x59, 0x9d, 0xf3, 0xb6, 0x63, 0x19, 0x10, 0x69, 0xdd, 0xa2, 0xcb, 0x9c, 0xf3, 0x9b,
+	0x8e, 0x65, 0x0b, 0xa9, 0x50, 0x4c, 0xf7, 0xb9, 0x9c, 0x6c, 0x53, 0x16, 0xe3, 0xc5, 0x10, 0xcd,
+	0x7b, 0xb4, 0x33, 0x8f, 0xc4, 0x3d, 0x98, 0x59, 0x74, 0x69, 0x59, 0xa5, 0x25, 0xbe, 0x4f, 0x6b,
+	0xa3, 0xcf, 0x2d, 0xef, 0x3d, 0x96, 0x53, 0x8b, 0xee, 0x75, 0xf5, 0x1a, 0xdf, 0x7f, 0x47, 0xa4,
+	0xf8, 0x9c, 0x2b, 0x58, 0xe2, 0x59, 0xa1, 0xd6, 0x96, 0x7f, 0xa3, 0x2f, 0xf7, 0x18, 0xf1, 0x7f,
+	0x18, 0xd5, 0x39, 0x2a, 0xb7, 0xb1, 0x7a, 0xcd, 0xaa, 0x9e, 0xca, 0x4b, 0x42, 0x3c, 0x06, 0xc8,
+	0x0c, 0xe6, 0x58, 0x3a, 0xad, 0x0a, 0xd6, 0xf4, 0xf8, 0xf8, 0xdf, 0x3b, 0x01, 0x9d, 0xec, 0x5c,
+	0x72, 0x2f, 0x8c, 0xfa, 0x73, 0x55, 0x9f, 0x25, 0xc0, 0xdd, 0x45, 0xa6, 0xb8, 0x0d, 0x74, 0xa2,
+	0xa9, 0x45, 0xd2, 0x74, 0xef, 0x28, 0x92, 0x83, 0xac, 0x6e, 0x96, 0xe8, 0x16, 0xcf, 0x01, 0x4e,
+	0xae, 0x2c, 0x6c, 0x74, 0x2b, 0x27, 0x32, 0x89, 0x59, 0x87, 0x0a, 0x46, 0x92, 0x4c, 0x6a, 0xde,
+	0x66, 0xab, 0xec, 0x3b, 0x2e, 0xde, 0x54, 0x7a, 0xb0, 0xf8, 0xb9, 0x03, 0x93, 0x7d, 0x39, 0x53,
+	0x58, 0x81, 0xe7, 0x58, 0x84, 0x64, 0x1e, 0x50, 0x35, 0x55, 0xe6, 0x74, 0x55, 0x86, 0x8c, 0x01,
+	0x11, 0xbf, 0xc1, 0xa2, 0x46, 0x13, 0x44, 0x1a, 0x10, 0xc7, 0x9b, 0x6c, 0x83, 0x36, 0xe9, 0xf3,
+	0xb0, 0x09, 0x48, 0x7c, 0x09, 0x87, 0x2b, 0x95, 0xa7, 0x84, 0xd2, 0x90, 0x30, 0xe2, 0x84, 0xd3,
+	0x95, 0xca, 0x9f, 0x98, 0x6c, 0xf3, 0x84, 0xc9, 0xc5, 0x4f, 0x7d, 0x18, 0x48, 0xb4, 0x4d, 0xe1,
+	0xfe, 0x81, 0xc8, 0xef, 0xc2, 0xd8, 0xcb, 0x3a, 0x6d, 0x2c, 0xe6, 0x41, 0xe9, 0xe0, 0xa9, 0xb7,
+	0x16, 0x73, 0x0a, 0xc0, 0x0f, 0xda, 0xa5, 0xd6, 0x29, 0xd7, 0x58, 0x96, 0xcf, 0x54, 0x02, 0x51,
+	0x4b, 0x66, 0xc4, 0xff, 0x60, 0xc4, 0x01, 0x19, 0xb5, 0xa1, 0xdf, 0x68, 0x4c, 0xc4, 0x49, 0x95,
+	0xb3, 0x4e, 0xf1, 0x43, 0x86, 0x98, 0xb3, 0x7e, 0x22, 0x19, 0x10, 0xe9, 0xf4, 0x1c, 0x4d, 0xae,
+	0x33, 0xc7, 0xaa, 0x89, 0x64, 0x0b, 0xf7, 0xba, 0x2b, 0xbe, 0xd2, 0x5d, 0xf7, 0x61, 0xe6, 0xad,
+	0xd4, 0x99, 0xa6, 0xcc, 0x94, 0xc3, 0x9c, 0x25, 0x13, 0xcb, 0x43, 0xcf, 0x9f, 0xb6, 0x34, 0x55,
+	0x83, 0x4a, 0x9c, 0x5a, 0x67, 0x58, 0x27, 0x23, 0x39, 0x24, 0xbc, 0xf4, 0xfd, 0x64, 0xf5, 0xba,
+	0x54, 0x05, 0x8f, 0xbf, 0x48, 0x06, 0x44, 0x52, 0xc8, 0xea, 0x86, 0x87, 0x5d, 0x24, 0xc9, 0xe4,
+	0x4e, 0xda, 0x1b, 0x6d, 0xd1, 0xe5, 0x0c, 0x9b, 0x41, 0xaf, 0x9d, 0x5d, 0x13, 0x49, 0xa6, 0x38,
+	0x86, 0x98, 0xaa, 0x5b, 0xe8, 0x12, 0x93, 0x43, 0x9e, 0xbf, 0xb7, 0x76, 0x32, 0x3e, 0x0d, 0x8e,
+	0x67, 0xe7, 0x58, 0x3a, 0xb9, 0x8b, 0x13, 0xf7, 0x60, 0xb0, 0xd2, 0xa5, 0x32, 0x17, 0xc9, 0x8c,
+	0x85, 0x7f, 0xb8, 0x5b, 0xf1, 0x94, 0x69, 0x19, 0xdc, 0xe2, 0x0b, 0x98, 0x84, 0x2f, 0xb3, 0x24,
+	0x92, 0x1b, 0xfc, 0x47, 0xe3, 0xc0, 0x91, 0x1e, 0x16, 0x29, 0x4c, 0xaf, 0x7c, 0x86, 0x26, 0xa7,
+	0x72, 0xac, 0x84, 0x9e, 0xec, 0x2a, 0x16, 0x2c, 0x92, 0x23, 0x8c, 0x4e, 0x0f, 0xfc, 0x05, 0xa6,
+	0x8b, 0x3c, 0x8c, 0x0a, 0x0f, 0x68, 0x50, 0xe8, 0xf2, 0xac, 0x0a, 0x97, 0x1d, 0xdb, 0x8b, 0x3f,
+	0x3a, 0x30, 0xf0, 0xdb, 0xda, 0x0d, 0xe1, 0xce, 0xde, 0x10, 0xa6, 0xaa, 0x66, 0x46, 0xd7, 0x6d,
+	0xfe, 0x80, 0xfc, 0xfd, 0x9a, 0x6d, 0xda, 0xe1, 0x4c, 0x36, 0x71, 0x2b, 0xed, 0x6c, 0x3b, 0x87,
+	0xc8, 0xe6, 0xf5, 0x4e, 0x39, 0x9d, 0x25, 0x51, 0x98, 0x66, 0x8c, 0xc4, 0x1c, 0xc6, 0xba, 0x74,
+	0x68, 0x6a, 0x83, 0x0e, 0xfd, 0xf8, 0x1c, 0xc9, 0x7d, 0x8a, 0x56, 0x96, 0x88, 0x39, 0xd2, 0x0c,
+	0xe5, 0x1e, 0xf2, 0x48, 0xdc, 0x81, 0xf8, 0xbd, 0x32, 0xa5, 0x2e, 0xd7, 0x36, 0x89, 0xd9, 0xb3,
+	0xc3, 0x8b, 0xdb, 0x10, 0xc9, 0xa6, 0x7c, 0x91, 0x53, 0x95, 0x74, 0xfb, 0x26, 0xe8, 0xea, 0x7c,
+	0xf1, 0x4b, 0x07, 0x46, 0xb2, 0x29, 0x83, 0xae, 0xaf, 0x79, 0xfd, 0xc5, 0xae, 0x1c, 0xb6, 0x35,
+	0x64, 0x40, 0x2c, 0x1a, 0x53, 0xb5, 0xbd, 0xed, 0x01, 0x1d, 0xae, 0xe1, 0xce, 0x4c, 0xfa, 0xd7,
+	0x0e, 0xd7, 0x37, 0xac, 0x0c, 0xee, 0x6b, 0x77, 0xc6, 0xe4, 0x6f, 0xee, 0x8c, 0x49, 0x7b, 0x67,
+	0x2c, 0x5e, 0xc2, 0xf4, 0x0d, 0xab, 0xbd, 0x7d, 0xdc, 0x5c, 0xdf, 0xe5, 0x7d, 0x18, 0x5a, 0x67,
+	0x50, 0x6d, 0xfd, 0xab, 0xe6, 0x60, 0xef, 0xd3, 0x4b, 0xe6, 0x65, 0xeb, 0x5f, 0xbc, 0x84, 0xb1,
+	0xcf, 0x75, 0xb2, 0x69, 0xca, 0x77, 0xb4, 0x67, 0xef, 0xe1, 0x6c, 0x1f, 0x59, 0x18, 0xdc, 0x74,
+	0x82, 0xb9, 0x72, 0x8a, 0xeb, 0x30, 0x91, 0x6c, 0x3f, 0x98, 0xc3, 0xc0, 0x47, 0x09, 0x80, 0xc1,
+	0xf2, 0xf4, 0xdb, 0x37, 0x6f, 0x4f, 0x67, 0xff, 0x0a, 0xf6, 0x33, 0x29, 0x67, 0x9d, 0xe3, 0x3f,
+	0x3b, 0x30, 0x5c, 0xfa, 0x84, 0xe2, 0x21, 0xf4, 0x64, 0x53, 0x8a, 0xcb, 0x59, 0x7f, 0xf9, 0x5a,
+	0xbb, 0x23, 0xf6, 0xc9, 0x70, 0x14, 0x8f, 0x20, 0x5a, 0x3a, 0x65, 0xdc, 0xa7, 0xaf, 0x78, 0x00,
+	0xfd, 0xef, 0x95, 0x76, 0xe2, 0x60, 0xdf, 0xf7, 0x22, 0xff, 0x68, 0xec, 0x57, 0x30, 0x38, 0x51,
+	0x65, 0x86, 0xc5, 0x27, 0x45, 0x7f, 0x03, 0x83, 0x37, 0xe1, 0xbe, 0xdf, 0x79, 0xaf, 0x1c, 0xc9,
+	0x9d, 0x9b, 0xd7, 0x78, 0x2e, 0xef, 0xa3, 0xce, 0xd3, 0xf1, 0x0f, 0xa3, 0xe0, 0xa8, 0x57, 0xab,
+	0x01, 0xbf, 0x59, 0x1f, 0xff, 0x35, 0x00, 0x18, 0x5a, 0xea, 0x69, 0xc4, 0x0a, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// SandboxClient is the client API for Sandbox service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SandboxClient interface {
+	// Run starts a run and waits it finished.
+	Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunStatus, error)
+	// Start queues a run and returns immediately.
+	Start(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunStatus, error)
+	// Wait waits a run finished.
+	Wait(ctx context.Context, in *RunId, opts ...grpc.CallOption) (*RunStatus, error)
+	// Cancel cancels a queued or running run.
+	Cancel(ctx context.Context, in *RunId, opts ...grpc.CallOption) (*RunStatus, error)
+	// Output streams stdout and stderr of a run from the beginning until it finished.
+	Output(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (Sandbox_OutputClient, error)
+}
+
+type sandboxClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSandboxClient(cc *grpc.ClientConn) SandboxClient {
+	return &sandboxClient{cc}
+}
+
+func (c *sandboxClient) Run(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/sandbox.Sandbox/Run", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxClient) Start(ctx context.Context, in *RunRequest, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/sandbox.Sandbox/Start", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxClient) Wait(ctx context.Context, in *RunId, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/sandbox.Sandbox/Wait", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxClient) Cancel(ctx context.Context, in *RunId, opts ...grpc.CallOption) (*RunStatus, error) {
+	out := new(RunStatus)
+	err := c.cc.Invoke(ctx, "/sandbox.Sandbox/Cancel", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sandboxClient) Output(ctx context.Context, in *OutputRequest, opts ...grpc.CallOption) (Sandbox_OutputClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Sandbox_serviceDesc.Streams[0], "/sandbox.Sandbox/Output", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &sandboxOutputClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Sandbox_OutputClient interface {
+	Recv() (*OutputChunk, error)
+	grpc.ClientStream
+}
+
+type sandboxOutputClient struct {
+	grpc.ClientStream
+}
+
+func (x *sandboxOutputClient) Recv() (*OutputChunk, error) {
+	m := new(OutputChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SandboxServer is the server API for Sandbox service.
+type SandboxServer interface {
+	// Run starts a run and waits it finished.
+	Run(context.Context, *RunRequest) (*RunStatus, error)
+	// Start queues a run and returns immediately.
+	Start(context.Context, *RunRequest) (*RunStatus, error)
+	// Wait waits a run finished.
+	Wait(context.Context, *RunId) (*RunStatus, error)
+	// Cancel cancels a queued or running run.
+	Cancel(context.Context, *RunId) (*RunStatus, error)
+	// Output streams stdout and stderr of a run from the beginning until it finished.
+	Output(*OutputRequest, Sandbox_OutputServer) error
+}
+
+// UnimplementedSandboxServer can be embedded to have forward compatible implementations.
+type UnimplementedSandboxServer struct {
+}
+
+func (*UnimplementedSandboxServer) Run(ctx context.Context, req *RunRequest) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
+}
+func (*UnimplementedSandboxServer) Start(ctx context.Context, req *RunRequest) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Start not implemented")
+}
+func (*UnimplementedSandboxServer) Wait(ctx context.Context, req *RunId) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wait not implemented")
+}
+func (*UnimplementedSandboxServer) Cancel(ctx context.Context, req *RunId) (*RunStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (*UnimplementedSandboxServer) Output(req *OutputRequest, srv Sandbox_OutputServer) error {
+	return status.Errorf(codes.Unimplemented, "method Output not implemented")
+}
+
+func RegisterSandboxServer(s *grpc.Server, srv SandboxServer) {
+	s.RegisterService(&_Sandbox_serviceDesc, srv)
+}
+
+func _Sandbox_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServer).Run(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sandbox.Sandbox/Run",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServer).Run(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sandbox_Start_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServer).Start(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sandbox.Sandbox/Start",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServer).Start(ctx, req.(*RunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sandbox_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sandbox.Sandbox/Wait",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServer).Wait(ctx, req.(*RunId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sandbox_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RunId)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SandboxServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sandbox.Sandbox/Cancel",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SandboxServer).Cancel(ctx, req.(*RunId))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sandbox_Output_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(OutputRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(SandboxServer).Output(m, &sandboxOutputServer{stream})
+}
+
+type Sandbox_OutputServer interface {
+	Send(*OutputChunk) error
+	grpc.ServerStream
+}
+
+type sandboxOutputServer struct {
+	grpc.ServerStream
+}
+
+func (x *sandboxOutputServer) Send(m *OutputChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _Sandbox_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "sandbox.Sandbox",
+	HandlerType: (*SandboxServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Run",
+			Handler:    _Sandbox_Run_Handler,
+		},
+		{
+			MethodName: "Start",
+			Handler:    _Sandbox_Start_Handler,
+		},
+		{
+			MethodName: "Wait",
+			Handler:    _Sandbox_Wait_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _Sandbox_Cancel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Output",
+			Handler:       _Sandbox_Output_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "sandbox.proto",
+}
@@ -0,0 +1,163 @@
+// +build linux
+
+// Package rpc implements the gRPC Sandbox service defined in sandboxpb,
+// runs are executed by server.Server.
+package rpc
+
+import (
+	"context"
+	"github.com/sdibtacm/sandbox/rpc/sandboxpb"
+	"github.com/sdibtacm/sandbox/server"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"sync"
+)
+
+type Server struct {
+	s *server.Server
+}
+
+// NewServer returns a gRPC server of the Sandbox service, runs are executed by s.
+// If s.Token is set, calls must have the metadata `authorization: Bearer <token>`.
+func NewServer(s *server.Server) *grpc.Server {
+	g := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := authorize(ctx, s); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := authorize(ss.Context(), s); err != nil {
+				return err
+			}
+			return handler(srv, ss)
+		}),
+	)
+	sandboxpb.RegisterSandboxServer(g, &Server{s: s})
+	return g
+}
+
+func authorize(ctx context.Context, s *server.Server) error {
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok && len(md.Get("authorization")) > 0 {
+		auth = md.Get("authorization")[0]
+	}
+	if !s.Authorized(auth) {
+		return status.Error(codes.Unauthenticated, server.ErrUnauthorized.Error())
+	}
+	return nil
+}
+
+// Serve serves the Sandbox service on l until l is closed.
+func Serve(l net.Listener, s *server.Server) error {
+	return NewServer(s).Serve(l)
+}
+
+func toError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if _, ok := err.(*server.ForbiddenError); ok {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	switch err {
+	case server.ErrNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case server.ErrClosed:
+		return status.Error(codes.Unavailable, err.Error())
+	case server.ErrNoCommand, server.ErrBadStream:
+		return status.Error(codes.InvalidArgument, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func (s *Server) submit(req *sandboxpb.RunRequest, wait bool) (*sandboxpb.RunStatus, error) {
+	if req.GetCommand() == "" {
+		return nil, toError(server.ErrNoCommand)
+	}
+	runReq := FromRunRequest(req)
+	runReq.Wait = wait
+	st, err := s.s.Submit(runReq)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return ToRunStatus(st), nil
+}
+
+func (s *Server) Run(ctx context.Context, req *sandboxpb.RunRequest) (*sandboxpb.RunStatus, error) {
+	st, err := s.submit(req, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.wait(ctx, st.Id, true)
+}
+
+func (s *Server) Start(ctx context.Context, req *sandboxpb.RunRequest) (*sandboxpb.RunStatus, error) {
+	return s.submit(req, false)
+}
+
+// wait waits the run, the run will be cancelled if ctx done and cancel is true.
+func (s *Server) wait(ctx context.Context, id string, cancel bool) (*sandboxpb.RunStatus, error) {
+	st, err := s.s.Wait(ctx, id)
+	if err != nil && cancel && ctx.Err() != nil {
+		_, _ = s.s.Cancel(id)
+	}
+	if err != nil {
+		return nil, toError(err)
+	}
+	return ToRunStatus(st), nil
+}
+
+func (s *Server) Wait(ctx context.Context, id *sandboxpb.RunId) (*sandboxpb.RunStatus, error) {
+	return s.wait(ctx, id.GetId(), false)
+}
+
+func (s *Server) Cancel(ctx context.Context, id *sandboxpb.RunId) (*sandboxpb.RunStatus, error) {
+	st, err := s.s.Cancel(id.GetId())
+	if err != nil {
+		return nil, toError(err)
+	}
+	return ToRunStatus(st), nil
+}
+
+func (s *Server) Output(req *sandboxpb.OutputRequest, stream sandboxpb.Sandbox_OutputServer) error {
+	streams := req.GetStreams()
+	if len(streams) == 0 {
+		streams = []sandboxpb.Stream{sandboxpb.Stream_STDOUT, sandboxpb.Stream_STDERR}
+	}
+	if _, err := s.s.Get(req.GetId()); err != nil {
+		return toError(err)
+	}
+
+	// Send is not safe to be called in different goroutines
+	var mu sync.Mutex
+	errs := make(chan error, len(streams))
+	for _, st := range streams {
+		go func(st sandboxpb.Stream) {
+			errs <- s.s.Follow(stream.Context(), req.GetId(), strings.ToLower(st.String()), func(data []byte) error {
+				mu.Lock()
+				defer mu.Unlock()
+				return stream.Send(&sandboxpb.OutputChunk{Stream: st, Data: data})
+			})
+		}(st)
+	}
+	var err error
+	for range streams {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return toError(err)
+	}
+	return nil
+}
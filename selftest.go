@@ -0,0 +1,156 @@
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/exec/scmpFilter"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+var ErrSelfTestFailed = errors.New("self test failed")
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Check that the sandbox works on this machine",
+	Long: `Check that the sandbox works on this machine.
+
+It runs some programs by /bin/sh and checks that limits are enforced.`,
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		Init()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return selftest()
+	},
+}
+
+func init() {
+	flags := selftestCmd.Flags()
+	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
+	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
+	cmd.AddCommand(selftestCmd)
+}
+
+type selfTest struct {
+	name string
+	// run returns message of the test, err if failed
+	run func() (string, error)
+}
+
+var selfTests = []selfTest{
+	{"run", func() (string, error) {
+		return expectExit(shell("exit 0"), 0)
+	}},
+	{"exit code", func() (string, error) {
+		return expectExit(shell("exit 3"), 3)
+	}},
+	{"cpu time limit", func() (string, error) {
+		c := shell("while :; do :; done")
+		c.ResourceLimit.CpuTime, c.ResourceLimit.ClockTime = 200, 5000
+		return expectExceed(c, exec.EXCEED_CPU_TIME)
+	}},
+	{"clock time limit", func() (string, error) {
+		c := shell("sleep 10")
+		c.ResourceLimit.ClockTime = 200
+		return expectExceed(c, exec.EXCEED_CLOCK_TIME)
+	}},
+	{"memory limit", func() (string, error) {
+		c := shell("x=$(head -c 268435456 /dev/zero | tr '\\0' a)")
+		c.ResourceLimit.Memory, c.ResourceLimit.ClockTime = 32<<20, 10000
+		return expectExceed(c, exec.EXCEED_MEMORY)
+	}},
+	{"output limit", func() (string, error) {
+		c := shell("while :; do echo 0123456789; done")
+		c.ResourceLimit.Output, c.ResourceLimit.ClockTime = 4096, 5000
+		return expectExceed(c, exec.EXCEED_OUTPUT)
+	}},
+	{"seccomp", func() (string, error) {
+		// processes are denied by the basic level
+		c := shell("/bin/true")
+		c.ResourceLimit.ClockTime = 5000
+		c.Syscall = &exec.SyscallLimit{Level: scmpFilter.LEVEL_BASIC, Action: int(scmpFilter.DEFAULT_TRACE)}
+		r, err := runSelfTest(c)
+		if err != nil {
+			return "", err
+		}
+		if r.Verdict != exec.VERDICT_BAD_SYSCALL {
+			return "", fmt.Errorf("%s, want %s", exec.VERDICT_STR[r.Verdict], exec.VERDICT_STR[exec.VERDICT_BAD_SYSCALL])
+		}
+		name, _, err := lookupSyscall(strconv.Itoa(r.Syscall))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s, syscall %s", exec.VERDICT_STR[r.Verdict], name), nil
+	}},
+	{"privilege", func() (string, error) {
+		if os.Getuid() != 0 {
+			return "not root, uid, gid and chroot can not be used", nil
+		}
+		return "root", nil
+	}},
+}
+
+func shell(script string) *exec.Cmd {
+	c := exec.Command("/bin/sh", "-c", script)
+	c.Stdout, c.Stderr = ioutil.Discard, ioutil.Discard
+	return c
+}
+
+func runSelfTest(c *exec.Cmd) (*exec.Result, error) {
+	if err := c.Run(); err != nil {
+		return nil, err
+	}
+	return c.Result(), nil
+}
+
+func expectExit(c *exec.Cmd, code int) (string, error) {
+	r, err := runSelfTest(c)
+	if err != nil {
+		return "", err
+	}
+	if r.ExitCode != code || r.Exceed != exec.EXCEED_NONE {
+		return "", fmt.Errorf("%s, exit code %d, want %d", exec.VERDICT_STR[r.Verdict], r.ExitCode, code)
+	}
+	return fmt.Sprintf("exit code %d, clock %dms", r.ExitCode, r.ClockTime), nil
+}
+
+func expectExceed(c *exec.Cmd, exceed int) (string, error) {
+	r, err := runSelfTest(c)
+	if err != nil {
+		return "", err
+	}
+	if r.Exceed != exceed {
+		return "", fmt.Errorf("%s, exceed %s, want %s", exec.VERDICT_STR[r.Verdict],
+			exec.EXCEED_STR[r.Exceed], exec.EXCEED_STR[exceed])
+	}
+	return fmt.Sprintf("%s, cpu %dms, clock %dms, memory %d", exec.VERDICT_STR[r.Verdict],
+		r.CpuTime, r.ClockTime, r.MemoryUsed), nil
+}
+
+func selftest() error {
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "CHECK\tRESULT\tMESSAGE")
+	for _, t := range selfTests {
+		msg, err := t.run()
+		result := "ok"
+		if err != nil {
+			result, msg, failed = "fail", err.Error(), true
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", t.name, result, msg)
+	}
+	_ = w.Flush()
+	if failed {
+		return ErrSelfTestFailed
+	}
+	return nil
+}
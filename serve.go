@@ -0,0 +1,198 @@
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/g"
+	"github.com/sdibtacm/sandbox/metrics"
+	"github.com/sdibtacm/sandbox/rpc"
+	"github.com/sdibtacm/sandbox/server"
+	"github.com/spf13/cobra"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	cmdServeListen  string
+	cmdServeGrpc    string
+	cmdServeWorkers int
+	cmdServeCPUs    string
+	cmdServeMetrics string
+
+	cmdServeTokenFile   string
+	cmdServeDirs        []string
+	cmdServeUnsafe      bool
+	cmdServeRunUid      int
+	cmdServeRunGid      int
+	cmdServeRetention   time.Duration
+	cmdServeStreamLimit int
+)
+
+var ErrTcpNoToken = errors.New("listen on tcp needs --token-file")
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run as a daemon, serve HTTP/JSON API to run programs in sandbox",
+	Long: `Run as a daemon, serve HTTP/JSON API to run programs in sandbox.
+
+  POST   /runs              submit a run
+  GET    /runs/{id}         get status and result of a run
+  POST   /runs/{id}/cancel  cancel a run
+  DELETE /runs/{id}         cancel a run and forget it
+  GET    /runs/{id}/log     stream stdout, or stderr with ?stream=stderr
+  GET    /status            status of the server
+
+With --grpc, the gRPC Sandbox service (see rpc/sandboxpb/sandbox.proto) is served too.
+With --metrics, metrics are served in Prometheus text format at /metrics.
+
+Requests can only use host files and commands in --allow-dir, must set a chroot in
+--allow-dir, and can not set bpf, clone flags, uid or gid without --unsafe. Runs
+execute as --run-uid and --run-gid unless requests set them, never as root.
+Listening on tcp needs --token-file, clients send the token by the header
+"Authorization: Bearer <token>".`,
+	Example:       "sandbox serve --listen unix:///run/sandbox.sock --workers 4",
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		Init()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return serve()
+	},
+}
+
+func init() {
+	flags := serveCmd.Flags()
+	flags.StringVar(&cmdServeListen, "listen", "unix:///run/sandbox.sock", "Listen `address`, like unix:///run/sandbox.sock or tcp://127.0.0.1:8080")
+	flags.StringVar(&cmdServeGrpc, "grpc", "", "Also serve gRPC on `address`, like unix:///run/sandbox-grpc.sock")
+	flags.StringVar(&cmdServeMetrics, "metrics", "", "Serve Prometheus metrics at /metrics on `address`, like tcp://0.0.0.0:9100")
+	flags.IntVar(&cmdServeWorkers, "workers", runtime.NumCPU(), "Max runs at the same time, others will be queued")
+	flags.StringVar(&cmdServeCPUs, "cpus", "", "Pin every run to a dedicated cpu of `cpus`, like 0-3,6 or all, at most one run per cpu, workers will not be used")
+	flags.StringVar(&cmdServeTokenFile, "token-file", "", "Require the token in `file` from clients")
+	flags.StringArrayVar(&cmdServeDirs, "allow-dir", nil, "Allow requests to use files in host `dir`, like stdin_file, files, chroot and command. Can repeat")
+	flags.BoolVar(&cmdServeUnsafe, "unsafe", false, "Allow requests to set bpf, clone flags, uid and gid, and to run without chroot, which can break the sandbox")
+	flags.IntVar(&cmdServeRunUid, "run-uid", server.DEFAULT_RUN_ID, "Run as `uid` unless requests set it, it can not be 0")
+	flags.IntVar(&cmdServeRunGid, "run-gid", server.DEFAULT_RUN_ID, "Run as `gid` unless requests set it, it can not be 0")
+	flags.DurationVar(&cmdServeRetention, "retention", server.DEFAULT_RETENTION, "Keep a finished run for `duration`, then it is removed")
+	flags.IntVar(&cmdServeStreamLimit, "stream-limit", server.DEFAULT_STREAM_LIMIT, "Max `bytes` of stdout and stderr kept for each run")
+	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
+	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
+	flags.BoolVar(&cmdLogVerbose, "verbose", false, "Record log verbose")
+
+	cmd.AddCommand(serveCmd)
+}
+
+func serve() error {
+	s, err := newServer()
+	if err != nil {
+		return err
+	}
+	if s.Token == "" && (isTcp(cmdServeListen) || isTcp(cmdServeGrpc)) {
+		return ErrTcpNoToken
+	}
+	l, err := server.Listen(cmdServeListen)
+	if err != nil {
+		return err
+	}
+
+	var gl net.Listener
+	if cmdServeGrpc != "" {
+		if gl, err = server.Listen(cmdServeGrpc); err != nil {
+			_ = l.Close()
+			return err
+		}
+		gs := rpc.NewServer(s)
+		go func() {
+			g.GetLog().Info("serve gRPC on {}", cmdServeGrpc)
+			if err := gs.Serve(gl); err != nil {
+				g.GetLog().Warning("gRPC serve stopped with error: {}", err)
+			}
+		}()
+		defer gs.Stop()
+	}
+
+	if cmdServeMetrics != "" {
+		ml, err := server.Listen(cmdServeMetrics)
+		if err != nil {
+			_ = l.Close()
+			return err
+		}
+		defer ml.Close()
+		reg := metrics.New()
+		reg.QueueFunc = func() int { return s.Status().Queued }
+		exec.AddListener(reg)
+		defer exec.RemoveListener(reg)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", reg)
+		go func() {
+			g.GetLog().Info("serve metrics on {}", cmdServeMetrics)
+			_ = http.Serve(ml, mux)
+		}()
+	}
+
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigchan
+		g.GetLog().Info("receive signal {}, will stop serve", sig)
+		_ = l.Close()
+	}()
+
+	g.GetLog().Info("serve on {} with {} workers", cmdServeListen, s.Status().Workers)
+	err = s.Serve(l)
+	s.Close()
+	signal.Stop(sigchan)
+	if _, ok := err.(*net.OpError); ok {
+		// listener is closed by signal
+		return nil
+	}
+	return err
+}
+
+// isTcp reports whether addr of server.Listen is a tcp address.
+func isTcp(addr string) bool {
+	return addr != "" && !strings.HasPrefix(addr, "unix://")
+}
+
+func newServer() (s *server.Server, err error) {
+	if cmdServeCPUs == "" {
+		s = server.New(cmdServeWorkers)
+	} else {
+		var cpus []int
+		if cmdServeCPUs != "all" {
+			if cpus, err = exec.ParseCPUList(cmdServeCPUs); err != nil {
+				return nil, err
+			}
+		}
+		sched, err := exec.NewScheduler(cpus)
+		if err != nil {
+			return nil, err
+		}
+		g.GetLog().Info("runs will be pinned to cpus {}", sched.CPUs())
+		s = server.NewWithScheduler(sched)
+	}
+
+	if cmdServeTokenFile != "" {
+		b, err := ioutil.ReadFile(cmdServeTokenFile)
+		if err != nil {
+			return nil, &FileError{Name: cmdServeTokenFile, Err: err}
+		}
+		if s.Token = strings.TrimSpace(string(b)); s.Token == "" {
+			return nil, &FileError{Name: cmdServeTokenFile, Err: errors.New("empty token")}
+		}
+	}
+	s.Policy = server.Policy{Unsafe: cmdServeUnsafe, Dirs: cmdServeDirs, Uid: cmdServeRunUid, Gid: cmdServeRunGid}
+	s.Retention = cmdServeRetention
+	s.StreamLimit = cmdServeStreamLimit
+	return s, nil
+}
@@ -0,0 +1,132 @@
+// +build linux
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DEFAULT_RUN_ID is the uid and gid of nobody, runs execute as it by default.
+const DEFAULT_RUN_ID = 65534
+
+// Policy restricts what a request can ask for, the zero value is the most strict,
+// requests can not use host paths or fields which can break the sandbox, and
+// no run is allowed until Uid and Gid are set.
+type Policy struct {
+	// Unsafe allows requests to set Sys.Bpf, Sys.Cloneflags, Uid and Gid, and to
+	// run without Chroot.
+	Unsafe bool
+	// Dirs are the host dirs which files of requests (stdin_file, stdout_file,
+	// stderr_file, files, chroot, command and chdir) must be in, symlinks are
+	// resolved. Command and chdir are in chroot if it is set.
+	Dirs []string
+	// Uid and Gid are the user and group runs execute as, unless requests set
+	// them. A run never executes as root.
+	Uid int
+	Gid int
+}
+
+// ForbiddenError is returned when a field of a request is not allowed by Policy.
+type ForbiddenError struct {
+	Field string
+	Value string
+}
+
+func (e *ForbiddenError) Error() string {
+	if e.Value == "" {
+		return e.Field + " is forbidden by server policy"
+	}
+	return e.Field + " " + e.Value + " is forbidden by server policy"
+}
+
+// Check returns a *ForbiddenError if req is not allowed.
+func (p *Policy) Check(req *RunRequest) error {
+	if !p.Unsafe {
+		switch {
+		case len(req.Sys.Bpf) > 0:
+			return &ForbiddenError{Field: "sys.bpf"}
+		case req.Sys.Cloneflags != 0:
+			return &ForbiddenError{Field: "sys.cloneflags"}
+		case req.Uid != 0:
+			return &ForbiddenError{Field: "uid"}
+		case req.Gid != 0:
+			return &ForbiddenError{Field: "gid"}
+		case req.Chroot == "":
+			return &ForbiddenError{Field: "empty chroot"}
+		}
+	}
+	// a negative id is -1 for setresuid, which keeps the id of sandbox
+	if uid, gid := p.ids(req); uid <= 0 {
+		return &ForbiddenError{Field: "uid", Value: strconv.Itoa(uid)}
+	} else if gid <= 0 {
+		return &ForbiddenError{Field: "gid", Value: strconv.Itoa(gid)}
+	}
+
+	paths := []struct{ field, path string }{
+		{"chroot", req.Chroot},
+		{"stdin_file", req.StdinFile},
+		{"stdout_file", req.StdoutFile},
+		{"stderr_file", req.StderrFile},
+	}
+	for _, f := range req.Files {
+		paths = append(paths, struct{ field, path string }{"files", f.Path})
+	}
+	for _, f := range paths {
+		if f.path != "" && !p.allowPath(f.path) {
+			return &ForbiddenError{Field: f.field, Value: f.path}
+		}
+	}
+	// they are resolved in chroot by the process and must be absolute, a relative
+	// command is searched in PATH, and a relative chdir is out of chroot.
+	for _, f := range []struct{ field, path string }{
+		{"command", req.Command},
+		{"chdir", req.Chdir},
+	} {
+		if f.path != "" && (!filepath.IsAbs(f.path) || !p.allowPath(filepath.Join(req.Chroot, f.path))) {
+			return &ForbiddenError{Field: f.field, Value: f.path}
+		}
+	}
+	return nil
+}
+
+// ids returns the user and group req runs as.
+func (p *Policy) ids(req *RunRequest) (uid, gid int) {
+	uid, gid = req.Uid, req.Gid
+	if uid == 0 {
+		uid = p.Uid
+	}
+	if gid == 0 {
+		gid = p.Gid
+	}
+	return
+}
+
+// allowPath reports whether path is in one of Dirs, the path may not exist,
+// like an output file, then symlinks of its dir are resolved.
+func (p *Policy) allowPath(path string) bool {
+	if !filepath.IsAbs(path) {
+		return false
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if os.IsNotExist(err) {
+		var dir string
+		if dir, err = filepath.EvalSymlinks(filepath.Dir(path)); err == nil {
+			real = filepath.Join(dir, filepath.Base(path))
+		}
+	}
+	if err != nil {
+		return false
+	}
+	for _, d := range p.Dirs {
+		if d, err = filepath.EvalSymlinks(d); err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(d, real); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
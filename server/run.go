@@ -0,0 +1,442 @@
+// +build linux
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/units/helper"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	STATE_QUEUED    = "queued"
+	STATE_RUNNING   = "running"
+	STATE_FINISHED  = "finished"
+	STATE_CANCELLED = "cancelled" // cancelled before running
+	STATE_ERROR     = "error"     // fail to start
+)
+
+var (
+	ErrNoCommand   = errors.New("command is required")
+	ErrBadFileMode = errors.New("file mode must be r, w or rw")
+	ErrBadFd       = errors.New("extra file fd must >= 3")
+	ErrBadRlimit   = errors.New("too many rlimit")
+	ErrBadBpf      = errors.New("bpf length must be a multiple of 8")
+)
+
+// RunRequest describes a run, paths are paths on the host of the server.
+type RunRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Env     []string `json:"env,omitempty"`
+	Chroot  string   `json:"chroot,omitempty"`
+	Chdir   string   `json:"chdir,omitempty"`
+
+	// Stdin is the content of stdin, it is used when StdinFile is empty.
+	Stdin      string     `json:"stdin,omitempty"`
+	StdinFile  string     `json:"stdin_file,omitempty"`
+	StdoutFile string     `json:"stdout_file,omitempty"`
+	StderrFile string     `json:"stderr_file,omitempty"`
+	Files      []FileSpec `json:"files,omitempty"`
+
+	Limit   Limit   `json:"limit"`
+	Sys     Sys     `json:"sys"`
+	Seccomp Seccomp `json:"seccomp"`
+	Uid     int     `json:"uid,omitempty"`
+	Gid     int     `json:"gid,omitempty"`
+
+	// CaptureLog captures log messages of the run into RunResult.Log.
+	CaptureLog bool `json:"capture_log,omitempty"`
+
+	// Wait makes the submit request return after the run finished.
+	Wait bool `json:"wait,omitempty"`
+}
+
+// FileSpec is an extra file passed to the process as Fd.
+type FileSpec struct {
+	Fd   int    `json:"fd"`
+	Path string `json:"path"`
+	Mode string `json:"mode"` // r, w or rw
+}
+
+// Limit is exec.Resource
+type Limit struct {
+	CpuTime   uint   `json:"cpu_time,omitempty"`   // ms
+	ClockTime uint   `json:"clock_time,omitempty"` // ms
+	Memory    uint64 `json:"memory,omitempty"`     // byte
+	Output    uint64 `json:"output,omitempty"`     // byte, stdout + stderr
+	Stdout    uint64 `json:"stdout,omitempty"`     // byte
+	Stderr    uint64 `json:"stderr,omitempty"`     // byte
+	Thread    uint   `json:"thread,omitempty"`
+}
+
+// Sys is exec.SysAttr, files are set by FileSpec, and Bpf is raw sock_filter array.
+type Sys struct {
+	Ptrace     bool     `json:"ptrace,omitempty"`
+	Setsid     bool     `json:"setsid,omitempty"`
+	Rlimit     []uint64 `json:"rlimit,omitempty"` // index is RLIMIT_*
+	Cloneflags uint64   `json:"cloneflags,omitempty"`
+	Setctty    bool     `json:"setctty,omitempty"`
+	Ctty       int      `json:"ctty,omitempty"`
+	Pdeathsig  uint     `json:"pdeathsig,omitempty"`
+	CPUSet     []int    `json:"cpu_set,omitempty"` // not used if the server has a scheduler
+	Umask      uint     `json:"umask,omitempty"`
+	Bpf        []byte   `json:"bpf,omitempty"`
+}
+
+// Seccomp is exec.SyscallLimit
+type Seccomp struct {
+	Level      int    `json:"level,omitempty"`
+	Action     int    `json:"action,omitempty"`
+	Helper     string `json:"helper,omitempty"`
+	NoNewPrivs bool   `json:"no_new_privs,omitempty"`
+	// Arches are added to the filter besides the native arch, like x86 and x32
+	Arches        []string `json:"arches,omitempty"`
+	BadArchAction int      `json:"bad_arch_action,omitempty"` // 0: kill, 1: trace, 2: EPERM, 3: ENOSYS
+}
+
+// RunResult is the result of a finished run.
+type RunResult struct {
+	CpuTime         uint            `json:"cpu_time"`    // ms
+	ClockTime       uint            `json:"clock_time"`  // ms
+	Memory          uint64          `json:"memory"`      // byte
+	ExitStatus      uint32          `json:"exit_status"` // raw wait status
+	ExitCode        int             `json:"exit_code"`
+	Signal          int             `json:"signal,omitempty"`
+	Exceed          string          `json:"exceed"`
+	Verdict         string          `json:"verdict"`
+	Output          uint64          `json:"output"`
+	OutputTruncated bool            `json:"output_truncated,omitempty"`
+	HelpStr         string          `json:"help_str,omitempty"`
+	CPU             int             `json:"cpu"`     // -1 if not pinned to one cpu
+	Syscall         int             `json:"syscall"` // the bad syscall, -1 if none
+	SyscallArch     string          `json:"syscall_arch,omitempty"`
+	Log             string          `json:"log,omitempty"` // JSON lines of log messages if CaptureLog
+	Timeline        []TimelineEvent `json:"timeline,omitempty"`
+	Binary          *Binary         `json:"binary,omitempty"` // nil if not an ELF
+	Stdout          string          `json:"stdout,omitempty"`
+	Stderr          string          `json:"stderr,omitempty"`
+	StreamTruncated bool            `json:"stream_truncated,omitempty"` // Stdout or Stderr is cut by the stream limit of server
+
+	raw *exec.Result
+}
+
+// Raw returns the exec.Result of the run.
+func (rr *RunResult) Raw() *exec.Result {
+	return rr.raw
+}
+
+// TimelineEvent is exec.TimelineEvent.
+type TimelineEvent struct {
+	At    int64  `json:"at"` // us since the run started
+	Event string `json:"event"`
+	Child bool   `json:"child,omitempty"`
+	Info  string `json:"info,omitempty"`
+}
+
+// Binary is exec.Binary.
+type Binary struct {
+	Path        string   `json:"path"`
+	Script      string   `json:"script,omitempty"`
+	Arch        string   `json:"arch"`
+	Bits        int      `json:"bits"`
+	Static      bool     `json:"static"`
+	Interpreter string   `json:"interpreter,omitempty"`
+	Needed      []string `json:"needed,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// RunStatus is returned by the API for a run.
+type RunStatus struct {
+	ID     string     `json:"id"`
+	State  string     `json:"state"`
+	Error  string     `json:"error,omitempty"`
+	Result *RunResult `json:"result,omitempty"`
+}
+
+type run struct {
+	id    string
+	req   *RunRequest
+	sched *exec.Scheduler
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stdout *stream
+	stderr *stream
+	done   chan struct{}
+
+	mu     sync.Mutex
+	state  string
+	err    error
+	result *RunResult
+}
+
+func newRun(id string, req *RunRequest, streamLimit int) *run {
+	r := &run{
+		id:     id,
+		req:    req,
+		stdout: newStream(streamLimit),
+		stderr: newStream(streamLimit),
+		done:   make(chan struct{}),
+		state:  STATE_QUEUED,
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r
+}
+
+func (r *run) status() *RunStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := &RunStatus{ID: r.id, State: r.state, Result: r.result}
+	if r.err != nil {
+		s.Error = r.err.Error()
+	}
+	return s
+}
+
+func (r *run) setState(state string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state = state
+	r.err = err
+}
+
+func (r *run) finish(state string, err error, result *RunResult) {
+	r.mu.Lock()
+	r.state = state
+	r.err = err
+	r.result = result
+	r.mu.Unlock()
+
+	r.stdout.Close()
+	r.stderr.Close()
+	close(r.done)
+}
+
+func openFile(path, mode string) (*os.File, error) {
+	switch mode {
+	case "r", "":
+		return os.OpenFile(path, os.O_RDONLY, 0)
+	case "w":
+		return os.OpenFile(path, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0664)
+	case "rw":
+		return os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0664)
+	}
+	return nil, ErrBadFileMode
+}
+
+// command builds exec.Cmd by the request, files opened will be put into closers.
+func (r *run) command() (c *exec.Cmd, closers []*os.File, err error) {
+	req := r.req
+	if req.Command == "" {
+		return nil, nil, ErrNoCommand
+	}
+	c = exec.CommandContext(r.ctx, req.Command, req.Args...)
+	c.Scheduler = r.sched
+	c.ID = r.id
+	c.CaptureLog = req.CaptureLog
+	c.Envs = req.Env
+	c.Chroot = req.Chroot
+	c.Chdir = req.Chdir
+
+	defer func() {
+		if err != nil {
+			for _, f := range closers {
+				f.Close()
+			}
+			closers = nil
+		}
+	}()
+	open := func(path, mode string) (*os.File, error) {
+		f, err := openFile(path, mode)
+		if err == nil {
+			closers = append(closers, f)
+		}
+		return f, err
+	}
+
+	if req.StdinFile != "" {
+		if c.Stdin, err = open(req.StdinFile, "r"); err != nil {
+			return
+		}
+	} else if req.Stdin != "" {
+		c.Stdin = strings.NewReader(req.Stdin)
+	}
+	// output always go to stream, so it can be got by log api
+	c.Stdout, c.Stderr = r.stdout, r.stderr
+	if req.StdoutFile != "" {
+		var f *os.File
+		if f, err = open(req.StdoutFile, "w"); err != nil {
+			return
+		}
+		c.Stdout = f
+	}
+	if req.StderrFile != "" {
+		var f *os.File
+		if f, err = open(req.StderrFile, "w"); err != nil {
+			return
+		}
+		c.Stderr = f
+	}
+	for _, spec := range req.Files {
+		if spec.Fd < 3 {
+			err = ErrBadFd
+			return
+		}
+		var f *os.File
+		if f, err = open(spec.Path, spec.Mode); err != nil {
+			return
+		}
+		for len(c.ExtraFiles) <= spec.Fd-3 {
+			c.ExtraFiles = append(c.ExtraFiles, nil)
+		}
+		c.ExtraFiles[spec.Fd-3] = f
+	}
+
+	c.ResourceLimit = exec.Resource{
+		CpuTime:   req.Limit.CpuTime,
+		ClockTime: req.Limit.ClockTime,
+		Memory:    req.Limit.Memory,
+		Output:    req.Limit.Output,
+		Stdout:    req.Limit.Stdout,
+		Stderr:    req.Limit.Stderr,
+		Thread:    req.Limit.Thread,
+	}
+
+	c.Sys = &exec.SysAttr{
+		Ptrace:        req.Sys.Ptrace,
+		Setsid:        req.Sys.Setsid,
+		Setctty:       req.Sys.Setctty,
+		Ctty:          req.Sys.Ctty,
+		SetNoNewPrivs: req.Seccomp.NoNewPrivs,
+		Cloneflags:    uintptr(req.Sys.Cloneflags),
+		Pdeathsig:     req.Sys.Pdeathsig,
+		CPUSet:        req.Sys.CPUSet,
+	}
+	if len(req.Sys.Rlimit) > len(c.Sys.RlimitList) {
+		err = ErrBadRlimit
+		return
+	}
+	copy(c.Sys.RlimitList[:], req.Sys.Rlimit)
+	if req.Uid > 0 || req.Gid > 0 || req.Sys.Umask > 0 {
+		c.Sys.Credential = &exec.Credential{Uid: req.Uid, Gid: req.Gid, Umask: req.Sys.Umask}
+	}
+	if len(req.Sys.Bpf) > 0 {
+		if c.Sys.Bpf, err = parseBpf(req.Sys.Bpf); err != nil {
+			return
+		}
+	}
+	c.Syscall = &exec.SyscallLimit{
+		Level:         req.Seccomp.Level,
+		Action:        req.Seccomp.Action,
+		Helper:        req.Seccomp.Helper,
+		Arches:        req.Seccomp.Arches,
+		BadArchAction: req.Seccomp.BadArchAction,
+	}
+	if req.Seccomp.Helper != "" {
+		c.Syscall.Level = -1
+	}
+	return c, closers, nil
+}
+
+// parseBpf parse raw sock_filter array in native byte order.
+func parseBpf(b []byte) (*syscall.SockFprog, error) {
+	if len(b)%8 != 0 || len(b)/8 > 0xffff {
+		return nil, ErrBadBpf
+	}
+	var order binary.ByteOrder = binary.BigEndian
+	if helper.IsLittleEndian() {
+		order = binary.LittleEndian
+	}
+	filters := make([]syscall.SockFilter, len(b)/8)
+	if err := binary.Read(bytes.NewReader(b), order, filters); err != nil {
+		return nil, err
+	}
+	return &syscall.SockFprog{Len: uint16(len(filters)), Filter: &filters[0]}, nil
+}
+
+func newRunResult(res *exec.Result, r *run) *RunResult {
+	rr := &RunResult{
+		CpuTime:         res.CpuTime,
+		ClockTime:       res.ClockTime,
+		Memory:          res.MemoryUsed,
+		ExitStatus:      uint32(res.ExitStatus),
+		ExitCode:        res.ExitCode,
+		Exceed:          exec.EXCEED_STR[res.Exceed],
+		Verdict:         exec.VERDICT_STR[res.Verdict],
+		Output:          res.Output,
+		OutputTruncated: res.OutputTruncated,
+		HelpStr:         res.HelpStr,
+		CPU:             res.CPU,
+		Syscall:         res.Syscall,
+		SyscallArch:     res.SyscallArch,
+		Log:             string(res.Log),
+		Stdout:          r.stdout.String(),
+		Stderr:          r.stderr.String(),
+		StreamTruncated: r.stdout.Truncated() || r.stderr.Truncated(),
+		raw:             res,
+	}
+	if res.ExitStatus.Signaled() {
+		rr.Signal = int(res.ExitStatus.Signal())
+	}
+	for _, e := range res.Timeline {
+		rr.Timeline = append(rr.Timeline, TimelineEvent{
+			At:    int64(e.At / time.Microsecond),
+			Event: e.Event,
+			Child: e.Child,
+			Info:  e.Info,
+		})
+	}
+	if b := res.Binary; b.Path != "" {
+		rr.Binary = &Binary{
+			Path:        b.Path,
+			Script:      b.Script,
+			Arch:        b.Arch,
+			Bits:        b.Bits,
+			Static:      b.Static,
+			Interpreter: b.Interpreter,
+			Needed:      b.Needed,
+			Warnings:    b.Warnings,
+		}
+	}
+	return rr
+}
+
+// execute the run, it should be called in a worker.
+func (r *run) execute() {
+	if r.ctx.Err() != nil {
+		r.finish(STATE_CANCELLED, r.ctx.Err(), nil)
+		return
+	}
+
+	c, closers, err := r.command()
+	if err != nil {
+		r.finish(STATE_ERROR, err, nil)
+		return
+	}
+	defer func() {
+		for _, f := range closers {
+			f.Close()
+		}
+	}()
+
+	r.setState(STATE_RUNNING, nil)
+	if err = c.Run(); err != nil && c.ProcessState == nil {
+		if c.Process == nil && r.ctx.Err() != nil {
+			r.finish(STATE_CANCELLED, r.ctx.Err(), nil)
+		} else {
+			r.finish(STATE_ERROR, err, nil)
+		}
+		return
+	}
+	// copy error is ignored, the result is still useful
+	r.finish(STATE_FINISHED, nil, newRunResult(c.Result(), r))
+}
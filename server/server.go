@@ -0,0 +1,403 @@
+// +build linux
+
+// Package server provides an HTTP/JSON API to run programs in sandbox,
+// it is used by `sandbox serve`.
+//
+//	POST   /runs              submit a run, body is RunRequest, return RunStatus
+//	GET    /runs/{id}         get RunStatus of a run
+//	POST   /runs/{id}/cancel  cancel a queued or running run
+//	DELETE /runs/{id}         cancel a run and forget it
+//	GET    /runs/{id}/log     stream stdout (or stderr with ?stream=stderr) until the run finished
+//	GET    /status            status of the server
+//
+// If Server.Token is set, every request must have the header
+// `Authorization: Bearer <token>`.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/exec/log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("run not found")
+	ErrClosed       = errors.New("server closed")
+	ErrBadStream    = errors.New("stream must be stdout or stderr")
+	ErrUnauthorized = errors.New("bad or missing token")
+)
+
+const (
+	DEFAULT_RETENTION    = 10 * time.Minute
+	DEFAULT_STREAM_LIMIT = 4 << 20
+)
+
+// Status is the status of the server.
+type Status struct {
+	Workers  int   `json:"workers"`
+	Queued   int   `json:"queued"`
+	Running  int   `json:"running"`
+	Finished int   `json:"finished"`
+	CPUs     []int `json:"cpus,omitempty"` // cpus runs are pinned to
+}
+
+type Server struct {
+	// Policy restricts requests, it must not be changed after serving.
+	Policy Policy
+	// Retention is how long a finished run is kept, it is removed after that.
+	Retention time.Duration
+	// StreamLimit is the max bytes of stdout and stderr kept for each run.
+	StreamLimit int
+	// Token is required by requests if it is not empty.
+	Token string
+
+	workers int
+	slots   chan struct{} // a run must get a slot before execute
+	sched   *exec.Scheduler
+
+	mu     sync.Mutex
+	runs   map[string]*run
+	closed bool
+	wg     sync.WaitGroup
+
+	nextID uint64
+}
+
+// New returns a Server run at most workers runs at the same time.
+func New(workers int) *Server {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Server{
+		Policy:      Policy{Uid: DEFAULT_RUN_ID, Gid: DEFAULT_RUN_ID},
+		Retention:   DEFAULT_RETENTION,
+		StreamLimit: DEFAULT_STREAM_LIMIT,
+		workers:     workers,
+		slots:       make(chan struct{}, workers),
+		runs:        make(map[string]*run),
+	}
+}
+
+// NewWithScheduler returns a Server pin every run to a dedicated cpu of sched,
+// it runs at most one run per cpu.
+func NewWithScheduler(sched *exec.Scheduler) *Server {
+	s := New(len(sched.CPUs()))
+	s.sched = sched
+	return s
+}
+
+// Submit queues a run, it will be executed when there is a free worker. A
+// *ForbiddenError is returned if req is not allowed by the policy.
+func (s *Server) Submit(req *RunRequest) (*RunStatus, error) {
+	if err := s.Policy.Check(req); err != nil {
+		return nil, err
+	}
+	req.Uid, req.Gid = s.Policy.ids(req)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrClosed
+	}
+	id := strconv.FormatUint(atomic.AddUint64(&s.nextID, 1), 10)
+	r := newRun(id, req, s.StreamLimit)
+	r.sched = s.sched
+	s.runs[id] = r
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	go func() {
+		defer s.wg.Done()
+		defer s.expire(r)
+		select {
+		case s.slots <- struct{}{}:
+		case <-r.ctx.Done():
+			r.finish(STATE_CANCELLED, r.ctx.Err(), nil)
+			return
+		}
+		defer func() { <-s.slots }()
+		r.execute()
+	}()
+
+	if req.Wait {
+		<-r.done
+	}
+	return r.status(), nil
+}
+
+// expire removes the finished run after Retention.
+func (s *Server) expire(r *run) {
+	time.AfterFunc(s.Retention, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.runs[r.id] == r {
+			delete(s.runs, r.id)
+		}
+	})
+}
+
+func (s *Server) get(id string) (*run, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.runs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return r, nil
+}
+
+// Get returns the status of a run.
+func (s *Server) Get(id string) (*RunStatus, error) {
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	return r.status(), nil
+}
+
+// Wait waits the run finished or ctx done, and returns the status of the run.
+func (s *Server) Wait(ctx context.Context, id string) (*RunStatus, error) {
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	select {
+	case <-r.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return r.status(), nil
+}
+
+// Follow calls fn with the output of stream ("stdout" or "stderr") of a run
+// from the beginning, until the run finished or ctx done.
+func (s *Server) Follow(ctx context.Context, id string, stream string, fn func([]byte) error) error {
+	r, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	st := r.stdout
+	switch stream {
+	case "stdout", "":
+	case "stderr":
+		st = r.stderr
+	default:
+		return ErrBadStream
+	}
+	offset := 0
+	for {
+		data, changed, closed := st.next(offset)
+		if len(data) > 0 {
+			if err := fn(data); err != nil {
+				return err
+			}
+			offset += len(data)
+			continue
+		}
+		if closed {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Cancel cancels a queued or running run, a running process will be killed.
+func (s *Server) Cancel(id string) (*RunStatus, error) {
+	r, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	r.cancel()
+	<-r.done
+	return r.status(), nil
+}
+
+// Remove cancels a run and forgets it.
+func (s *Server) Remove(id string) error {
+	if _, err := s.Cancel(id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.runs, id)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *Server) Status() *Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := &Status{Workers: s.workers}
+	if s.sched != nil {
+		st.CPUs = s.sched.CPUs()
+	}
+	for _, r := range s.runs {
+		switch r.status().State {
+		case STATE_QUEUED:
+			st.Queued++
+		case STATE_RUNNING:
+			st.Running++
+		default:
+			st.Finished++
+		}
+	}
+	return st
+}
+
+// Close cancels all runs and waits them finished.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for _, r := range s.runs {
+		r.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Serve accepts connections on l and serve the API.
+func (s *Server) Serve(l net.Listener) error {
+	return http.Serve(l, s)
+}
+
+// Listen listens on addr, addr like `unix:///run/sandbox.sock` or `tcp://127.0.0.1:8080`,
+// a stale unix socket file will be removed.
+func Listen(addr string) (net.Listener, error) {
+	network, address := "tcp", addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, address = addr[:i], addr[i+3:]
+	}
+	if network == "unix" {
+		if _, err := os.Stat(address); err == nil {
+			_ = os.Remove(address)
+		}
+	}
+	return net.Listen(network, address)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.GetLog().Warning("write response error: {}", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch err {
+	case ErrNotFound:
+		code = http.StatusNotFound
+	case ErrClosed:
+		code = http.StatusServiceUnavailable
+	case ErrUnauthorized:
+		code = http.StatusUnauthorized
+	}
+	if _, ok := err.(*ForbiddenError); ok {
+		code = http.StatusForbidden
+	}
+	writeJSON(w, code, map[string]string{"error": err.Error()})
+}
+
+// Authorized reports whether the authorization header value has the token.
+func (s *Server) Authorized(auth string) bool {
+	if s.Token == "" {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.Token)) == 1
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !s.Authorized(req.Header.Get("Authorization")) {
+		writeError(w, ErrUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+
+	switch {
+	case len(parts) == 1 && parts[0] == "status" && req.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, s.Status())
+
+	case len(parts) == 1 && parts[0] == "runs" && req.Method == http.MethodPost:
+		var runReq RunRequest
+		if err := json.NewDecoder(req.Body).Decode(&runReq); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+		if runReq.Command == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": ErrNoCommand.Error()})
+			return
+		}
+		st, err := s.Submit(&runReq)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+
+	case len(parts) == 2 && parts[0] == "runs" && req.Method == http.MethodGet:
+		st, err := s.Get(parts[1])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+
+	case len(parts) == 2 && parts[0] == "runs" && req.Method == http.MethodDelete:
+		if err := s.Remove(parts[1]); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "cancel" && req.Method == http.MethodPost:
+		st, err := s.Cancel(parts[1])
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, st)
+
+	case len(parts) == 3 && parts[0] == "runs" && parts[2] == "log" && req.Method == http.MethodGet:
+		s.streamLog(w, req, parts[1], req.URL.Query().Get("stream"))
+
+	default:
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such api"})
+	}
+}
+
+// streamLog writes the stream to client until it is closed or client gone.
+func (s *Server) streamLog(w http.ResponseWriter, req *http.Request, id string, stream string) {
+	if _, err := s.get(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	if stream != "" && stream != "stdout" && stream != "stderr" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": ErrBadStream.Error()})
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	flusher, _ := w.(http.Flusher)
+	_ = s.Follow(req.Context(), id, stream, func(data []byte) error {
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+}
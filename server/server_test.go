@@ -0,0 +1,281 @@
+// +build linux
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/boxjan/golib/logs"
+	"github.com/sdibtacm/sandbox/exec"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPolicy lets requests run host commands without chroot as nobody.
+var testPolicy = Policy{Unsafe: true, Dirs: []string{"/bin", "/usr/bin"}, Uid: DEFAULT_RUN_ID, Gid: DEFAULT_RUN_ID}
+
+func newTestServer(workers int) *Server {
+	s := New(workers)
+	s.Policy = testPolicy
+	return s
+}
+
+func startServer(t *testing.T, s *Server) (*http.Client, func()) {
+	exec.SetLogger(logs.NewLoggerWithCmdWriter(logs.LevelWarningStr))
+
+	dir, err := ioutil.TempDir("", "sandbox-server-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sock := filepath.Join(dir, "sandbox.sock")
+	l, err := Listen("unix://" + sock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", sock)
+		},
+	}}
+	return client, func() {
+		l.Close()
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func call(t *testing.T, client *http.Client, method, path string, body, out interface{}) int {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, "http://sandbox"+path, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestServeRun(t *testing.T) {
+	client, stop := startServer(t, newTestServer(2))
+	defer stop()
+
+	var st RunStatus
+	code := call(t, client, "POST", "/runs", &RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "read x; echo out $x; echo err >&2; exit 3"},
+		Stdin:   "hello\n",
+		Limit:   Limit{ClockTime: 5000},
+		Wait:    true,
+	}, &st)
+	if code != http.StatusOK || st.State != STATE_FINISHED || st.Result == nil {
+		t.Fatalf("submit: code = %d, status = %+v", code, st)
+	}
+	r := st.Result
+	if r.Stdout != "out hello\n" || r.Stderr != "err\n" || r.ExitCode != 3 || r.Verdict != exec.VERDICT_STR[exec.VERDICT_RUNTIME_ERROR] {
+		t.Errorf("result = %+v", r)
+	}
+
+	var got RunStatus
+	if code := call(t, client, "GET", "/runs/"+st.ID, nil, &got); code != http.StatusOK || got.State != STATE_FINISHED {
+		t.Errorf("get: code = %d, status = %+v", code, got)
+	}
+	if code := call(t, client, "GET", "/runs/404", nil, nil); code != http.StatusNotFound {
+		t.Errorf("get not exist run: code = %d", code)
+	}
+}
+
+func TestServeCancelAndLog(t *testing.T) {
+	client, stop := startServer(t, newTestServer(1))
+	defer stop()
+
+	var running, queued RunStatus
+	call(t, client, "POST", "/runs", &RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "echo start; sleep 30"},
+	}, &running)
+	call(t, client, "POST", "/runs", &RunRequest{Command: "/bin/true"}, &queued)
+
+	// wait the first run output
+	resp, err := client.Get("http://sandbox/runs/" + running.ID + "/log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line := make([]byte, len("start\n"))
+	if _, err := resp.Body.Read(line); err != nil || string(line) != "start\n" {
+		t.Fatalf("log = %q, err = %v", line, err)
+	}
+
+	var st Status
+	call(t, client, "GET", "/status", nil, &st)
+	if st.Running != 1 || st.Queued != 1 {
+		t.Errorf("status = %+v, want 1 running and 1 queued", st)
+	}
+
+	start := time.Now()
+	var cancelled RunStatus
+	call(t, client, "POST", "/runs/"+running.ID+"/cancel", nil, &cancelled)
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("cancel takes %v", time.Since(start))
+	}
+	if cancelled.State != STATE_FINISHED || cancelled.Result == nil || cancelled.Result.Verdict != exec.VERDICT_STR[exec.VERDICT_CANCELLED] {
+		t.Errorf("cancelled status = %+v", cancelled)
+	}
+
+	// log is closed after the run finished
+	if rest, err := ioutil.ReadAll(resp.Body); err != nil || len(rest) != 0 {
+		t.Errorf("rest log = %q, err = %v", rest, err)
+	}
+
+	if code := call(t, client, "DELETE", "/runs/"+queued.ID, nil, nil); code != http.StatusNoContent {
+		t.Errorf("delete: code = %d", code)
+	}
+	if code := call(t, client, "GET", "/runs/"+queued.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("get deleted run: code = %d", code)
+	}
+}
+
+func TestServePolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sandbox-policy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	in := filepath.Join(dir, "in")
+	if err = ioutil.WriteFile(in, []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("/etc/passwd", filepath.Join(dir, "passwd")); err != nil {
+		t.Fatal(err)
+	}
+
+	strict := New(1)
+	strict.Policy.Dirs = []string{dir}
+	unsafe := New(1)
+	unsafe.Policy = testPolicy
+	unsafe.Policy.Dirs = append([]string{dir}, testPolicy.Dirs...)
+	root := New(1)
+	root.Policy = Policy{Unsafe: true, Dirs: unsafe.Policy.Dirs}
+	clients := make(map[*Server]*http.Client)
+	for _, s := range []*Server{strict, unsafe, root} {
+		client, stop := startServer(t, s)
+		defer stop()
+		clients[s] = client
+	}
+
+	for _, tt := range []struct {
+		name string
+		s    *Server
+		req  RunRequest
+		code int
+	}{
+		{"file in dir", unsafe, RunRequest{StdinFile: in, StdoutFile: filepath.Join(dir, "out")}, http.StatusOK},
+		{"file out of dir", unsafe, RunRequest{StdinFile: "/etc/passwd"}, http.StatusForbidden},
+		{"relative file", unsafe, RunRequest{StdinFile: "in"}, http.StatusForbidden},
+		{"dot dot", unsafe, RunRequest{StdoutFile: filepath.Join(dir, "../out")}, http.StatusForbidden},
+		{"symlink out of dir", unsafe, RunRequest{Files: []FileSpec{{Fd: 3, Path: filepath.Join(dir, "passwd")}}}, http.StatusForbidden},
+		{"command out of dir", unsafe, RunRequest{Command: "/etc/passwd"}, http.StatusForbidden},
+		{"relative command", unsafe, RunRequest{Command: "cat"}, http.StatusForbidden},
+		{"chdir out of dir", unsafe, RunRequest{Chdir: "/etc"}, http.StatusForbidden},
+		{"negative uid", unsafe, RunRequest{Uid: -1}, http.StatusForbidden},
+		{"root by default", root, RunRequest{}, http.StatusForbidden},
+		{"chroot in dir", strict, RunRequest{Chroot: dir, Command: "/cat", Chdir: "/"}, http.StatusOK},
+		{"no chroot", strict, RunRequest{}, http.StatusForbidden},
+		{"chroot out of dir", strict, RunRequest{Chroot: "/"}, http.StatusForbidden},
+		{"command out of chroot", strict, RunRequest{Chroot: dir, Command: "/../../bin/cat"}, http.StatusForbidden},
+		{"bpf", strict, RunRequest{Chroot: dir, Sys: Sys{Bpf: make([]byte, 8)}}, http.StatusForbidden},
+		{"cloneflags", strict, RunRequest{Chroot: dir, Sys: Sys{Cloneflags: 0x10000000}}, http.StatusForbidden},
+		{"uid", strict, RunRequest{Chroot: dir, Uid: 1000}, http.StatusForbidden},
+	} {
+		if tt.req.Command == "" {
+			tt.req.Command = "/bin/cat"
+		}
+		tt.req.Wait = true
+		var st RunStatus
+		if code := call(t, clients[tt.s], "POST", "/runs", &tt.req, &st); code != tt.code {
+			t.Errorf("%s: code = %d, status = %+v, want %d", tt.name, code, st, tt.code)
+		}
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "out")); err != nil || string(b) != "hello\n" {
+		t.Errorf("out = %q, err = %v", b, err)
+	}
+
+	var st RunStatus
+	call(t, clients[unsafe], "POST", "/runs", &RunRequest{Command: "/bin/sh", Args: []string{"-c", "id -u; id -g"}, Wait: true}, &st)
+	if st.Result == nil || st.Result.Stdout != "65534\n65534\n" {
+		t.Errorf("status = %+v, want run as nobody", st)
+	}
+}
+
+func TestServeRetentionAndStreamLimit(t *testing.T) {
+	s := newTestServer(1)
+	s.Retention = 100 * time.Millisecond
+	s.StreamLimit = 10
+	client, stop := startServer(t, s)
+	defer stop()
+
+	var st RunStatus
+	call(t, client, "POST", "/runs", &RunRequest{
+		Command: "/bin/sh",
+		Args:    []string{"-c", "head -c 100 /dev/zero"},
+		Wait:    true,
+	}, &st)
+	if st.Result == nil || len(st.Result.Stdout) != 10 || !st.Result.StreamTruncated || st.Result.Verdict != exec.VERDICT_STR[exec.VERDICT_OK] {
+		t.Errorf("status = %+v, want 10 bytes of stdout and truncated", st)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if code := call(t, client, "GET", "/runs/"+st.ID, nil, nil); code != http.StatusNotFound {
+		t.Errorf("get expired run: code = %d", code)
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	s := New(1)
+	s.Token = "secret"
+	client, stop := startServer(t, s)
+	defer stop()
+
+	if code := call(t, client, "GET", "/status", nil, nil); code != http.StatusUnauthorized {
+		t.Errorf("no token: code = %d", code)
+	}
+	for _, tt := range []struct {
+		auth string
+		code int
+	}{
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req, _ := http.NewRequest("GET", "http://sandbox/status", nil)
+		req.Header.Set("Authorization", tt.auth)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.code {
+			t.Errorf("%s: code = %d, want %d", tt.auth, resp.StatusCode, tt.code)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"sync"
+)
+
+// stream keeps bytes written to it up to limit, readers can follow it until it is closed.
+type stream struct {
+	mu        sync.Mutex
+	data      []byte
+	limit     int
+	truncated bool
+	closed    bool
+	changed   chan struct{} // closed and replaced when data changed or stream closed
+}
+
+func newStream(limit int) *stream {
+	return &stream{limit: limit, changed: make(chan struct{})}
+}
+
+// Write never fails, bytes over limit are dropped, so the process is not
+// affected by the size of the stream.
+func (s *stream) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(p)
+	if remain := s.limit - len(s.data); n > remain {
+		p = p[:remain]
+		s.truncated = true
+	}
+	if len(p) == 0 {
+		return n, nil
+	}
+	s.data = append(s.data, p...)
+	close(s.changed)
+	s.changed = make(chan struct{})
+	return n, nil
+}
+
+func (s *stream) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.changed)
+	}
+	return nil
+}
+
+func (s *stream) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.data)
+}
+
+func (s *stream) Truncated() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.truncated
+}
+
+// next returns data after offset, a channel will be closed when there is more data,
+// and whether the stream is closed.
+func (s *stream) next(offset int) ([]byte, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var data []byte
+	if offset < len(s.data) {
+		data = s.data[offset:len(s.data):len(s.data)]
+	}
+	return data, s.changed, s.closed
+}
@@ -0,0 +1,137 @@
+// +build linux
+
+package main
+
+import (
+	"fmt"
+	"github.com/sdibtacm/sandbox/exec"
+	"github.com/sdibtacm/sandbox/lang"
+	"github.com/sdibtacm/sandbox/units/helper"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+)
+
+var (
+	cmdSubmitLang        string
+	cmdSubmitProfiles    string
+	cmdSubmitWorkDir     string
+	cmdSubmitTimeLimit   uint // ms
+	cmdSubmitMemoryLimit string
+)
+
+var submitCmd = &cobra.Command{
+	Use:   "submit --lang LANG --tests dir [flags] SOURCE",
+	Short: "Compile a source by language profile and run it against test cases",
+	Long: `Compile a source by language profile and run it against test cases.
+
+The source is saved in a work dir by the file name of the profile, compiled with the compile
+limits of the profile, and the program runs against every case in --tests like judge.
+If compile failed, the verdict is compile error and the compiler output is printed.
+Builtin languages are ` + strings.Join(lang.DefaultProfiles().Names(), ", ") + `, more can be
+loaded by --profiles from a JSON array of profiles.`,
+	Example:       "sandbox submit --lang cpp17 --tests tests/ main.cpp",
+	Args:          cobra.ExactArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PreRun: func(cmd *cobra.Command, args []string) {
+		Init()
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return submit(args[0])
+	},
+}
+
+func init() {
+	flags := submitCmd.Flags()
+	flags.StringVar(&cmdSubmitLang, "lang", "", "Language `name` of the source")
+	flags.StringVar(&cmdSubmitProfiles, "profiles", "", "Load language profiles from JSON `file`")
+	flags.StringVar(&cmdSubmitWorkDir, "work-dir", "", "Keep the source and the program in `dir`, default is a temporary dir")
+	flags.UintVarP(&cmdSubmitTimeLimit, "max-time", "t", 0, "Limit cpu time of every case in micro seconds(ms), default is the limit of profile")
+	flags.StringVarP(&cmdSubmitMemoryLimit, "max-memory", "m", "", "Limit memory of every case, `bytes` supports common suffix like `k`, `m`, `g`, default is the limit of profile")
+	addJudgeFlags(submitCmd)
+	flags.StringVarP(&cmdLogPath, "log", "l", "console", "Log record set")
+	flags.BoolVarP(&cmdLogDebug, "debug", "v", false, "Set log level debug")
+	flags.BoolVar(&cmdLogVerbose, "verbose", false, "Record log verbose")
+	_ = submitCmd.MarkFlagRequired("lang")
+
+	cmd.AddCommand(submitCmd)
+}
+
+func submit(source string) error {
+	profiles := lang.DefaultProfiles()
+	if cmdSubmitProfiles != "" {
+		if err := profiles.Load(cmdSubmitProfiles); err != nil {
+			return err
+		}
+	}
+	p, err := profiles.Get(cmdSubmitLang)
+	if err != nil {
+		return err
+	}
+	cases, err := exec.LoadTestCases(cmdJudgeTests)
+	if err != nil {
+		return err
+	}
+	ch, err := judgeChecker()
+	if err != nil {
+		return err
+	}
+
+	s := &lang.Submission{
+		Profile: p,
+		Source:  source,
+		Batch: &exec.Batch{
+			Cases:         cases,
+			Checker:       ch,
+			Parallel:      cmdJudgeParallel,
+			StopOnFailure: cmdJudgeStopOnFailure,
+			OutputDir:     cmdJudgeOutputDir,
+		},
+		WorkDir: cmdSubmitWorkDir,
+	}
+	if cmdSubmitTimeLimit != 0 || cmdSubmitMemoryLimit != "" {
+		s.RunLimit = p.RunLimit
+		if cmdSubmitTimeLimit != 0 {
+			s.RunLimit.CpuTime = cmdSubmitTimeLimit
+			s.RunLimit.ClockTime = cmdSubmitTimeLimit * 3
+		}
+		if cmdSubmitMemoryLimit != "" {
+			s.RunLimit.Memory = helper.StrToBytes(cmdSubmitMemoryLimit)
+		}
+	}
+
+	ctx, cancel := signalContext()
+	defer cancel()
+	r, err := s.Run(ctx)
+	if err != nil {
+		return err
+	}
+	if cmdJudgeJson {
+		return printSubmitJson(r)
+	}
+	if r.Batch == nil {
+		fmt.Println(exec.VERDICT_STR[r.Verdict])
+		_, _ = os.Stdout.Write(r.Compile.Output)
+		return nil
+	}
+	printJudgeResult(r.Batch)
+	return nil
+}
+
+type submitResultJson struct {
+	Verdict       string           `json:"verdict"`
+	CompileOutput string           `json:"compile_output,omitempty"`
+	Result        *judgeResultJson `json:"result,omitempty"`
+}
+
+func printSubmitJson(r *lang.SubmitResult) error {
+	out := submitResultJson{Verdict: exec.VERDICT_STR[r.Verdict]}
+	if r.Compile != nil {
+		out.CompileOutput = string(r.Compile.Output)
+	}
+	if r.Batch != nil {
+		out.Result = newJudgeResultJson(r.Batch)
+	}
+	return printJson(out)
+}
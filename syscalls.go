@@ -0,0 +1,88 @@
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const syscallHelp = `Syscall limit:
+  --syscall-limit-level, -p    preset level of syscall white list, a level allows all below it
+                               0: no limit, 1: basic (read files, write stdout),
+                               2: + threads, 3: + write files, 4: + processes,
+                               5: + network, 6: + system, 7: all but dangerous syscalls
+  --syscall                    filter in lrun format, it is used instead of the preset level,
+                               not supported yet
+                               see https://github.com/quark-zju/lrun/blob/master/src/seccomp.h
+  --syscall-default-action     action of syscalls not in the filter
+                               0: deny, 1: allow (only lrun filter)
+  --syscall-bad-syscall-action action of denied syscalls
+                               0: kill, 1: trace (verdict is bad syscall), 2: EPERM, 3: ENOSYS
+  --syscall-arch               add seccomp arch to the filter, like x86 (int 0x80) or x32,
+                               rules are translated to it, arch of the executable is always added
+  --syscall-bad-arch-action    action of syscalls of arches not in the filter
+                               0: kill (verdict is bad syscall), 1: trace, 2: EPERM
+  --no-new-privs               set no_new_privs, it is always set when syscall is limited`
+
+var ErrUnknownSyscall = errors.New("unknown syscall")
+
+var syscallsCmd = &cobra.Command{
+	Use:   "syscalls [NAME|NUMBER...]",
+	Short: "Show help about syscall limit, or look up syscalls by name or number",
+	Long: `Show help about syscall limit, or look up syscalls by name or number.
+
+Numbers are of the native arch, the syscall of a bad syscall verdict can be looked up by it.`,
+	Example:       "sandbox syscalls execve 59",
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			printSyscallHelp()
+			return nil
+		}
+		return printSyscalls(args)
+	},
+}
+
+func init() {
+	cmd.AddCommand(syscallsCmd)
+}
+
+func printSyscallHelp() {
+	fmt.Println(syscallHelp)
+}
+
+func printSyscalls(args []string) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "NAME\tNUMBER")
+	for _, arg := range args {
+		name, nr, err := lookupSyscall(arg)
+		if err != nil {
+			_ = w.Flush()
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\n", name, nr)
+	}
+	return w.Flush()
+}
+
+func lookupSyscall(arg string) (string, int, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		name, err := seccomp.ScmpSyscall(n).GetName()
+		if err != nil {
+			return "", 0, fmt.Errorf("%v: %s", ErrUnknownSyscall, arg)
+		}
+		return name, n, nil
+	}
+	call, err := seccomp.GetSyscallFromName(arg)
+	if err != nil {
+		return "", 0, fmt.Errorf("%v: %s", ErrUnknownSyscall, arg)
+	}
+	return arg, int(call), nil
+}
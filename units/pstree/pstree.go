@@ -6,7 +6,6 @@ package pstree
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"sort"
@@ -39,10 +38,9 @@ func New() (*Tree, error) {
 		}
 		parent, ok := procs[proc.Stat.Ppid]
 		if !ok {
-			log.Panicf(
-				"internal logic error. parent of [%d] does not exist!",
-				pid,
-			)
+			// parent exited between Glob and scan, the process is
+			// being reparented, it will be found in next scan.
+			continue
 		}
 		parent.Children = append(parent.Children, pid)
 		procs[parent.Stat.Pid] = parent
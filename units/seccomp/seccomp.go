@@ -125,7 +125,8 @@ const (
 	// ActInvalid is a placeholder to ensure uninitialized ScmpAction
 	// variables are invalid
 	ActInvalid ScmpAction = iota
-	// ActKill kills the process
+	// ActKill kills the thread that violated the rule, other threads of the
+	// same thread group will continue to execute
 	ActKill ScmpAction = iota
 	// ActTrap throws SIGSYS
 	ActTrap ScmpAction = iota
@@ -141,6 +142,9 @@ const (
 	// This action is only usable when libseccomp API level 3 or higher is
 	// supported.
 	ActLog ScmpAction = iota
+	// ActKillProcess kills the whole process, it needs kernel 4.14 and
+	// libseccomp 2.4.0 or higher.
+	ActKillProcess ScmpAction = iota
 )
 
 const (
@@ -291,6 +295,8 @@ func (a ScmpCompareOp) String() string {
 func (a ScmpAction) String() string {
 	switch a & 0xFFFF {
 	case ActKill:
+		return "Action: Kill Thread"
+	case ActKillProcess:
 		return "Action: Kill Process"
 	case ActTrap:
 		return "Action: Send SIGSYS"
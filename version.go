@@ -0,0 +1,56 @@
+// +build linux
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sdibtacm/sandbox/units/seccomp"
+	"github.com/sdibtacm/sandbox/units/version"
+	"github.com/spf13/cobra"
+	"os"
+	"runtime"
+)
+
+var ErrUnknownShell = errors.New("unknown shell, only bash, zsh and powershell are supported")
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version of sandbox and the libraries it uses",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		major, minor, micro := seccomp.GetLibraryVersion()
+		fmt.Printf("sandbox %s\n", version.Get())
+		fmt.Printf("go %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+		fmt.Printf("libseccomp %d.%d.%d\n", major, minor, micro)
+	},
+}
+
+var completionCmd = &cobra.Command{
+	Use:   "completion bash|zsh|powershell",
+	Short: "Generate shell completion script",
+	Long: `Generate shell completion script.
+
+For bash, add "source <(sandbox completion bash)" to ~/.bashrc.
+For zsh, save "sandbox completion zsh" as _sandbox in a dir of $fpath.`,
+	Args:          cobra.ExactArgs(1),
+	ValidArgs:     []string{"bash", "zsh", "powershell"},
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	RunE: func(c *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return cmd.GenBashCompletion(os.Stdout)
+		case "zsh":
+			return cmd.GenZshCompletion(os.Stdout)
+		case "powershell":
+			return cmd.GenPowerShellCompletion(os.Stdout)
+		}
+		return fmt.Errorf("%v: %s", ErrUnknownShell, args[0])
+	},
+}
+
+func init() {
+	cmd.AddCommand(versionCmd)
+	cmd.AddCommand(completionCmd)
+}